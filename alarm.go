@@ -0,0 +1,93 @@
+package ical
+
+import (
+	"sort"
+	"time"
+)
+
+// FireTimes returns the times within [from, to) at which the alarm fires for
+// the event, including repetitions. A relative trigger is resolved against
+// DTSTART or DTEND of every occurrence of a recurring event.
+func (a *Alarm) FireTimes(v *Event, from, to time.Time) []time.Time {
+	instances := func(from, to time.Time) []time.Time {
+		return recurrences(v.StartDate, v.RRule, v.RDates, v.ExDates, from, to)
+	}
+	return a.fireTimes(instances, v.occurrence(v.StartDate).End.Sub(v.StartDate), from, to)
+}
+
+// TodoFireTimes returns the times within [from, to) at which the alarm fires
+// for the todo, including repetitions. A relative trigger is resolved against
+// DTSTART or DUE of every occurrence of a recurring todo.
+func (a *Alarm) TodoFireTimes(t *Todo, from, to time.Time) []time.Time {
+	if a.TriggerDate.IsZero() {
+		if a.Related == "END" && t.DueDate.IsZero() {
+			return nil
+		}
+		if a.Related != "END" && t.StartDate.IsZero() {
+			return nil
+		}
+	}
+
+	if t.StartDate.IsZero() {
+		// without DTSTART, the todo has a single instance anchored on DUE
+		instances := func(from, to time.Time) []time.Time {
+			if t.DueDate.IsZero() || t.DueDate.Before(from) || !t.DueDate.Before(to) {
+				return nil
+			}
+			return []time.Time{t.DueDate}
+		}
+		return a.fireTimes(instances, 0, from, to)
+	}
+
+	instances := func(from, to time.Time) []time.Time {
+		return recurrences(t.StartDate, t.RRule, t.RDates, t.ExDates, from, to)
+	}
+	return a.fireTimes(instances, t.DueDate.Sub(t.StartDate), from, to)
+}
+
+// fireTimes computes the fire times within [from, to) of the alarm given the
+// instance starts of its component and the length of an instance
+func (a *Alarm) fireTimes(instances func(from, to time.Time) []time.Time, length time.Duration, from, to time.Time) []time.Time {
+	var times []time.Time
+	repeats := a.repetitions()
+
+	add := func(t time.Time) {
+		for _, delay := range repeats {
+			fire := t.Add(delay)
+			if !fire.Before(from) && fire.Before(to) {
+				times = append(times, fire)
+			}
+		}
+	}
+
+	if !a.TriggerDate.IsZero() {
+		add(a.TriggerDate)
+		return times
+	}
+
+	shift := a.Offset
+	if a.Related == "END" {
+		shift += length
+	}
+
+	// instances whose fire times may fall within [from, to)
+	last := repeats[len(repeats)-1]
+	for _, start := range instances(from.Add(-shift-last), to.Add(-shift)) {
+		add(start.Add(shift))
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+// repetitions returns the delay of each firing of the alarm after its trigger
+func (a *Alarm) repetitions() []time.Duration {
+	delays := []time.Duration{0}
+	if a.Duration <= 0 {
+		return delays
+	}
+	for i := 1; i <= a.Repeat; i++ {
+		delays = append(delays, time.Duration(i)*a.Duration)
+	}
+	return delays
+}
@@ -0,0 +1,153 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlarmFireTimes(t *testing.T) {
+	day := func(d, h, m int) time.Time {
+		return time.Date(2019, time.January, d, h, m, 0, 0, time.UTC)
+	}
+
+	event := &Event{
+		StartDate: day(2, 10, 0),
+		EndDate:   day(2, 11, 0),
+	}
+	recurring := &Event{
+		StartDate: day(2, 10, 0),
+		EndDate:   day(2, 11, 0),
+		RRule:     &Recur{Freq: "DAILY", Count: 3},
+		ExDates:   []time.Time{day(3, 10, 0)},
+		RDates:    []time.Time{day(10, 8, 0)},
+	}
+
+	tests := []struct {
+		name  string
+		alarm *Alarm
+		event *Event
+		from  time.Time
+		to    time.Time
+		want  []time.Time
+	}{
+		{
+			name:  "relative to start",
+			alarm: &Alarm{Related: "START", Offset: -15 * time.Minute},
+			event: event,
+			from:  day(1, 0, 0),
+			to:    day(5, 0, 0),
+			want:  []time.Time{day(2, 9, 45)},
+		},
+		{
+			name:  "relative to end",
+			alarm: &Alarm{Related: "END", Offset: 5 * time.Minute},
+			event: event,
+			from:  day(1, 0, 0),
+			to:    day(5, 0, 0),
+			want:  []time.Time{day(2, 11, 5)},
+		},
+		{
+			name:  "repeated",
+			alarm: &Alarm{Related: "START", Offset: -15 * time.Minute, Repeat: 2, Duration: 5 * time.Minute},
+			event: event,
+			from:  day(1, 0, 0),
+			to:    day(5, 0, 0),
+			want:  []time.Time{day(2, 9, 45), day(2, 9, 50), day(2, 9, 55)},
+		},
+		{
+			name:  "repeated, window cuts the first firings",
+			alarm: &Alarm{Related: "START", Offset: -15 * time.Minute, Repeat: 2, Duration: 5 * time.Minute},
+			event: event,
+			from:  day(2, 9, 50),
+			to:    day(2, 9, 55),
+			want:  []time.Time{day(2, 9, 50)},
+		},
+		{
+			name:  "absolute",
+			alarm: &Alarm{TriggerDate: day(1, 8, 0)},
+			event: recurring,
+			from:  day(1, 0, 0),
+			to:    day(30, 0, 0),
+			want:  []time.Time{day(1, 8, 0)},
+		},
+		{
+			name:  "recurring",
+			alarm: &Alarm{Related: "START", Offset: -time.Hour},
+			event: recurring,
+			from:  day(1, 0, 0),
+			to:    day(30, 0, 0),
+			want:  []time.Time{day(2, 9, 0), day(4, 9, 0), day(10, 7, 0)},
+		},
+		{
+			name:  "recurring, alarm after the start of the window",
+			alarm: &Alarm{Related: "START", Offset: -time.Hour},
+			event: recurring,
+			from:  day(4, 9, 30),
+			to:    day(30, 0, 0),
+			want:  []time.Time{day(10, 7, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.alarm.FireTimes(tt.event, tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("got %v want %v", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAlarmTodoFireTimes(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"PRODID:-//test//EN",
+		"VERSION:2.0",
+		"BEGIN:VTODO",
+		"UID:todo@test",
+		"DTSTAMP:20190101T000000Z",
+		"DUE:20190105T170000Z",
+		"SUMMARY:Submit report",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Report is due",
+		"TRIGGER;RELATED=END:-PT1H",
+		"END:VALARM",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Report has no start",
+		"TRIGGER:-PT1H",
+		"END:VALARM",
+		"END:VTODO",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	c, err := Parse(strings.NewReader(ics), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Todos) != 1 || len(c.Todos[0].Alarms) != 2 {
+		t.Fatalf("got %d todos, expected one with two alarms", len(c.Todos))
+	}
+
+	todo := c.Todos[0]
+	from := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2019, time.February, 1, 0, 0, 0, 0, time.UTC)
+
+	got := todo.Alarms[0].TodoFireTimes(todo, from, to)
+	want := time.Date(2019, time.January, 5, 16, 0, 0, 0, time.UTC)
+	if len(got) != 1 || !got[0].Equal(want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	if got := todo.Alarms[1].TodoFireTimes(todo, from, to); len(got) != 0 {
+		t.Errorf("got %v, expected no fire time without DTSTART", got)
+	}
+}
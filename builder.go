@@ -0,0 +1,356 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An EventBuilder builds an event, writing its properties along with its
+// typed fields:
+//
+//	event, err := ical.NewEventBuilder().
+//		Start(start).
+//		End(end).
+//		Summary("Planning").
+//		Attendee("bob@example.com", "Bob").
+//		RRule("FREQ=WEEKLY;COUNT=4").
+//		Alarm(-10*time.Minute, "Planning").
+//		Build()
+//
+// The first error is kept and returned by Build.
+type EventBuilder struct {
+	v      *Event
+	allDay bool
+	err    error
+}
+
+// NewEventBuilder creates a builder of an empty event
+func NewEventBuilder() *EventBuilder {
+	return &EventBuilder{v: NewEvent()}
+}
+
+// UID sets the UID, a new one being generated by Build when not set
+func (b *EventBuilder) UID(uid string) *EventBuilder {
+	b.v.UID = uid
+	setProperty("UID", uid, &b.v.Properties)
+	return b
+}
+
+// Sequence sets the revision of the event
+func (b *EventBuilder) Sequence(n int) *EventBuilder {
+	b.v.Sequence = n
+	setProperty("SEQUENCE", strconv.Itoa(n), &b.v.Properties)
+	return b
+}
+
+// Start sets the start, with the TZID of the location of t, or in UTC for a
+// time in UTC or in the system location
+func (b *EventBuilder) Start(t time.Time) *EventBuilder {
+	b.v.StartDate = t
+	b.setDates("DTSTART", []time.Time{t})
+	return b
+}
+
+// End sets the end, exclusive, replacing the duration
+func (b *EventBuilder) End(t time.Time) *EventBuilder {
+	b.v.EndDate = t
+	removeProperty("DURATION", &b.v.Properties)
+	b.setDates("DTEND", []time.Time{t})
+	return b
+}
+
+// Duration sets the duration of the event rather than its end
+func (b *EventBuilder) Duration(d time.Duration) *EventBuilder {
+	b.v.EndDate = b.v.StartDate.Add(d)
+	removeProperty("DTEND", &b.v.Properties)
+	setProperty("DURATION", formatDuration(d), &b.v.Properties)
+	return b
+}
+
+// AllDay makes the event last whole days, its dates written without time.
+// An end within a day is rounded up to the next day.
+func (b *EventBuilder) AllDay() *EventBuilder {
+	b.allDay = true
+	b.v.AllDay = true
+	if end := b.v.EndDate; !end.IsZero() {
+		y, m, d := end.Date()
+		if day := time.Date(y, m, d, 0, 0, 0, 0, end.Location()); end.After(day) {
+			b.v.EndDate = day.AddDate(0, 0, 1)
+		}
+	}
+	for _, prop := range []struct {
+		name  string
+		times []time.Time
+	}{
+		{"DTSTART", []time.Time{b.v.StartDate}},
+		{"DTEND", []time.Time{b.v.EndDate}},
+		{"RDATE", b.v.RDates},
+		{"EXDATE", b.v.ExDates},
+		{"RECURRENCE-ID", []time.Time{b.v.RecurrenceID}},
+	} {
+		if hasProperty(prop.name, b.v.Properties) {
+			b.setDates(prop.name, prop.times)
+		}
+	}
+	return b
+}
+
+// Summary sets the summary, escaped
+func (b *EventBuilder) Summary(summary string) *EventBuilder {
+	b.v.Summary = escapeText(summary)
+	setProperty("SUMMARY", b.v.Summary, &b.v.Properties)
+	return b
+}
+
+// Description sets the description, escaped
+func (b *EventBuilder) Description(description string) *EventBuilder {
+	b.v.Description = escapeText(description)
+	setProperty("DESCRIPTION", b.v.Description, &b.v.Properties)
+	return b
+}
+
+// Location sets the location, escaped
+func (b *EventBuilder) Location(location string) *EventBuilder {
+	setProperty("LOCATION", escapeText(location), &b.v.Properties)
+	return b
+}
+
+// Categories adds categories
+func (b *EventBuilder) Categories(categories ...string) *EventBuilder {
+	escaped := make([]string, len(categories))
+	for i, category := range categories {
+		escaped[i] = escapeText(category)
+	}
+	b.add("CATEGORIES", strings.Join(escaped, ","))
+	return b
+}
+
+// Organizer sets the organizer, an email address or a calendar user
+// address, with its common name when not empty
+func (b *EventBuilder) Organizer(address, name string) *EventBuilder {
+	removeProperty("ORGANIZER", &b.v.Properties)
+	b.calAddress("ORGANIZER", address, name)
+	return b
+}
+
+// Attendee adds an attendee, an email address or a calendar user address,
+// with its common name when not empty
+func (b *EventBuilder) Attendee(address, name string) *EventBuilder {
+	b.calAddress("ATTENDEE", address, name)
+	return b
+}
+
+// RRule sets the recurrence rule, such as "FREQ=WEEKLY;COUNT=4"
+func (b *EventBuilder) RRule(rule string) *EventBuilder {
+	r, err := ParseRecur(strings.TrimPrefix(rule, "RRULE:"))
+	if err != nil {
+		b.fail(err)
+		return b
+	}
+	b.v.RRule = r
+	setProperty("RRULE", r.String(), &b.v.Properties)
+	return b
+}
+
+// RDate adds recurrence dates
+func (b *EventBuilder) RDate(times ...time.Time) *EventBuilder {
+	b.v.RDates = append(b.v.RDates, times...)
+	b.v.Properties = append(b.v.Properties, dateProperties("RDATE", times, b.allDay, false)...)
+	return b
+}
+
+// ExDate adds excluded recurrence dates
+func (b *EventBuilder) ExDate(times ...time.Time) *EventBuilder {
+	b.v.ExDates = append(b.v.ExDates, times...)
+	b.v.Properties = append(b.v.Properties, dateProperties("EXDATE", times, b.allDay, false)...)
+	return b
+}
+
+// RecurrenceID makes the event override the instance of a recurring event
+// starting at t
+func (b *EventBuilder) RecurrenceID(t time.Time) *EventBuilder {
+	b.v.RecurrenceID = t
+	b.setDates("RECURRENCE-ID", []time.Time{t})
+	return b
+}
+
+// Alarm adds a display alarm, triggered at an offset from the start
+func (b *EventBuilder) Alarm(offset time.Duration, description string) *EventBuilder {
+	a := NewAlarm()
+	setProperty("ACTION", "DISPLAY", &a.Properties)
+	setProperty("TRIGGER", formatDuration(offset), &a.Properties)
+	setProperty("DESCRIPTION", escapeText(description), &a.Properties)
+	return b.AddAlarm(a)
+}
+
+// AddAlarm adds an alarm made of its properties, its typed fields being set
+// from them
+func (b *EventBuilder) AddAlarm(a *Alarm) *EventBuilder {
+	if err := decodeAlarm(a, time.Local); err != nil {
+		b.fail(err)
+		return b
+	}
+	b.v.Alarms = append(b.v.Alarms, a)
+	return b
+}
+
+// Property adds a property, such as an X- property, with its value as is
+func (b *EventBuilder) Property(name, value string) *EventBuilder {
+	b.add(strings.ToUpper(name), value)
+	return b
+}
+
+// Build checks the event and returns it, with a new UID when not set and a
+// DTSTAMP. The builder can go on building other events from it.
+func (b *EventBuilder) Build() (*Event, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	v := b.v.clone()
+	if v.UID == "" {
+		v.UID = newUID()
+		removeProperty("UID", &v.Properties)
+		uid := &Property{Name: "UID", Value: v.UID, Params: make(map[string]*Param)}
+		v.Properties = append([]*Property{uid}, v.Properties...)
+	}
+	v.Timestamp = time.Now().UTC().Truncate(time.Second)
+	if prop := getProperty("DTSTAMP", v.Properties); prop != nil {
+		prop.Value = formatDateTime(v.Timestamp)
+	} else {
+		// right after the UID
+		i := 0
+		for i < len(v.Properties) && v.Properties[i].Name != "UID" {
+			i++
+		}
+		stamp := &Property{Name: "DTSTAMP", Value: formatDateTime(v.Timestamp), Params: make(map[string]*Param)}
+		properties := append([]*Property{}, v.Properties[:i+1]...)
+		v.Properties = append(append(properties, stamp), v.Properties[i+1:]...)
+	}
+
+	// typed fields are set again from the properties, checking them alike
+	if err := decodeEvent(v, "", time.Local); err != nil {
+		return nil, err
+	}
+	if v.EndDate.Before(v.StartDate) {
+		return nil, fmt.Errorf("event ends before it starts")
+	}
+	if v.AllDay && hasProperty("DTEND", v.Properties) && !v.EndDate.After(v.StartDate) {
+		return nil, fmt.Errorf("all-day event ends on the day it starts")
+	}
+	return v, nil
+}
+
+// setDates replaces the properties of a name by the ones of times, where
+// the first one was
+func (b *EventBuilder) setDates(name string, times []time.Time) {
+	index := len(b.v.Properties)
+	for i, prop := range b.v.Properties {
+		if prop.Name == name {
+			index = i
+			break
+		}
+	}
+	removeProperty(name, &b.v.Properties)
+	if index > len(b.v.Properties) {
+		index = len(b.v.Properties)
+	}
+
+	props := dateProperties(name, times, b.allDay, false)
+	properties := append([]*Property{}, b.v.Properties[:index]...)
+	properties = append(properties, props...)
+	b.v.Properties = append(properties, b.v.Properties[index:]...)
+}
+
+// calAddress adds a property of a calendar user address
+func (b *EventBuilder) calAddress(name, address, cn string) {
+	if !strings.Contains(address, ":") {
+		address = "mailto:" + address
+	}
+	prop := b.add(name, address)
+	if cn != "" {
+		prop.Params["CN"] = &Param{Values: []string{cn}}
+	}
+}
+
+func (b *EventBuilder) add(name, value string) *Property {
+	prop := NewProperty()
+	prop.Name = name
+	prop.Value = value
+	b.v.Properties = append(b.v.Properties, prop)
+	return prop
+}
+
+// fail keeps the first error
+func (b *EventBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// A CalendarBuilder builds a calendar of events
+type CalendarBuilder struct {
+	c *Calendar
+}
+
+// NewCalendarBuilder creates a builder of a calendar with the PRODID of
+// this package and the VERSION 2.0
+func NewCalendarBuilder() *CalendarBuilder {
+	c := NewCalendar()
+	c.Prodid = ProdID
+	c.Version = "2.0"
+	setProperty("PRODID", c.Prodid, &c.Properties)
+	setProperty("VERSION", c.Version, &c.Properties)
+	return &CalendarBuilder{c: c}
+}
+
+// ProdID sets the PRODID
+func (b *CalendarBuilder) ProdID(prodID string) *CalendarBuilder {
+	b.c.Prodid = prodID
+	setProperty("PRODID", prodID, &b.c.Properties)
+	return b
+}
+
+// Method sets the iTIP method, such as MethodRequest
+func (b *CalendarBuilder) Method(method string) *CalendarBuilder {
+	b.c.Method = method
+	setProperty("METHOD", method, &b.c.Properties)
+	return b
+}
+
+// Name sets the name of the calendar, escaped
+// from rfc7986-5.1
+func (b *CalendarBuilder) Name(name string) *CalendarBuilder {
+	setProperty("NAME", escapeText(name), &b.c.Properties)
+	return b
+}
+
+// Event adds events
+func (b *CalendarBuilder) Event(events ...*Event) *CalendarBuilder {
+	b.c.Events = append(b.c.Events, events...)
+	return b
+}
+
+// Build checks that no two events have the same UID and RECURRENCE-ID, and
+// returns the calendar, with a VTIMEZONE for each TZID of the events from
+// the IANA time zone database
+func (b *CalendarBuilder) Build() (*Calendar, error) {
+	seen := make(map[string]bool)
+	for _, v := range b.c.Events {
+		key := v.UID + "\n" + v.RecurrenceID.UTC().String()
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate event %s", v.UID)
+		}
+		seen[key] = true
+	}
+	c := *b.c
+	c.Properties = cloneProperties(b.c.Properties)
+	c.Events = append([]*Event{}, b.c.Events...)
+	c.Timezones = append([]*Timezone{}, b.c.Timezones...)
+	if err := addTimezones(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
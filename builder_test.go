@@ -0,0 +1,156 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBuilder(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	start := time.Date(2019, 1, 10, 10, 0, 0, 0, paris)
+	builder := NewEventBuilder().
+		UID("planning@example.com").
+		Start(start).
+		End(start.Add(time.Hour)).
+		Summary("Planning, Q1").
+		Organizer("alice@example.com", "Alice").
+		Attendee("bob@example.com", "").
+		RRule("FREQ=WEEKLY;COUNT=4").
+		ExDate(start.AddDate(0, 0, 7)).
+		Alarm(-10*time.Minute, "Planning").
+		Property("X-CRM-ID", "D-42")
+	v, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, prop := range v.Properties {
+		if prop.Name != "DTSTAMP" {
+			lines = append(lines, encodeProperty(prop))
+		}
+	}
+	expected := []string{
+		"UID:planning@example.com",
+		"DTSTART;TZID=Europe/Paris:20190110T100000",
+		"DTEND;TZID=Europe/Paris:20190110T110000",
+		`SUMMARY:Planning\, Q1`,
+		"ORGANIZER;CN=Alice:mailto:alice@example.com",
+		"ATTENDEE:mailto:bob@example.com",
+		"RRULE:FREQ=WEEKLY;COUNT=4",
+		"EXDATE;TZID=Europe/Paris:20190117T100000",
+		"X-CRM-ID:D-42",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+	if v.Properties[1].Name != "DTSTAMP" || v.Timestamp.IsZero() {
+		t.Errorf("expected a DTSTAMP after the UID, got %v", v.Properties[1])
+	}
+	if v.UID != "planning@example.com" || !v.StartDate.Equal(start) || !v.EndDate.Equal(start.Add(time.Hour)) ||
+		v.RRule == nil || v.RRule.Count != 4 || len(v.ExDates) != 1 || v.Summary != `Planning\, Q1` {
+		t.Errorf("expected typed fields in sync, got %+v", v)
+	}
+	if len(v.Alarms) != 1 || v.Alarms[0].Offset != -10*time.Minute {
+		t.Errorf("unexpected alarms %+v", v.Alarms)
+	}
+
+	// the builder goes on from the built event
+	other, err := builder.UID("review@example.com").Summary("Review").Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.UID != "planning@example.com" || other.UID != "review@example.com" || len(other.ExDates) != 1 {
+		t.Errorf("unexpected events %s %s", v.UID, other.UID)
+	}
+
+	c, err := NewCalendarBuilder().Method(MethodRequest).Event(v, other).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := Encode(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(&b, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Events) != 2 || parsed.Method != MethodRequest {
+		t.Errorf("unexpected calendar %+v", parsed)
+	}
+	if len(parsed.Timezones) != 1 || timezoneID(parsed.Timezones[0]) != "Europe/Paris" {
+		t.Errorf("expected the VTIMEZONE of Europe/Paris, got %d timezones", len(parsed.Timezones))
+	}
+	if _, err := NewCalendarBuilder().Event(v, v).Build(); err == nil {
+		t.Error("expected an error for a duplicate event")
+	}
+}
+
+func TestEventBuilderAllDay(t *testing.T) {
+	day := time.Date(2019, 1, 10, 0, 0, 0, 0, time.Local)
+	v, err := NewEventBuilder().Start(day).Summary("Day off").AllDay().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prop := getProperty("DTSTART", v.Properties); encodeProperty(prop) != "DTSTART;VALUE=DATE:20190110" {
+		t.Errorf("unexpected DTSTART %s", encodeProperty(prop))
+	}
+	if !v.AllDay || !v.EndDate.Equal(day.AddDate(0, 0, 1)) || v.UID == "" || v.Properties[0].Name != "UID" {
+		t.Errorf("unexpected event %+v", v)
+	}
+}
+
+func TestEventBuilderAllDayEnd(t *testing.T) {
+	start := time.Date(2019, 1, 10, 10, 0, 0, 0, time.UTC)
+	v, err := NewEventBuilder().Start(start).End(start.Add(time.Hour)).AllDay().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prop := getProperty("DTEND", v.Properties); encodeProperty(prop) != "DTEND;VALUE=DATE:20190111" {
+		t.Errorf("expected the end rounded up to the next day, got %s", encodeProperty(prop))
+	}
+
+	day := time.Date(2019, 1, 10, 0, 0, 0, 0, time.UTC)
+	if _, err := NewEventBuilder().AllDay().Start(day).End(day).Build(); err == nil {
+		t.Error("expected an error for an all-day event ending on its first day")
+	}
+}
+
+func TestEventBuilderEnd(t *testing.T) {
+	start := time.Date(2019, 1, 10, 10, 0, 0, 0, time.Local)
+	v, err := NewEventBuilder().Start(start).End(start.Add(time.Hour)).Duration(90 * time.Minute).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasProperty("DTEND", v.Properties) || !v.EndDate.Equal(start.Add(90*time.Minute)) {
+		t.Errorf("expected the duration to replace the end, got %s", v.EndDate)
+	}
+	if prop := getProperty("DTSTART", v.Properties); encodeProperty(prop) != "DTSTART:"+formatDateTime(start) {
+		t.Errorf("expected a DTSTART in UTC, got %s", encodeProperty(prop))
+	}
+
+	v, err = NewEventBuilder().Start(start).Duration(time.Hour).End(start.Add(2 * time.Hour)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasProperty("DURATION", v.Properties) || !v.EndDate.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("expected the end to replace the duration, got %s", v.EndDate)
+	}
+}
+
+func TestEventBuilderErrors(t *testing.T) {
+	start := time.Date(2019, 1, 10, 10, 0, 0, 0, time.UTC)
+	for name, builder := range map[string]*EventBuilder{
+		"no start":     NewEventBuilder().Summary("Planning"),
+		"invalid rule": NewEventBuilder().Start(start).RRule("FREQ=SOMETIMES"),
+		"end first":    NewEventBuilder().Start(start).End(start.Add(-time.Hour)),
+		"alarm":        NewEventBuilder().Start(start).AddAlarm(NewAlarm()),
+	} {
+		if _, err := builder.Build(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/iswangwenbin/ical"
+)
+
+// ErrPreconditionFailed is returned by a Client when the ETag of an object
+// doesn't match, the object having been changed by someone else
+var ErrPreconditionFailed = errors.New("caldav: precondition failed")
+
+// A Client accesses the calendars of a CalDAV server
+type Client struct {
+	HTTPClient *http.Client   // Client sending the requests, defaults to http.DefaultClient
+	Username   string         // Username for basic authentication, if any
+	Password   string         // Password for basic authentication
+	Location   *time.Location // Location of the parsed calendars, defaults to the system location
+	endpoint   *url.URL
+}
+
+// NewClient creates a Client for a server, the endpoint being the URL of the
+// CalDAV service, where the current user principal is looked for
+func NewClient(endpoint string) (*Client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("caldav: unsupported scheme %q", u.Scheme)
+	}
+	return &Client{endpoint: u}, nil
+}
+
+// FindPrincipal returns the path of the current user principal
+// from rfc5397-3
+func (c *Client) FindPrincipal() (string, error) {
+	ms, err := c.propfind(c.endpoint.Path, "0", `<D:current-user-principal/>`)
+	if err != nil {
+		return "", err
+	}
+	for _, props := range ms.props() {
+		if p := props.CurrentUserPrincipal.Href; p != "" {
+			return hrefPath(p), nil
+		}
+	}
+	return "", fmt.Errorf("caldav: no current-user-principal at %s", c.endpoint.Path)
+}
+
+// FindHomeSet returns the path of the calendar home set of a principal
+// from rfc4791-6.2.1
+func (c *Client) FindHomeSet(principal string) (string, error) {
+	ms, err := c.propfind(principal, "0", `<C:calendar-home-set/>`)
+	if err != nil {
+		return "", err
+	}
+	for _, props := range ms.props() {
+		if p := props.CalendarHomeSet.Href; p != "" {
+			return hrefPath(p), nil
+		}
+	}
+	return "", fmt.Errorf("caldav: no calendar-home-set for %s", principal)
+}
+
+// Calendars returns the calendar collections of a home set
+func (c *Client) Calendars(homeSet string) ([]Collection, error) {
+	ms, err := c.propfind(homeSet, "1", `<D:resourcetype/><D:displayname/><C:calendar-description/><C:supported-calendar-component-set/>`)
+	if err != nil {
+		return nil, err
+	}
+
+	var collections []Collection
+	for _, resp := range ms.Responses {
+		for _, ps := range resp.Propstat {
+			if !okStatus(ps.Status) || ps.Prop.ResourceType.Calendar == nil {
+				continue
+			}
+			coll := Collection{
+				Path:        collectionPath(hrefPath(resp.Href)),
+				Name:        ps.Prop.DisplayName,
+				Description: ps.Prop.Description,
+			}
+			for _, comp := range ps.Prop.ComponentSet.Comps {
+				coll.Components = append(coll.Components, comp.Name)
+			}
+			collections = append(collections, coll)
+		}
+	}
+	return collections, nil
+}
+
+// MultiGet returns the objects of a collection at the given paths, skipping
+// the missing ones
+// from rfc4791-7.9
+func (c *Client) MultiGet(collection string, paths ...string) ([]Object, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	var b strings.Builder
+	b.WriteString(`<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`)
+	b.WriteString(`<D:prop><D:getetag/><C:calendar-data/></D:prop>`)
+	for _, p := range paths {
+		b.WriteString(`<D:href>`)
+		xml.EscapeText(&b, []byte(href(p)))
+		b.WriteString(`</D:href>`)
+	}
+	b.WriteString(`</C:calendar-multiget>`)
+
+	ms, err := c.multistatus("REPORT", collection, "1", b.String())
+	if err != nil {
+		return nil, err
+	}
+	return c.objects(ms)
+}
+
+// GetObject returns an object
+func (c *Client) GetObject(path string) (*Object, error) {
+	resp, err := c.do("GET", path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	cal, err := ical.Parse(resp.Body, c.Location)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Path: path, ETag: resp.Header.Get("ETag"), Calendar: cal}, nil
+}
+
+// PutObject creates or updates an object and returns its new ETag, empty if
+// the server doesn't tell. The etag is the one of the object being updated,
+// empty to create an object. It returns ErrPreconditionFailed when the
+// object was changed since, or already exists.
+func (c *Client) PutObject(path string, cal *ical.Calendar, etag string) (string, error) {
+	var b bytes.Buffer
+	if err := ical.Encode(&b, cal); err != nil {
+		return "", err
+	}
+
+	header := http.Header{"Content-Type": {"text/calendar; charset=utf-8"}}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	} else {
+		header.Set("If-None-Match", "*")
+	}
+	resp, err := c.do("PUT", path, &b, header)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Header.Get("ETag"), nil
+}
+
+// DeleteObject deletes an object, if its ETag still matches when set
+func (c *Client) DeleteObject(path, etag string) error {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+	resp, err := c.do("DELETE", path, nil, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// A SyncResult holds the changes of a collection since a sync token
+type SyncResult struct {
+	Token   string   // Token to pass to the next sync
+	Updated []Object // Objects created or changed
+	Deleted []string // Paths of the deleted objects
+}
+
+// Sync returns the changes of a collection since a sync token, every object
+// for an empty token. The changed objects are fetched with a multiget. It
+// returns ErrInvalidSyncToken for a token rejected by the server, invalid or
+// expired, the collection having to be synced again from an empty token.
+// from rfc6578-3.2
+func (c *Client) Sync(collection, token string) (*SyncResult, error) {
+	var b strings.Builder
+	b.WriteString(`<D:sync-collection xmlns:D="DAV:"><D:sync-token>`)
+	xml.EscapeText(&b, []byte(token))
+	b.WriteString(`</D:sync-token><D:sync-level>1</D:sync-level><D:prop><D:getetag/></D:prop></D:sync-collection>`)
+
+	ms, err := c.multistatus("REPORT", collection, "", b.String())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{Token: ms.SyncToken}
+	var changed []string
+	for _, resp := range ms.Responses {
+		p := hrefPath(resp.Href)
+		switch {
+		case strings.Contains(resp.Status, " 404 "):
+			result.Deleted = append(result.Deleted, p)
+		case len(resp.Propstat) > 0 && okStatus(resp.Propstat[0].Status):
+			changed = append(changed, p)
+		}
+	}
+
+	if result.Updated, err = c.MultiGet(collection, changed...); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// objects returns the objects of a multiget response
+func (c *Client) objects(ms *davResponse) ([]Object, error) {
+	var objects []Object
+	for _, resp := range ms.Responses {
+		for _, ps := range resp.Propstat {
+			if !okStatus(ps.Status) || ps.Prop.CalendarData == "" {
+				continue
+			}
+			cal, err := ical.Parse(strings.NewReader(ps.Prop.CalendarData), c.Location)
+			if err != nil {
+				return nil, fmt.Errorf("caldav: %s: %v", resp.Href, err)
+			}
+			objects = append(objects, Object{Path: hrefPath(resp.Href), ETag: ps.Prop.ETag, Calendar: cal})
+		}
+	}
+	return objects, nil
+}
+
+// props returns the properties found in a multistatus response
+func (ms *davResponse) props() []davProps {
+	var props []davProps
+	for _, resp := range ms.Responses {
+		for _, ps := range resp.Propstat {
+			if okStatus(ps.Status) {
+				props = append(props, ps.Prop)
+			}
+		}
+	}
+	return props
+}
+
+// okStatus checks if a DAV:status is a success
+func okStatus(status string) bool {
+	return strings.Contains(status, " 2")
+}
+
+// propfind sends a PROPFIND request for some properties
+func (c *Client) propfind(path, depth, props string) (*davResponse, error) {
+	body := `<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav"><D:prop>` + props + `</D:prop></D:propfind>`
+	return c.multistatus("PROPFIND", path, depth, body)
+}
+
+// multistatus sends a request answered with a multistatus response
+func (c *Client) multistatus(method, path, depth, body string) (*davResponse, error) {
+	header := http.Header{"Content-Type": {"application/xml; charset=utf-8"}}
+	if depth != "" {
+		header.Set("Depth", depth)
+	}
+	resp, err := c.do(method, path, strings.NewReader(xml.Header+body), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("caldav: %s %s: expected a multistatus response, got %s", method, path, resp.Status)
+	}
+	var ms davResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+// do sends a request to a path of the server, returning an error for a
+// status other than 2xx
+func (c *Client) do(method, path string, body io.Reader, header http.Header) (*http.Response, error) {
+	u := c.endpoint.ResolveReference(&url.URL{Path: path})
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	case http.StatusPreconditionFailed:
+		return nil, ErrPreconditionFailed
+	case http.StatusForbidden, http.StatusConflict:
+		// from rfc6578-3.2
+		if bytes.Contains(message, []byte("valid-sync-token")) {
+			return nil, ErrInvalidSyncToken
+		}
+	}
+	return nil, fmt.Errorf("caldav: %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+}
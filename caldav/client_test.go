@@ -0,0 +1,117 @@
+package caldav
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iswangwenbin/ical"
+)
+
+func TestClient(t *testing.T) {
+	h := newServer(t)
+	h.Principal = "/principals/john/"
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	c, err := NewClient(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	principal, err := c.FindPrincipal()
+	if err != nil || principal != "/principals/john/" {
+		t.Fatalf("expected principal /principals/john/, got %q, %v", principal, err)
+	}
+	homeSet, err := c.FindHomeSet(principal)
+	if err != nil || homeSet != "/" {
+		t.Fatalf("expected home set /, got %q, %v", homeSet, err)
+	}
+	calendars, err := c.Calendars(homeSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendars) != 1 || calendars[0].Path != "/work/" || calendars[0].Name != "Work" || strings.Join(calendars[0].Components, ",") != "VEVENT" {
+		t.Fatalf("unexpected calendars %+v", calendars)
+	}
+
+	sync, err := c.Sync("/work/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sync.Updated) != 1 || len(sync.Deleted) != 0 || sync.Token == "" {
+		t.Fatalf("unexpected initial sync %+v", sync)
+	}
+	meeting := sync.Updated[0]
+	if meeting.Path != "/work/meeting.ics" || meeting.ETag == "" || meeting.Calendar.Events[0].UID != "meeting@example.com" {
+		t.Fatalf("unexpected object %+v", meeting)
+	}
+
+	// create an event, then change the meeting
+	v := ical.NewEvent()
+	v.Properties = []*ical.Property{
+		{Name: "UID", Value: "lunch@example.com", Params: map[string]*ical.Param{}},
+		{Name: "DTSTAMP", Value: "20190101T000000Z", Params: map[string]*ical.Param{}},
+		{Name: "DTSTART", Value: "20190111T120000Z", Params: map[string]*ical.Param{}},
+		{Name: "SUMMARY", Value: "Lunch", Params: map[string]*ical.Param{}},
+	}
+	lunch := ical.NewCalendar()
+	lunch.Properties = meeting.Calendar.Properties
+	lunch.Events = append(lunch.Events, v)
+	if _, err := c.PutObject("/work/lunch.ics", lunch, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PutObject("/work/lunch.ics", lunch, ""); err != ErrPreconditionFailed {
+		t.Errorf("expected ErrPreconditionFailed creating an existing object, got %v", err)
+	}
+
+	for _, prop := range meeting.Calendar.Events[0].Properties {
+		if prop.Name == "SUMMARY" {
+			prop.Value = "Review"
+		}
+	}
+	etag, err := c.PutObject(meeting.Path, meeting.Calendar, meeting.ETag)
+	if err != nil || etag == "" || etag == meeting.ETag {
+		t.Fatalf("expected a new etag, got %q, %v", etag, err)
+	}
+	if _, err := c.PutObject(meeting.Path, meeting.Calendar, meeting.ETag); err != ErrPreconditionFailed {
+		t.Errorf("expected ErrPreconditionFailed with a stale etag, got %v", err)
+	}
+
+	next, err := c.Sync("/work/", sync.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, o := range next.Updated {
+		paths = append(paths, o.Path)
+	}
+	if strings.Join(paths, " ") != "/work/lunch.ics /work/meeting.ics" || len(next.Deleted) != 0 {
+		t.Fatalf("unexpected sync %+v", next)
+	}
+	if next.Updated[1].Calendar.Events[0].Summary != "Review" {
+		t.Errorf("expected the updated summary, got %q", next.Updated[1].Calendar.Events[0].Summary)
+	}
+
+	if err := c.DeleteObject("/work/lunch.ics", ""); err != nil {
+		t.Fatal(err)
+	}
+	last, err := c.Sync("/work/", next.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(last.Updated) != 0 || strings.Join(last.Deleted, " ") != "/work/lunch.ics" {
+		t.Errorf("unexpected sync %+v", last)
+	}
+
+	objects, err := c.MultiGet("/work/", "/work/meeting.ics", "/work/lunch.ics")
+	if err != nil || len(objects) != 1 || objects[0].ETag != etag {
+		t.Errorf("expected the meeting only, got %+v, %v", objects, err)
+	}
+	if _, err := c.GetObject("/work/lunch.ics"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := c.Sync("/work/", "data:,99"); err != ErrInvalidSyncToken {
+		t.Errorf("expected ErrInvalidSyncToken, got %v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package caldav
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iswangwenbin/ical"
+)
+
+// dateTimeLayoutUTC is the layout of the bounds of a time-range
+const dateTimeLayoutUTC = "20060102T150405Z"
+
+// farFuture bounds the expansion of the components with a finite number of
+// instances, for a time-range without end
+var farFuture = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// parseTimeRange returns the bounds of a time-range, zero when open
+func parseTimeRange(tr *timeRange) (start, end time.Time, err error) {
+	if tr.Start == "" && tr.End == "" {
+		return start, end, fmt.Errorf("time-range without start nor end")
+	}
+	if tr.Start != "" {
+		if start, err = time.Parse(dateTimeLayoutUTC, tr.Start); err != nil {
+			return start, end, err
+		}
+	}
+	if tr.End != "" {
+		if end, err = time.Parse(dateTimeLayoutUTC, tr.End); err != nil {
+			return start, end, err
+		}
+	}
+	return start, end, nil
+}
+
+// unbounded checks if a recurrence rule has neither COUNT nor UNTIL, its
+// instances going on after any time
+func unbounded(rrule *ical.Recur) bool {
+	return rrule != nil && rrule.Count == 0 && rrule.Until.IsZero()
+}
+
+// eventsBetween returns the events of a calendar overlapping a time-range.
+// Without end, the events recurring forever overlap it, the others being
+// expanded up to their last instance.
+func eventsBetween(c *ical.Calendar, start, end time.Time) map[*ical.Event]bool {
+	matched := make(map[*ical.Event]bool)
+	bounded := c
+	if end.IsZero() {
+		bounded, end = ical.NewCalendar(), farFuture
+		for _, v := range c.Events {
+			if unbounded(v.RRule) {
+				matched[v] = true
+			} else {
+				bounded.Events = append(bounded.Events, v)
+			}
+		}
+	}
+	for _, o := range bounded.EventsBetween(start, end) {
+		matched[o.Event] = true
+	}
+	return matched
+}
+
+// matchFilter checks if a calendar object matches the comp-filter of a
+// calendar-query, which applies to the VCALENDAR component
+// from rfc4791-9.7.1
+func matchFilter(f compFilter, c *ical.Calendar) (bool, error) {
+	if f.Name != "VCALENDAR" {
+		return false, nil
+	}
+	if f.IsNotDefined != nil {
+		return false, nil
+	}
+	for _, sub := range f.CompFilters {
+		ok, err := matchComponents(sub, c)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchComponents checks if at least one component of the calendar of the
+// type of the filter matches it, or if there is none for is-not-defined
+func matchComponents(f compFilter, c *ical.Calendar) (bool, error) {
+	var start, end time.Time
+	if f.TimeRange != nil {
+		var err error
+		if start, end, err = parseTimeRange(f.TimeRange); err != nil {
+			return false, err
+		}
+	}
+	bound := end
+	if bound.IsZero() {
+		bound = farFuture
+	}
+
+	count := 0
+	switch f.Name {
+	case "VEVENT":
+		count = len(c.Events)
+		if f.IsNotDefined != nil || count == 0 {
+			break
+		}
+		var matched map[*ical.Event]bool
+		if f.TimeRange != nil {
+			matched = eventsBetween(c, start, end)
+		}
+		for _, v := range c.Events {
+			if f.TimeRange != nil && !matched[v] {
+				continue
+			}
+			ok, err := matchAlarms(f.CompFilters, v.Alarms, v.RRule, func(a *ical.Alarm, from, to time.Time) bool {
+				return len(a.FireTimes(v, from, to)) > 0
+			})
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case "VTODO":
+		count = len(c.Todos)
+		if f.IsNotDefined != nil || count == 0 {
+			break
+		}
+		overrides := make(map[string][]time.Time)
+		for _, t := range c.Todos {
+			if !t.RecurrenceID.IsZero() {
+				overrides[t.UID] = append(overrides[t.UID], t.RecurrenceID)
+			}
+		}
+		for _, t := range c.Todos {
+			if f.TimeRange != nil && !todoBetween(t, overrides[t.UID], start, end) {
+				continue
+			}
+			ok, err := matchAlarms(f.CompFilters, t.Alarms, t.RRule, func(a *ical.Alarm, from, to time.Time) bool {
+				return len(a.TodoFireTimes(t, from, to)) > 0
+			})
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case "VFREEBUSY":
+		count = len(c.FreeBusys)
+		if f.IsNotDefined != nil || count == 0 || f.TimeRange == nil {
+			break
+		}
+		for _, fb := range c.FreeBusys {
+			if start.Before(fb.EndDate) && bound.After(fb.StartDate) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case "VTIMEZONE":
+		count = len(c.Timezones)
+	}
+
+	if f.IsNotDefined != nil {
+		return count == 0, nil
+	}
+	return count > 0, nil
+}
+
+// matchAlarms checks the comp-filters nested in a VEVENT or VTODO filter,
+// only VALARM filters being supported. Without end, the relative alarms of
+// a component recurring forever fire within the time-range.
+func matchAlarms(filters []compFilter, alarms []*ical.Alarm, rrule *ical.Recur, fires func(a *ical.Alarm, from, to time.Time) bool) (bool, error) {
+	for _, f := range filters {
+		if f.Name != "VALARM" {
+			return false, nil
+		}
+		if f.IsNotDefined != nil {
+			if len(alarms) > 0 {
+				return false, nil
+			}
+			continue
+		}
+		if f.TimeRange == nil {
+			if len(alarms) == 0 {
+				return false, nil
+			}
+			continue
+		}
+
+		start, end, err := parseTimeRange(f.TimeRange)
+		if err != nil {
+			return false, err
+		}
+		forever := end.IsZero() && unbounded(rrule)
+		if end.IsZero() {
+			end = farFuture
+		}
+		found := false
+		for _, a := range alarms {
+			if (forever && a.TriggerDate.IsZero()) || fires(a, start, end) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// todoBetween checks if an instance of a todo overlaps a time-range, its
+// recurrences expanded except the overridden ones. Without end, a todo
+// recurring forever overlaps it.
+func todoBetween(t *ical.Todo, overridden []time.Time, start, end time.Time) bool {
+	if t.StartDate.IsZero() || !t.RecurrenceID.IsZero() || (t.RRule == nil && len(t.RDates) == 0) {
+		if end.IsZero() {
+			end = farFuture
+		}
+		return todoOverlaps(t, start, end)
+	}
+	if end.IsZero() {
+		if unbounded(t.RRule) {
+			return true
+		}
+		end = farFuture
+	}
+
+	var length time.Duration
+	if !t.DueDate.IsZero() {
+		length = t.DueDate.Sub(t.StartDate)
+	}
+	// instances starting before the range may still be due within it
+	from := start.Add(-length)
+	starts := []time.Time{t.StartDate}
+	if t.RRule != nil {
+		starts = append(starts, t.RRule.Between(t.StartDate, from, end)...)
+	}
+	starts = append(starts, t.RDates...)
+
+	for _, s := range starts {
+		if s.Before(from) || !s.Before(end) || containsTime(t.ExDates, s) || containsTime(overridden, s) {
+			continue
+		}
+		instance := *t
+		instance.StartDate = s
+		if !t.DueDate.IsZero() {
+			instance.DueDate = s.Add(length)
+		}
+		if todoOverlaps(&instance, start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTime(list []time.Time, t time.Time) bool {
+	for _, v := range list {
+		if v.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// todoOverlaps checks if a todo overlaps a time-range, from its start and
+// due dates. A todo without any of them always overlaps.
+// from rfc4791-9.9
+func todoOverlaps(t *ical.Todo, start, end time.Time) bool {
+	switch {
+	case !t.StartDate.IsZero() && !t.DueDate.IsZero():
+		return (start.Before(t.DueDate) || !start.After(t.StartDate)) && (end.After(t.StartDate) || !end.Before(t.DueDate))
+	case !t.StartDate.IsZero():
+		return !start.After(t.StartDate) && end.After(t.StartDate)
+	case !t.DueDate.IsZero():
+		return start.Before(t.DueDate) && !end.Before(t.DueDate)
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package caldav
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/iswangwenbin/ical"
+)
+
+func TestMatchTodoRecurrences(t *testing.T) {
+	todo := func(lines ...string) *ical.Calendar {
+		t.Helper()
+		ics := strings.Join(append(append([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"PRODID:-//test//EN",
+			"BEGIN:VTODO",
+			"UID:report@example.com",
+			"DTSTAMP:20190101T000000Z",
+			"DTSTART:20190107T090000Z",
+			"DUE:20190107T170000Z",
+		}, lines...), "END:VTODO", "END:VCALENDAR", ""), "\r\n")
+		c, err := ical.Parse(strings.NewReader(ics), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		calendar *ical.Calendar
+		start    string
+		end      string
+		matches  bool
+	}{
+		{"first instance", todo("RRULE:FREQ=WEEKLY;COUNT=4"), "20190107T100000Z", "20190107T110000Z", true},
+		{"later instance", todo("RRULE:FREQ=WEEKLY;COUNT=4"), "20190121T100000Z", "20190121T110000Z", true},
+		{"between instances", todo("RRULE:FREQ=WEEKLY;COUNT=4"), "20190122T000000Z", "20190127T000000Z", false},
+		{"after the last instance", todo("RRULE:FREQ=WEEKLY;COUNT=4"), "20190201T000000Z", "", false},
+		{"excluded instance", todo("RRULE:FREQ=WEEKLY;COUNT=4", "EXDATE:20190121T090000Z"), "20190121T100000Z", "20190121T110000Z", false},
+		{"recurrence date", todo("RDATE:20190301T090000Z"), "20190301T100000Z", "20190301T110000Z", true},
+		{"without end", todo("RRULE:FREQ=WEEKLY"), "20900101T000000Z", "", true},
+	}
+	for _, tt := range tests {
+		f := compFilter{Name: "VCALENDAR", CompFilters: []compFilter{{Name: "VTODO", TimeRange: &timeRange{Start: tt.start, End: tt.end}}}}
+		matches, err := matchFilter(f, tt.calendar)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if matches != tt.matches {
+			t.Errorf("%s: expected match %v, got %v", tt.name, tt.matches, matches)
+		}
+	}
+}
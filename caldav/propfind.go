@@ -0,0 +1,415 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/iswangwenbin/ical"
+)
+
+// a resource is the target of a PROPFIND or a REPORT: the home set or the
+// principal, a collection, or an object
+type resource struct {
+	path       string
+	collection *Collection
+	object     *Object
+}
+
+// resource returns the resource at a path
+func (h *Handler) resource(p string) (*resource, error) {
+	if collectionPath(p) == collectionPath(h.HomeSet) || collectionPath(p) == collectionPath(h.Principal) {
+		return &resource{path: collectionPath(p)}, nil
+	}
+	if coll, err := h.Store.Collection(collectionPath(p)); err == nil {
+		return &resource{path: coll.Path, collection: coll}, nil
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+	o, err := h.Store.Object(p)
+	if err != nil {
+		return nil, err
+	}
+	return &resource{path: o.Path, object: o}, nil
+}
+
+// children returns the members of a resource: the collections of the home
+// set, or the objects of a collection
+func (h *Handler) children(res *resource) ([]*resource, error) {
+	var children []*resource
+	switch {
+	case res.object != nil:
+	case res.collection != nil:
+		objects, err := h.Store.Objects(res.collection.Path)
+		if err != nil {
+			return nil, err
+		}
+		for i := range objects {
+			children = append(children, &resource{path: objects[i].Path, object: &objects[i]})
+		}
+	case res.path == collectionPath(h.HomeSet):
+		collections, err := h.Store.Collections()
+		if err != nil {
+			return nil, err
+		}
+		for i := range collections {
+			if strings.HasPrefix(collections[i].Path, res.path) && collections[i].Path != res.path {
+				children = append(children, &resource{path: collections[i].Path, collection: &collections[i]})
+			}
+		}
+	}
+	return children, nil
+}
+
+// propfind answers a PROPFIND request. A Depth of infinity is handled as 1.
+// from rfc4918-9.1
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request) error {
+	var req propfind
+	ok, err := readXML(r, &req)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		req.AllProp = &struct{}{}
+	}
+
+	res, err := h.resource(r.URL.Path)
+	if err != nil {
+		return err
+	}
+	resources := []*resource{res}
+	if r.Header.Get("Depth") != "0" {
+		children, err := h.children(res)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, children...)
+	}
+
+	ms := multistatus{}
+	for _, res := range resources {
+		var resp response
+		switch {
+		case req.PropName != nil:
+			resp = response{Href: href(res.path), Propstat: []propstat{{Status: statusLine(http.StatusOK)}}}
+			for _, name := range h.propNames(res) {
+				resp.Propstat[0].Prop.Values = append(resp.Propstat[0].Prop.Values, element{XMLName: name})
+			}
+		case req.Prop != nil:
+			resp, err = h.response(res, req.Prop.Names)
+		default:
+			var names []element
+			for _, name := range h.propNames(res) {
+				names = append(names, element{XMLName: name})
+			}
+			resp, err = h.response(res, names)
+		}
+		if err != nil {
+			return err
+		}
+		ms.Responses = append(ms.Responses, resp)
+	}
+
+	writeXML(w, http.StatusMultiStatus, ms)
+	return nil
+}
+
+// propNames returns the names of the properties of a resource, returned for
+// allprop. CALDAV:calendar-data must be asked for explicitly.
+func (h *Handler) propNames(res *resource) []xml.Name {
+	names := []xml.Name{
+		{Space: nsDAV, Local: "resourcetype"},
+		{Space: nsDAV, Local: "current-user-principal"},
+	}
+	switch {
+	case res.object != nil:
+		names = append(names,
+			xml.Name{Space: nsDAV, Local: "getetag"},
+			xml.Name{Space: nsDAV, Local: "getcontenttype"},
+			xml.Name{Space: nsDAV, Local: "getlastmodified"},
+		)
+	case res.collection != nil:
+		names = append(names,
+			xml.Name{Space: nsDAV, Local: "displayname"},
+			xml.Name{Space: nsCalDAV, Local: "calendar-description"},
+			xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"},
+		)
+		if _, ok := h.Store.(SyncStore); ok {
+			names = append(names, xml.Name{Space: nsDAV, Local: "sync-token"})
+		}
+	default:
+		names = append(names,
+			xml.Name{Space: nsCalDAV, Local: "calendar-home-set"},
+		)
+	}
+	return names
+}
+
+// response returns the response holding the requested properties of a
+// resource, the missing ones in a 404 propstat
+func (h *Handler) response(res *resource, names []element) (response, error) {
+	found := propstat{Status: statusLine(http.StatusOK)}
+	missing := propstat{Status: statusLine(http.StatusNotFound)}
+	for _, name := range names {
+		value, ok, err := h.property(res, name.XMLName)
+		if err != nil {
+			return response{}, err
+		}
+		if ok {
+			found.Prop.Values = append(found.Prop.Values, value)
+		} else {
+			missing.Prop.Values = append(missing.Prop.Values, element{XMLName: name.XMLName})
+		}
+	}
+
+	resp := response{Href: href(res.path)}
+	if len(found.Prop.Values) > 0 {
+		resp.Propstat = append(resp.Propstat, found)
+	}
+	if len(missing.Prop.Values) > 0 {
+		resp.Propstat = append(resp.Propstat, missing)
+	}
+	return resp, nil
+}
+
+// property returns the value of a property of a resource
+func (h *Handler) property(res *resource, name xml.Name) (element, bool, error) {
+	value := element{XMLName: name}
+
+	switch name {
+	case xml.Name{Space: nsDAV, Local: "resourcetype"}:
+		switch {
+		case res.object != nil:
+		case res.collection != nil:
+			value.Inner = `<collection xmlns="DAV:"/><calendar xmlns="urn:ietf:params:xml:ns:caldav"/>`
+		case res.path == collectionPath(h.Principal):
+			value.Inner = `<collection xmlns="DAV:"/><principal xmlns="DAV:"/>`
+		default:
+			value.Inner = `<collection xmlns="DAV:"/>`
+		}
+		return value, true, nil
+	case xml.Name{Space: nsDAV, Local: "current-user-principal"}:
+		return hrefElement(name.Space, name.Local, href(collectionPath(h.Principal))), true, nil
+	}
+
+	switch {
+	case res.object != nil:
+		o := res.object
+		switch name {
+		case xml.Name{Space: nsDAV, Local: "getetag"}:
+			return textElement(name.Space, name.Local, o.ETag), true, nil
+		case xml.Name{Space: nsDAV, Local: "getcontenttype"}:
+			return textElement(name.Space, name.Local, "text/calendar; charset=utf-8"), true, nil
+		case xml.Name{Space: nsDAV, Local: "getlastmodified"}:
+			if o.ModTime.IsZero() {
+				return value, false, nil
+			}
+			return textElement(name.Space, name.Local, o.ModTime.UTC().Format(http.TimeFormat)), true, nil
+		case xml.Name{Space: nsDAV, Local: "getcontentlength"}, xml.Name{Space: nsCalDAV, Local: "calendar-data"}:
+			var b bytes.Buffer
+			if err := ical.Encode(&b, o.Calendar); err != nil {
+				return value, false, err
+			}
+			if name.Local == "getcontentlength" {
+				return textElement(name.Space, name.Local, strconv.Itoa(b.Len())), true, nil
+			}
+			return textElement(name.Space, name.Local, b.String()), true, nil
+		}
+
+	case res.collection != nil:
+		coll := res.collection
+		switch name {
+		case xml.Name{Space: nsDAV, Local: "displayname"}:
+			return textElement(name.Space, name.Local, coll.Name), true, nil
+		case xml.Name{Space: nsCalDAV, Local: "calendar-description"}:
+			if coll.Description == "" {
+				return value, false, nil
+			}
+			return textElement(name.Space, name.Local, coll.Description), true, nil
+		case xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}:
+			components := coll.Components
+			if len(components) == 0 {
+				components = []string{"VEVENT", "VTODO", "VFREEBUSY"}
+			}
+			for _, c := range components {
+				var b bytes.Buffer
+				xml.EscapeText(&b, []byte(c))
+				value.Inner += `<comp xmlns="urn:ietf:params:xml:ns:caldav" name="` + b.String() + `"/>`
+			}
+			return value, true, nil
+		case xml.Name{Space: nsDAV, Local: "sync-token"}:
+			store, ok := h.Store.(SyncStore)
+			if !ok {
+				return value, false, nil
+			}
+			token, err := store.SyncToken(coll.Path)
+			if err != nil {
+				return value, false, err
+			}
+			return textElement(name.Space, name.Local, token), true, nil
+		}
+
+	default:
+		if name == (xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}) {
+			return hrefElement(name.Space, name.Local, href(collectionPath(h.HomeSet))), true, nil
+		}
+	}
+
+	return value, false, nil
+}
+
+// report answers a calendar-query, a calendar-multiget or a sync-collection
+// REPORT
+// from rfc4791-7
+func (h *Handler) report(w http.ResponseWriter, r *http.Request) error {
+	var root struct {
+		XMLName xml.Name
+	}
+	data, err := readBody(r)
+	if err != nil {
+		return err
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return newHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var ms multistatus
+	switch root.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		var req calendarQuery
+		if err := xml.Unmarshal(data, &req); err != nil {
+			return newHTTPError(http.StatusBadRequest, err.Error())
+		}
+		ms, err = h.calendarQuery(r, &req)
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		var req calendarMultiget
+		if err := xml.Unmarshal(data, &req); err != nil {
+			return newHTTPError(http.StatusBadRequest, err.Error())
+		}
+		ms, err = h.calendarMultiget(&req)
+	case xml.Name{Space: nsDAV, Local: "sync-collection"}:
+		var req syncCollection
+		if err := xml.Unmarshal(data, &req); err != nil {
+			return newHTTPError(http.StatusBadRequest, err.Error())
+		}
+		ms, err = h.syncCollection(r, &req)
+	default:
+		return preconditionError(nsDAV, "supported-report", "unsupported report "+root.XMLName.Local)
+	}
+	if err != nil {
+		return err
+	}
+
+	writeXML(w, http.StatusMultiStatus, ms)
+	return nil
+}
+
+// calendarQuery returns the objects of the target matching the filter
+// from rfc4791-7.8
+func (h *Handler) calendarQuery(r *http.Request, req *calendarQuery) (multistatus, error) {
+	var ms multistatus
+	res, err := h.resource(r.URL.Path)
+	if err != nil {
+		return ms, err
+	}
+	objects := []*resource{res}
+	if res.object == nil {
+		if objects, err = h.children(res); err != nil {
+			return ms, err
+		}
+	}
+
+	for _, o := range objects {
+		if o.object == nil {
+			continue
+		}
+		ok, err := matchFilter(req.Filter.CompFilter, o.object.Calendar)
+		if err != nil {
+			return ms, preconditionError(nsCalDAV, "valid-filter", err.Error())
+		}
+		if !ok {
+			continue
+		}
+		resp, err := h.response(o, reportProps(req.Prop))
+		if err != nil {
+			return ms, err
+		}
+		ms.Responses = append(ms.Responses, resp)
+	}
+	return ms, nil
+}
+
+// calendarMultiget returns the objects of the given hrefs
+// from rfc4791-7.9
+func (h *Handler) calendarMultiget(req *calendarMultiget) (multistatus, error) {
+	var ms multistatus
+	for _, ref := range req.Hrefs {
+		o, err := h.Store.Object(hrefPath(ref))
+		if err == ErrNotFound {
+			ms.Responses = append(ms.Responses, response{Href: strings.TrimSpace(ref), Status: statusLine(http.StatusNotFound)})
+			continue
+		}
+		if err != nil {
+			return ms, err
+		}
+		resp, err := h.response(&resource{path: o.Path, object: o}, reportProps(req.Prop))
+		if err != nil {
+			return ms, err
+		}
+		ms.Responses = append(ms.Responses, resp)
+	}
+	return ms, nil
+}
+
+// syncCollection returns the objects of a collection changed and deleted
+// since the sync token of the request, deleted ones with a 404 status
+// from rfc6578-3.2
+func (h *Handler) syncCollection(r *http.Request, req *syncCollection) (multistatus, error) {
+	var ms multistatus
+	store, ok := h.Store.(SyncStore)
+	if !ok {
+		return ms, preconditionError(nsDAV, "supported-report", "sync-collection not supported by the store")
+	}
+	if req.SyncLevel != "1" {
+		return ms, preconditionError(nsDAV, "supported-report", "only sync-level 1 is supported")
+	}
+	coll, err := h.Store.Collection(collectionPath(r.URL.Path))
+	if err != nil {
+		return ms, err
+	}
+
+	changed, deleted, token, err := store.Changes(coll.Path, strings.TrimSpace(req.SyncToken))
+	if err == ErrInvalidSyncToken {
+		return ms, preconditionError(nsDAV, "valid-sync-token", err.Error())
+	}
+	if err != nil {
+		return ms, err
+	}
+
+	for _, p := range changed {
+		o, err := h.Store.Object(p)
+		if err != nil {
+			return ms, err
+		}
+		resp, err := h.response(&resource{path: o.Path, object: o}, reportProps(req.Prop))
+		if err != nil {
+			return ms, err
+		}
+		ms.Responses = append(ms.Responses, resp)
+	}
+	for _, p := range deleted {
+		ms.Responses = append(ms.Responses, response{Href: href(p), Status: statusLine(http.StatusNotFound)})
+	}
+	ms.SyncToken = token
+	return ms, nil
+}
+
+// reportProps returns the properties asked by a report, DAV:getetag by default
+func reportProps(p *propNames) []element {
+	if p == nil {
+		return []element{{XMLName: xml.Name{Space: nsDAV, Local: "getetag"}}}
+	}
+	return p.Names
+}
@@ -0,0 +1,389 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iswangwenbin/ical"
+)
+
+// A Handler serves the collections of a Store over CalDAV. Request paths
+// are the paths of the Store.
+type Handler struct {
+	Store     Store
+	Principal string // Path of the current user principal, defaults to "/"
+	HomeSet   string // Path of the calendar home set, holding the collections, defaults to "/"
+}
+
+// NewHandler creates a Handler serving a Store
+func NewHandler(store Store) *Handler {
+	return &Handler{Store: store, Principal: "/", HomeSet: "/"}
+}
+
+// an httpError is an error answered with a status, and for a failed
+// precondition the element of the condition
+type httpError struct {
+	status    int
+	condition *xml.Name
+	message   string
+}
+
+func (e *httpError) Error() string {
+	return e.message
+}
+
+func newHTTPError(status int, message string) error {
+	return &httpError{status: status, message: message}
+}
+
+// preconditionError is a 403 error for a failed precondition
+// from rfc4791-1.3
+func preconditionError(space, local, message string) error {
+	return &httpError{status: http.StatusForbidden, condition: &xml.Name{Space: space, Local: local}, message: message}
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	switch r.Method {
+	case "OPTIONS":
+		h.options(w)
+	case "GET", "HEAD":
+		err = h.get(w, r)
+	case "PUT":
+		err = h.put(w, r)
+	case "DELETE":
+		err = h.delete(w, r)
+	case "PROPFIND":
+		err = h.propfind(w, r)
+	case "REPORT":
+		err = h.report(w, r)
+	case "MKCALENDAR":
+		err = h.mkcalendar(w, r)
+	default:
+		err = newHTTPError(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+// writeError answers an error, with an error element for a precondition
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*httpError)
+	switch {
+	case ok:
+	case err == ErrNotFound:
+		e = &httpError{status: http.StatusNotFound, message: err.Error()}
+	default:
+		e = &httpError{status: http.StatusInternalServerError, message: err.Error()}
+	}
+
+	if e.condition == nil {
+		http.Error(w, e.message, e.status)
+		return
+	}
+	body := struct {
+		XMLName   xml.Name `xml:"DAV: error"`
+		Condition element
+	}{Condition: element{XMLName: *e.condition}}
+	writeXML(w, e.status, body)
+}
+
+// writeXML answers an XML body
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// readBody reads the body of a request
+func readBody(r *http.Request) ([]byte, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return data, nil
+}
+
+// readXML decodes the XML body of a request, returning false for an empty body
+func readXML(r *http.Request, v interface{}) (bool, error) {
+	data, err := readBody(r)
+	if err != nil {
+		return false, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return false, nil
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return false, newHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return true, nil
+}
+
+func (h *Handler) options(w http.ResponseWriter) {
+	w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT, MKCALENDAR")
+	w.Header().Set("DAV", "1, 3, calendar-access")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) error {
+	o, err := h.Store.Object(r.URL.Path)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := ical.Encode(&b, o.Calendar); err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", o.ETag)
+	if !o.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", o.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if matchETag(r.Header.Get("If-None-Match"), o.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		b.WriteTo(w)
+	}
+	return nil
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) error {
+	p := r.URL.Path
+	if strings.HasSuffix(p, "/") {
+		return newHTTPError(http.StatusMethodNotAllowed, "cannot put a collection")
+	}
+	coll, err := h.Store.Collection(parentPath(p))
+	if err == ErrNotFound {
+		return newHTTPError(http.StatusConflict, "no calendar collection at "+parentPath(p))
+	}
+	if err != nil {
+		return err
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/calendar" {
+			return preconditionError(nsCalDAV, "supported-calendar-data", "unsupported media type "+contentType)
+		}
+	}
+	c, err := ical.Parse(r.Body, time.UTC)
+	if err != nil {
+		return preconditionError(nsCalDAV, "valid-calendar-data", err.Error())
+	}
+	uid, err := objectUID(c, coll)
+	if err != nil {
+		return err
+	}
+
+	existing, err := h.Store.Object(p)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+	if err := checkPreconditions(r, existing); err != nil {
+		return err
+	}
+
+	objects, err := h.Store.Objects(coll.Path)
+	if err != nil {
+		return err
+	}
+	for _, o := range objects {
+		if o.Path != p {
+			if other, _ := objectUID(o.Calendar, nil); other == uid {
+				return preconditionError(nsCalDAV, "no-uid-conflict", "uid already used by "+o.Path)
+			}
+		}
+	}
+
+	o, err := h.Store.PutObject(p, c)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", o.ETag)
+	if existing == nil {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	return nil
+}
+
+// objectUID checks that a calendar is a valid calendar object resource,
+// holding components with a single UID, and returns the UID. The components
+// must be supported by the collection, if any.
+// from rfc4791-4.1
+func objectUID(c *ical.Calendar, coll *Collection) (string, error) {
+	if c.Method != "" {
+		return "", preconditionError(nsCalDAV, "valid-calendar-object-resource", "a calendar object must not have a method")
+	}
+
+	var uids, types []string
+	for _, v := range c.Events {
+		uids = append(uids, v.UID)
+		types = append(types, "VEVENT")
+	}
+	for _, t := range c.Todos {
+		uids = append(uids, t.UID)
+		types = append(types, "VTODO")
+	}
+	for _, f := range c.FreeBusys {
+		uids = append(uids, f.UID)
+		types = append(types, "VFREEBUSY")
+	}
+	if len(uids) == 0 {
+		return "", preconditionError(nsCalDAV, "valid-calendar-object-resource", "no component in the calendar object")
+	}
+	for i := range uids {
+		if uids[i] == "" || uids[i] != uids[0] || types[i] != types[0] {
+			return "", preconditionError(nsCalDAV, "valid-calendar-object-resource", "the components of a calendar object must share the same type and uid")
+		}
+	}
+
+	if coll != nil && !supports(coll, types[0]) {
+		return "", preconditionError(nsCalDAV, "supported-calendar-component", types[0]+" not supported by "+coll.Path)
+	}
+	return uids[0], nil
+}
+
+// supports checks if a collection supports a component type
+func supports(coll *Collection, name string) bool {
+	if len(coll.Components) == 0 {
+		return true
+	}
+	for _, c := range coll.Components {
+		if strings.EqualFold(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreconditions checks the If-Match and If-None-Match headers against
+// the existing object, nil if there is none
+func checkPreconditions(r *http.Request, existing *Object) error {
+	etag := ""
+	if existing != nil {
+		etag = existing.ETag
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && (existing == nil || !matchETag(ifMatch, etag)) {
+		return newHTTPError(http.StatusPreconditionFailed, "etag mismatch")
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && existing != nil && matchETag(ifNoneMatch, etag) {
+		return newHTTPError(http.StatusPreconditionFailed, "resource already exists")
+	}
+	return nil
+}
+
+// matchETag checks if an If-Match or If-None-Match header holds an entity tag
+func matchETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || (etag != "" && strings.TrimPrefix(tag, "W/") == etag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) error {
+	p := r.URL.Path
+	if coll, err := h.Store.Collection(collectionPath(p)); err == nil {
+		// a collection has no entity tag, only matched by "*"
+		if err := checkPreconditions(r, &Object{Path: coll.Path}); err != nil {
+			return err
+		}
+		if err := h.Store.DeleteCollection(coll.Path); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	existing, err := h.Store.Object(p)
+	if err != nil {
+		return err
+	}
+	if err := checkPreconditions(r, existing); err != nil {
+		return err
+	}
+	if err := h.Store.DeleteObject(p); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) mkcalendar(w http.ResponseWriter, r *http.Request) error {
+	p := collectionPath(r.URL.Path)
+	if !strings.HasPrefix(p, collectionPath(h.HomeSet)) || p == collectionPath(h.HomeSet) {
+		return preconditionError(nsCalDAV, "calendar-collection-location-ok", "calendars must be created in "+h.HomeSet)
+	}
+	if _, err := h.Store.Collection(p); err == nil {
+		return newHTTPError(http.StatusMethodNotAllowed, "collection already exists")
+	}
+	if _, err := h.Store.Object(strings.TrimSuffix(p, "/")); err == nil {
+		return newHTTPError(http.StatusMethodNotAllowed, "resource already exists")
+	}
+
+	var req mkcalendar
+	if _, err := readXML(r, &req); err != nil {
+		return err
+	}
+	coll := Collection{
+		Path:        p,
+		Name:        req.Set.Prop.DisplayName,
+		Description: req.Set.Prop.Description,
+	}
+	if set := req.Set.Prop.ComponentSet; set != nil {
+		for _, comp := range set.Comps {
+			coll.Components = append(coll.Components, comp.Name)
+		}
+	}
+	if err := h.Store.CreateCollection(coll); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// collectionPath returns a path ending with a slash
+func collectionPath(p string) string {
+	if strings.HasSuffix(p, "/") {
+		return p
+	}
+	return p + "/"
+}
+
+// hrefPath returns the path of a DAV:href, which may be an absolute URL
+func hrefPath(href string) string {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return href
+	}
+	return u.Path
+}
+
+// href returns the DAV:href of a path
+func href(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
@@ -0,0 +1,218 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile("../fixtures/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// serve sends a request to the handler, headers given as name and value pairs
+func serve(h http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func decodeMultistatus(t *testing.T, w *httptest.ResponseRecorder) multistatus {
+	t.Helper()
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("expected status 207, got %d: %s", w.Code, w.Body)
+	}
+	var ms multistatus
+	if err := xml.Unmarshal(w.Body.Bytes(), &ms); err != nil {
+		t.Fatal(err)
+	}
+	return ms
+}
+
+func hrefs(ms multistatus) []string {
+	var hrefs []string
+	for _, resp := range ms.Responses {
+		hrefs = append(hrefs, resp.Href)
+	}
+	return hrefs
+}
+
+const mkcalendarBody = `<?xml version="1.0" encoding="utf-8"?>
+<C:mkcalendar xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:set>
+    <D:prop>
+      <D:displayname>Work</D:displayname>
+      <C:supported-calendar-component-set>
+        <C:comp name="VEVENT"/>
+      </C:supported-calendar-component-set>
+    </D:prop>
+  </D:set>
+</C:mkcalendar>`
+
+// newServer creates a handler with a work calendar holding the invitation
+func newServer(t *testing.T) *Handler {
+	t.Helper()
+	h := NewHandler(NewMemoryStore())
+	if w := serve(h, "MKCALENDAR", "/work/", mkcalendarBody); w.Code != http.StatusCreated {
+		t.Fatalf("MKCALENDAR: expected status 201, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(h, "PUT", "/work/meeting.ics", fixture(t, "invitation.ics"), "Content-Type", "text/calendar"); w.Code != http.StatusCreated {
+		t.Fatalf("PUT: expected status 201, got %d: %s", w.Code, w.Body)
+	}
+	return h
+}
+
+func TestHandlerObjects(t *testing.T) {
+	h := newServer(t)
+
+	w := serve(h, "GET", "/work/meeting.ics", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET: expected status 200, got %d", w.Code)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || !strings.Contains(w.Body.String(), "UID:meeting@example.com") {
+		t.Errorf("GET: unexpected etag %q or body %s", etag, w.Body)
+	}
+	if w := serve(h, "GET", "/work/meeting.ics", "", "If-None-Match", etag); w.Code != http.StatusNotModified {
+		t.Errorf("GET If-None-Match: expected status 304, got %d", w.Code)
+	}
+
+	update := strings.Replace(fixture(t, "invitation.ics"), "SUMMARY:Planning", "SUMMARY:Review", 1)
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers []string
+		status  int
+	}{
+		{"create only", "PUT", "/work/meeting.ics", update, []string{"If-None-Match", "*"}, http.StatusPreconditionFailed},
+		{"stale etag", "PUT", "/work/meeting.ics", update, []string{"If-Match", `"stale"`}, http.StatusPreconditionFailed},
+		{"uid conflict", "PUT", "/work/other.ics", update, nil, http.StatusForbidden},
+		{"no collection", "PUT", "/home/meeting.ics", update, nil, http.StatusConflict},
+		{"invalid data", "PUT", "/work/bad.ics", "BEGIN:VCALENDAR\r\n", nil, http.StatusForbidden},
+		{"unsupported component", "PUT", "/work/todo.ics", "BEGIN:VCALENDAR\r\nPRODID:-//test//EN\r\nVERSION:2.0\r\nBEGIN:VTODO\r\nUID:todo\r\nDTSTAMP:20190101T000000Z\r\nEND:VTODO\r\nEND:VCALENDAR\r\n", nil, http.StatusForbidden},
+		{"update", "PUT", "/work/meeting.ics", update, []string{"If-Match", etag}, http.StatusNoContent},
+		{"delete stale", "DELETE", "/work/meeting.ics", "", []string{"If-Match", etag}, http.StatusPreconditionFailed},
+		{"delete", "DELETE", "/work/meeting.ics", "", nil, http.StatusNoContent},
+		{"get deleted", "GET", "/work/meeting.ics", "", nil, http.StatusNotFound},
+		{"existing calendar", "MKCALENDAR", "/work/", "", nil, http.StatusMethodNotAllowed},
+		{"delete calendar stale", "DELETE", "/work/", "", []string{"If-Match", etag}, http.StatusPreconditionFailed},
+		{"delete calendar", "DELETE", "/work/", "", []string{"If-Match", "*"}, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		if w := serve(h, tt.method, tt.path, tt.body, tt.headers...); w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, w.Code, w.Body)
+		}
+	}
+}
+
+func TestHandlerPropfind(t *testing.T) {
+	h := newServer(t)
+
+	w := serve(h, "PROPFIND", "/", `<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:current-user-principal/><C:calendar-home-set/></D:prop>
+</D:propfind>`, "Depth", "0")
+	ms := decodeMultistatus(t, w)
+	if len(ms.Responses) != 1 || !strings.Contains(w.Body.String(), "calendar-home-set") {
+		t.Errorf("unexpected principal response %s", w.Body)
+	}
+
+	w = serve(h, "PROPFIND", "/", "", "Depth", "1")
+	ms = decodeMultistatus(t, w)
+	if got := strings.Join(hrefs(ms), " "); got != "/ /work/" {
+		t.Errorf("expected hrefs / /work/, got %s", got)
+	}
+	if !strings.Contains(w.Body.String(), "Work</displayname>") || !strings.Contains(w.Body.String(), `name="VEVENT"`) {
+		t.Errorf("missing calendar properties in %s", w.Body)
+	}
+
+	w = serve(h, "PROPFIND", "/work/", `<?xml version="1.0"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:getetag/><D:displayname/></D:prop></D:propfind>`, "Depth", "1")
+	ms = decodeMultistatus(t, w)
+	if got := strings.Join(hrefs(ms), " "); got != "/work/ /work/meeting.ics" {
+		t.Errorf("expected hrefs /work/ /work/meeting.ics, got %s", got)
+	}
+	object := ms.Responses[1]
+	if len(object.Propstat) != 2 || object.Propstat[1].Status != "HTTP/1.1 404 Not Found" {
+		t.Errorf("expected displayname of the object not found, got %+v", object.Propstat)
+	}
+}
+
+func TestHandlerReport(t *testing.T) {
+	h := newServer(t)
+
+	query := func(filter string) string {
+		return `<?xml version="1.0"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <C:filter><C:comp-filter name="VCALENDAR">` + filter + `</C:comp-filter></C:filter>
+</C:calendar-query>`
+	}
+
+	tests := []struct {
+		name    string
+		filter  string
+		matches int
+	}{
+		{"any event", `<C:comp-filter name="VEVENT"/>`, 1},
+		{"no todo", `<C:comp-filter name="VTODO"/>`, 0},
+		{"todo not defined", `<C:comp-filter name="VTODO"><C:is-not-defined/></C:comp-filter>`, 1},
+		{"third occurrence", `<C:comp-filter name="VEVENT"><C:time-range start="20190124T000000Z" end="20190125T000000Z"/></C:comp-filter>`, 1},
+		{"between occurrences", `<C:comp-filter name="VEVENT"><C:time-range start="20190125T000000Z" end="20190131T000000Z"/></C:comp-filter>`, 0},
+		{"after the last occurrence", `<C:comp-filter name="VEVENT"><C:time-range start="20190201T000000Z"/></C:comp-filter>`, 0},
+		{"alarm", `<C:comp-filter name="VEVENT"><C:comp-filter name="VALARM"><C:time-range start="20190117T094500Z" end="20190117T095500Z"/></C:comp-filter></C:comp-filter>`, 1},
+		{"no alarm", `<C:comp-filter name="VEVENT"><C:comp-filter name="VALARM"><C:time-range start="20190117T100000Z" end="20190117T110000Z"/></C:comp-filter></C:comp-filter>`, 0},
+	}
+
+	for _, tt := range tests {
+		w := serve(h, "REPORT", "/work/", query(tt.filter), "Depth", "1")
+		ms := decodeMultistatus(t, w)
+		if len(ms.Responses) != tt.matches {
+			t.Errorf("%s: expected %d matches, got %d", tt.name, tt.matches, len(ms.Responses))
+		}
+		if tt.matches > 0 && !strings.Contains(w.Body.String(), "BEGIN:VCALENDAR") {
+			t.Errorf("%s: missing calendar-data in %s", tt.name, w.Body)
+		}
+	}
+
+	// a series without end overlaps any time-range without end
+	standup := strings.NewReplacer("UID:meeting@example.com", "UID:standup@example.com", "RRULE:FREQ=WEEKLY;COUNT=4", "RRULE:FREQ=WEEKLY").Replace(fixture(t, "invitation.ics"))
+	if w := serve(h, "PUT", "/work/standup.ics", standup, "Content-Type", "text/calendar"); w.Code != http.StatusCreated {
+		t.Fatalf("PUT: expected status 201, got %d: %s", w.Code, w.Body)
+	}
+	for _, filter := range []string{
+		`<C:comp-filter name="VEVENT"><C:time-range start="20900101T000000Z"/></C:comp-filter>`,
+		`<C:comp-filter name="VEVENT"><C:comp-filter name="VALARM"><C:time-range start="20900101T000000Z"/></C:comp-filter></C:comp-filter>`,
+	} {
+		ms := decodeMultistatus(t, serve(h, "REPORT", "/work/", query(filter), "Depth", "1"))
+		if got := strings.Join(hrefs(ms), " "); got != "/work/standup.ics" {
+			t.Errorf("expected the series without end to match, got %s", got)
+		}
+	}
+
+	w := serve(h, "REPORT", "/work/", `<?xml version="1.0"?>
+<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/></D:prop>
+  <D:href>/work/meeting.ics</D:href>
+  <D:href>/work/missing.ics</D:href>
+</C:calendar-multiget>`)
+	ms := decodeMultistatus(t, w)
+	if len(ms.Responses) != 2 || len(ms.Responses[0].Propstat) != 1 || ms.Responses[1].Status != "HTTP/1.1 404 Not Found" {
+		t.Errorf("unexpected multiget response %s", w.Body)
+	}
+}
@@ -0,0 +1,298 @@
+// Package caldav serves and accesses calendars over CalDAV, from rfc4791.
+package caldav
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iswangwenbin/ical"
+)
+
+// ErrNotFound is returned by a Store when a collection or an object doesn't exist
+var ErrNotFound = errors.New("caldav: not found")
+
+// A Collection is a calendar collection
+type Collection struct {
+	Path        string   // Path of the collection, ending with a slash
+	Name        string   // Display name
+	Description string   // Description of the calendar
+	Components  []string // Supported component types, like VEVENT and VTODO, all of them if empty
+}
+
+// An Object is a calendar object resource, an .ics file within a collection
+type Object struct {
+	Path     string
+	ETag     string // Strong entity tag, quoted
+	ModTime  time.Time
+	Calendar *ical.Calendar
+}
+
+// A Store holds the collections and the objects served by a Handler.
+// Methods return ErrNotFound for a missing collection or object.
+type Store interface {
+	Collections() ([]Collection, error)
+	Collection(path string) (*Collection, error)
+	CreateCollection(c Collection) error
+	DeleteCollection(path string) error
+	Objects(collection string) ([]Object, error)
+	Object(path string) (*Object, error)
+	PutObject(path string, c *ical.Calendar) (*Object, error)
+	DeleteObject(path string) error
+}
+
+// A SyncStore is a Store tracking the changes of its collections, for
+// sync-collection reports. A sync token is an URI standing for the state of
+// the store at some point.
+// from rfc6578
+type SyncStore interface {
+	Store
+	// SyncToken returns the current sync token of a collection
+	SyncToken(collection string) (string, error)
+	// Changes returns the paths of the objects of a collection changed and
+	// deleted since a sync token, and the current token. Every object is
+	// changed since an empty token. It returns ErrInvalidSyncToken for an
+	// unknown token.
+	Changes(collection, token string) (changed, deleted []string, current string, err error)
+}
+
+// ErrInvalidSyncToken is returned by a SyncStore for an unknown sync token,
+// and by a Client for a sync token rejected by the server
+var ErrInvalidSyncToken = errors.New("caldav: invalid sync token")
+
+// syncTokenPrefix is the prefix of the sync tokens of a MemoryStore, followed
+// by a revision
+const syncTokenPrefix = "data:,"
+
+// MemoryStore is a SyncStore keeping everything in memory
+type MemoryStore struct {
+	mu          sync.Mutex
+	collections map[string]Collection
+	objects     map[string]memoryObject
+	deleted     map[string]int // Revision of the deletion of objects
+	revision    int
+}
+
+type memoryObject struct {
+	data     []byte
+	etag     string
+	modTime  time.Time
+	revision int
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		collections: make(map[string]Collection),
+		objects:     make(map[string]memoryObject),
+		deleted:     make(map[string]int),
+	}
+}
+
+// Collections returns the collections, sorted by path
+func (s *MemoryStore) Collections() ([]Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collections := make([]Collection, 0, len(s.collections))
+	for _, c := range s.collections {
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].Path < collections[j].Path
+	})
+	return collections, nil
+}
+
+// Collection returns a collection
+func (s *MemoryStore) Collection(path string) (*Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[path]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
+
+// CreateCollection creates or replaces a collection
+func (s *MemoryStore) CreateCollection(c Collection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.collections[c.Path] = c
+	return nil
+}
+
+// DeleteCollection deletes a collection and its objects
+func (s *MemoryStore) DeleteCollection(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[path]; !ok {
+		return ErrNotFound
+	}
+	delete(s.collections, path)
+	for p := range s.objects {
+		if parentPath(p) == path {
+			delete(s.objects, p)
+		}
+	}
+	for p := range s.deleted {
+		if parentPath(p) == path {
+			delete(s.deleted, p)
+		}
+	}
+	return nil
+}
+
+// Objects returns the objects of a collection, sorted by path
+func (s *MemoryStore) Objects(collection string) ([]Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[collection]; !ok {
+		return nil, ErrNotFound
+	}
+
+	var paths []string
+	for p := range s.objects {
+		if parentPath(p) == collection {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	objects := make([]Object, 0, len(paths))
+	for _, p := range paths {
+		o, err := s.objects[p].object(p)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, *o)
+	}
+	return objects, nil
+}
+
+// Object returns an object
+func (s *MemoryStore) Object(path string) (*Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.objects[path]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return o.object(path)
+}
+
+// PutObject creates or replaces an object, the calendar is stored encoded
+func (s *MemoryStore) PutObject(path string, c *ical.Calendar) (*Object, error) {
+	var b bytes.Buffer
+	if err := ical.Encode(&b, c); err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(b.Bytes())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[parentPath(path)]; !ok {
+		return nil, ErrNotFound
+	}
+	s.revision++
+	o := memoryObject{
+		data:     b.Bytes(),
+		etag:     `"` + hex.EncodeToString(sum[:]) + `"`,
+		modTime:  time.Now().UTC(),
+		revision: s.revision,
+	}
+	s.objects[path] = o
+	delete(s.deleted, path)
+	return o.object(path)
+}
+
+// DeleteObject deletes an object
+func (s *MemoryStore) DeleteObject(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.objects[path]; !ok {
+		return ErrNotFound
+	}
+	delete(s.objects, path)
+	s.revision++
+	s.deleted[path] = s.revision
+	return nil
+}
+
+// SyncToken returns the current sync token, the same for every collection
+func (s *MemoryStore) SyncToken(collection string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[collection]; !ok {
+		return "", ErrNotFound
+	}
+	return syncTokenPrefix + strconv.Itoa(s.revision), nil
+}
+
+// Changes returns the objects of a collection changed and deleted since a
+// sync token, sorted by path
+func (s *MemoryStore) Changes(collection, token string) (changed, deleted []string, current string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.collections[collection]; !ok {
+		return nil, nil, "", ErrNotFound
+	}
+	since := 0
+	if token != "" {
+		since, err = strconv.Atoi(strings.TrimPrefix(token, syncTokenPrefix))
+		if err != nil || !strings.HasPrefix(token, syncTokenPrefix) || since < 0 || since > s.revision {
+			return nil, nil, "", ErrInvalidSyncToken
+		}
+	}
+
+	for p, o := range s.objects {
+		if parentPath(p) == collection && o.revision > since {
+			changed = append(changed, p)
+		}
+	}
+	if token != "" {
+		for p, revision := range s.deleted {
+			if parentPath(p) == collection && revision > since {
+				deleted = append(deleted, p)
+			}
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(deleted)
+	return changed, deleted, syncTokenPrefix + strconv.Itoa(s.revision), nil
+}
+
+// object parses a stored object, so that callers never share a calendar
+func (o memoryObject) object(path string) (*Object, error) {
+	c, err := ical.Parse(bytes.NewReader(o.data), time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Path: path, ETag: o.etag, ModTime: o.modTime, Calendar: c}, nil
+}
+
+// parentPath returns the path of the collection holding a resource
+func parentPath(p string) string {
+	dir := path.Dir(strings.TrimSuffix(p, "/"))
+	if dir == "/" {
+		return dir
+	}
+	return dir + "/"
+}
@@ -0,0 +1,172 @@
+package caldav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// XML namespaces
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+)
+
+// element is any XML element, kept as raw inner XML
+type element struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+// textElement creates an element holding escaped text
+func textElement(space, local, text string) element {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(text))
+	return element{XMLName: xml.Name{Space: space, Local: local}, Inner: b.String()}
+}
+
+// hrefElement creates an element holding a DAV:href
+func hrefElement(space, local, href string) element {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(href))
+	return element{XMLName: xml.Name{Space: space, Local: local}, Inner: `<href xmlns="DAV:">` + b.String() + `</href>`}
+}
+
+// propNames is a DAV:prop element of a request, listing the requested properties
+type propNames struct {
+	Names []element `xml:",any"`
+}
+
+// propfind is the body of a PROPFIND request
+// from rfc4918-14.20
+type propfind struct {
+	XMLName  xml.Name   `xml:"DAV: propfind"`
+	AllProp  *struct{}  `xml:"DAV: allprop"`
+	PropName *struct{}  `xml:"DAV: propname"`
+	Prop     *propNames `xml:"DAV: prop"`
+}
+
+// multistatus is the body of a 207 Multi-Status response
+// from rfc4918-14.16
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"response"`
+	SyncToken string     `xml:"sync-token,omitempty"`
+}
+
+type response struct {
+	Href     string     `xml:"href"`
+	Status   string     `xml:"status,omitempty"`
+	Propstat []propstat `xml:"propstat,omitempty"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"prop"`
+	Status string `xml:"status"`
+}
+
+type prop struct {
+	Values []element `xml:",any"`
+}
+
+// compFilter is a CALDAV:comp-filter element
+// from rfc4791-9.7.1
+type compFilter struct {
+	Name         string       `xml:"name,attr"`
+	IsNotDefined *struct{}    `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	CompFilters  []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// timeRange is a CALDAV:time-range element
+// from rfc4791-9.9
+type timeRange struct {
+	Start string `xml:"start,attr,omitempty"`
+	End   string `xml:"end,attr,omitempty"`
+}
+
+// calendarQuery is the body of a calendar-query REPORT
+// from rfc4791-9.5
+type calendarQuery struct {
+	XMLName xml.Name   `xml:"urn:ietf:params:xml:ns:caldav calendar-query"`
+	Prop    *propNames `xml:"DAV: prop"`
+	Filter  struct {
+		CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// calendarMultiget is the body of a calendar-multiget REPORT
+// from rfc4791-9.10
+type calendarMultiget struct {
+	XMLName xml.Name   `xml:"urn:ietf:params:xml:ns:caldav calendar-multiget"`
+	Prop    *propNames `xml:"DAV: prop"`
+	Hrefs   []string   `xml:"DAV: href"`
+}
+
+// syncCollection is the body of a sync-collection REPORT
+// from rfc6578-6.1
+type syncCollection struct {
+	XMLName   xml.Name   `xml:"DAV: sync-collection"`
+	SyncToken string     `xml:"DAV: sync-token"`
+	SyncLevel string     `xml:"DAV: sync-level"`
+	Prop      *propNames `xml:"DAV: prop"`
+}
+
+// mkcalendar is the body of a MKCALENDAR request
+// from rfc4791-5.3.1
+type mkcalendar struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav mkcalendar"`
+	Set     struct {
+		Prop struct {
+			DisplayName  string `xml:"DAV: displayname"`
+			Description  string `xml:"urn:ietf:params:xml:ns:caldav calendar-description"`
+			ComponentSet *struct {
+				Comps []struct {
+					Name string `xml:"name,attr"`
+				} `xml:"urn:ietf:params:xml:ns:caldav comp"`
+			} `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+		} `xml:"DAV: prop"`
+	} `xml:"DAV: set"`
+}
+
+// statusLine formats a status for a DAV:status element
+func statusLine(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+// davResponse is a multistatus response as read by the Client, with the
+// properties it asks for
+type davResponse struct {
+	XMLName   xml.Name `xml:"DAV: multistatus"`
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Status   string `xml:"DAV: status"`
+		Propstat []struct {
+			Status string   `xml:"DAV: status"`
+			Prop   davProps `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+	SyncToken string `xml:"DAV: sync-token"`
+}
+
+type davProps struct {
+	ResourceType struct {
+		Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+	} `xml:"DAV: resourcetype"`
+	CurrentUserPrincipal davHref `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      davHref `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	DisplayName          string  `xml:"DAV: displayname"`
+	Description          string  `xml:"urn:ietf:params:xml:ns:caldav calendar-description"`
+	ComponentSet         struct {
+		Comps []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"urn:ietf:params:xml:ns:caldav comp"`
+	} `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+	ETag         string `xml:"DAV: getetag"`
+	CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+type davHref struct {
+	Href string `xml:"DAV: href"`
+}
@@ -0,0 +1,58 @@
+package ical
+
+import (
+	"sort"
+	"time"
+)
+
+// A Conflict represents two overlapping occurrences, A starting first
+type Conflict struct {
+	A Occurrence
+	B Occurrence
+}
+
+// Conflicts returns the pairs of overlapping occurrences within the window,
+// among the events of the calendar and of the other calendars, recurring
+// events expanded. Transparent and cancelled events never conflict, and the
+// same instance found in several calendars is not a conflict.
+func (c *Calendar) Conflicts(window Period, others ...*Calendar) []Conflict {
+	var occurrences []Occurrence
+	for _, cal := range append([]*Calendar{c}, others...) {
+		for _, o := range cal.EventsBetween(window.Start, window.End) {
+			if _, ok := busyStatus(o.Event, ""); ok {
+				occurrences = append(occurrences, o)
+			}
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+
+	var conflicts []Conflict
+	for i, a := range occurrences {
+		for _, b := range occurrences[i+1:] {
+			if !b.Start.Before(a.End) {
+				break
+			}
+			if !a.Start.Before(b.End) || sameInstance(a, b) {
+				continue
+			}
+			conflicts = append(conflicts, Conflict{A: a, B: b})
+		}
+	}
+	return conflicts
+}
+
+// sameInstance checks if two occurrences are the same instance of an event
+func sameInstance(a, b Occurrence) bool {
+	return a.Event.UID != "" && a.Event.UID == b.Event.UID && a.Start.Equal(b.Start) && recurrenceID(a).Equal(recurrenceID(b))
+}
+
+// recurrenceID returns the original start of an occurrence
+func recurrenceID(o Occurrence) time.Time {
+	if !o.Event.RecurrenceID.IsZero() {
+		return o.Event.RecurrenceID
+	}
+	return o.Start
+}
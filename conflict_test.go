@@ -0,0 +1,48 @@
+package ical
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCalendarConflicts(t *testing.T) {
+	file, _ := os.Open("fixtures/recurring.ics")
+	work, err := Parse(file, time.UTC)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paris, _ := time.LoadLocation("Europe/Paris")
+	at := func(d, h, m int) time.Time {
+		return time.Date(2019, time.January, d, h, m, 0, 0, paris)
+	}
+
+	personal := NewCalendar()
+	dentist := NewEvent()
+	dentist.UID = "dentist@test"
+	dentist.StartDate, dentist.EndDate = at(16, 9, 0), at(16, 10, 0)
+	gym := NewEvent()
+	gym.UID = "gym@test"
+	gym.StartDate, gym.EndDate = at(14, 9, 0), at(14, 10, 0) // the standup was moved
+	shared := *work.Events[1]                                // the same meeting in both feeds
+	cancelled := NewEvent()
+	cancelled.UID = "cancelled@test"
+	cancelled.StartDate, cancelled.EndDate = at(14, 16, 0), at(14, 17, 0)
+	setProperty("STATUS", "CANCELLED", &cancelled.Properties)
+	personal.Events = append(personal.Events, dentist, gym, &shared, cancelled)
+
+	window := Period{Start: at(12, 0, 0), End: at(19, 0, 0)}
+	got := personal.Conflicts(window, work)
+
+	if len(got) != 1 {
+		for _, c := range got {
+			t.Logf("%s %v / %s %v", c.A.Event.UID, c.A.Start, c.B.Event.UID, c.B.Start)
+		}
+		t.Fatalf("got %d conflicts want 1", len(got))
+	}
+	if got[0].A.Event != dentist || got[0].B.Event.Summary != "Standup" || !got[0].B.Start.Equal(at(16, 9, 30)) {
+		t.Errorf("got conflict between %s at %v and %s at %v", got[0].A.Event.UID, got[0].A.Start, got[0].B.Event.UID, got[0].B.Start)
+	}
+}
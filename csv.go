@@ -0,0 +1,407 @@
+package ical
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// A CSVField is the field of an event held by a CSV column
+type CSVField string
+
+// Fields of the CSV columns. Start and end are a date and a time, or a date
+// alone for an all-day event, unless split in a date and a time column.
+const (
+	CSVSummary     CSVField = "summary"
+	CSVStart       CSVField = "start"
+	CSVStartDate   CSVField = "start-date"
+	CSVStartTime   CSVField = "start-time"
+	CSVEnd         CSVField = "end"
+	CSVEndDate     CSVField = "end-date"
+	CSVEndTime     CSVField = "end-time"
+	CSVAllDay      CSVField = "all-day"
+	CSVLocation    CSVField = "location"
+	CSVAttendees   CSVField = "attendees"
+	CSVCategories  CSVField = "categories"
+	CSVDescription CSVField = "description"
+	CSVUID         CSVField = "uid"
+)
+
+// A CSVColumn maps the column of a CSV file with this header to a field
+type CSVColumn struct {
+	Header string
+	Field  CSVField
+}
+
+// A CSVMapping is the layout of a CSV file of events, one event per row
+// after a header row
+type CSVMapping struct {
+	Columns    []CSVColumn
+	DateLayout string         // Layout of the dates, "2006-01-02" by default
+	TimeLayout string         // Layout of the times, "15:04" by default
+	Separator  string         // Separator of attendees and categories, ";" by default
+	Comma      rune           // Field delimiter, ',' by default
+	Location   *time.Location // Location of the times, as they are when exported and the system location when imported if not set
+}
+
+// DefaultCSV is a CSV mapping of the main fields of events
+var DefaultCSV = CSVMapping{
+	Columns: []CSVColumn{
+		{"Summary", CSVSummary},
+		{"Start", CSVStart},
+		{"End", CSVEnd},
+		{"All Day", CSVAllDay},
+		{"Location", CSVLocation},
+		{"Attendees", CSVAttendees},
+		{"Categories", CSVCategories},
+	},
+}
+
+// OutlookCSV is the CSV mapping of the calendar exports of Outlook
+var OutlookCSV = CSVMapping{
+	Columns: []CSVColumn{
+		{"Subject", CSVSummary},
+		{"Start Date", CSVStartDate},
+		{"Start Time", CSVStartTime},
+		{"End Date", CSVEndDate},
+		{"End Time", CSVEndTime},
+		{"All day event", CSVAllDay},
+		{"Required Attendees", CSVAttendees},
+		{"Categories", CSVCategories},
+		{"Description", CSVDescription},
+		{"Location", CSVLocation},
+	},
+	DateLayout: "1/2/2006",
+	TimeLayout: "3:04:05 PM",
+}
+
+// GoogleCSV is the CSV mapping of the imports of Google Calendar
+var GoogleCSV = CSVMapping{
+	Columns: []CSVColumn{
+		{"Subject", CSVSummary},
+		{"Start Date", CSVStartDate},
+		{"Start Time", CSVStartTime},
+		{"End Date", CSVEndDate},
+		{"End Time", CSVEndTime},
+		{"All Day Event", CSVAllDay},
+		{"Description", CSVDescription},
+		{"Location", CSVLocation},
+	},
+	DateLayout: "1/2/2006",
+	TimeLayout: "3:04 PM",
+}
+
+// Encode writes the events in CSV, a row for each event as it is,
+// recurring or not. The end of an all-day event is its last day, as
+// spreadsheets and calendar imports expect.
+func (m CSVMapping) Encode(w io.Writer, events []*Event) error {
+	occurrences := make([]Occurrence, 0, len(events))
+	for _, v := range events {
+		occurrences = append(occurrences, v.occurrence(v.StartDate))
+	}
+	return m.EncodeOccurrences(w, occurrences)
+}
+
+// EncodeOccurrences writes occurrences in CSV, such as the ones of
+// Calendar.EventsBetween, a row for each occurrence
+func (m CSVMapping) EncodeOccurrences(w io.Writer, occurrences []Occurrence) error {
+	cw := csv.NewWriter(w)
+	if m.Comma != 0 {
+		cw.Comma = m.Comma
+	}
+
+	header := make([]string, len(m.Columns))
+	for i, column := range m.Columns {
+		header[i] = column.Header
+	}
+	cw.Write(header)
+	for _, o := range occurrences {
+		record := make([]string, len(m.Columns))
+		for i, column := range m.Columns {
+			record[i] = m.format(o, column.Field)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// format returns the value of a field of an occurrence
+func (m CSVMapping) format(o Occurrence, field CSVField) string {
+	v := o.Event
+	start, end := o.Start, o.End
+	if m.Location != nil && !v.AllDay {
+		start, end = start.In(m.Location), end.In(m.Location)
+	}
+	if v.AllDay && end.After(start) {
+		// the last day rather than the day after it
+		end = end.AddDate(0, 0, -1)
+	}
+	if end.Before(start) {
+		end = start
+	}
+
+	switch field {
+	case CSVSummary:
+		return unescapeText(v.Summary)
+	case CSVDescription:
+		return unescapeText(v.Description)
+	case CSVUID:
+		return v.UID
+	case CSVStart:
+		return m.formatWhen(start, v.AllDay)
+	case CSVEnd:
+		return m.formatWhen(end, v.AllDay)
+	case CSVStartDate:
+		return start.Format(m.dateLayout())
+	case CSVEndDate:
+		return end.Format(m.dateLayout())
+	case CSVStartTime, CSVEndTime:
+		if v.AllDay {
+			return ""
+		}
+		if field == CSVStartTime {
+			return start.Format(m.timeLayout())
+		}
+		return end.Format(m.timeLayout())
+	case CSVAllDay:
+		if v.AllDay {
+			return "True"
+		}
+		return "False"
+	case CSVLocation:
+		if prop := getProperty("LOCATION", v.Properties); prop != nil {
+			return unescapeText(prop.Value)
+		}
+	case CSVAttendees:
+		var attendees []string
+		for _, prop := range v.Properties {
+			if prop.Name == "ATTENDEE" {
+				attendees = append(attendees, strings.TrimPrefix(prop.Value, "mailto:"))
+			}
+		}
+		return strings.Join(attendees, m.separator())
+	case CSVCategories:
+		var categories []string
+		for _, prop := range v.Properties {
+			if prop.Name == "CATEGORIES" {
+				categories = append(categories, splitText(prop.Value, ',')...)
+			}
+		}
+		return strings.Join(categories, m.separator())
+	}
+	return ""
+}
+
+// formatWhen formats a start or an end, a date alone for an all-day event
+func (m CSVMapping) formatWhen(t time.Time, allDay bool) string {
+	if allDay {
+		return t.Format(m.dateLayout())
+	}
+	return t.Format(m.dateLayout() + " " + m.timeLayout())
+}
+
+// Decode reads events in CSV into a new calendar. Columns are found by
+// their header, case insensitively, and the others are ignored. An event
+// is all-day when marked so or when its start has no time; its end date is
+// its last day, a day after its start when missing. Other events without
+// end last no time. Events are given a new UID
+// unless read from a column. The calendar holds a VTIMEZONE for the
+// location of the times.
+func (m CSVMapping) Decode(r io.Reader) (*Calendar, error) {
+	cr := csv.NewReader(r)
+	if m.Comma != 0 {
+		cr.Comma = m.Comma
+	}
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no CSV header found")
+	}
+
+	headers := make(map[string]int)
+	for i, header := range records[0] {
+		header = strings.TrimPrefix(header, "\ufeff")
+		headers[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+	columns := make(map[CSVField]int)
+	for _, column := range m.Columns {
+		if i, ok := headers[strings.ToLower(column.Header)]; ok {
+			columns[column.Field] = i
+		}
+	}
+	_, hasStart := columns[CSVStart]
+	_, hasStartDate := columns[CSVStartDate]
+	if !hasStart && !hasStartDate {
+		return nil, fmt.Errorf("no start column found in the CSV header")
+	}
+
+	c := NewCalendar()
+	c.Prodid = ProdID
+	c.Version = "2.0"
+	setProperty("PRODID", c.Prodid, &c.Properties)
+	setProperty("VERSION", c.Version, &c.Properties)
+
+	loc := m.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	for n, record := range records[1:] {
+		value := func(field CSVField) string {
+			if i, ok := columns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		v, err := m.event(value, loc)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+2, err)
+		}
+		if err := decodeEvent(v, c.Method, loc); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+2, err)
+		}
+		c.Events = append(c.Events, v)
+	}
+	if err := addTimezones(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// event creates the event of a row from the values of its fields
+func (m CSVMapping) event(value func(CSVField) string, loc *time.Location) (*Event, error) {
+	start, dateOnly, err := m.parseWhen(value(CSVStart), value(CSVStartDate), value(CSVStartTime), loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %v", err)
+	}
+	if start.IsZero() {
+		return nil, fmt.Errorf("no start")
+	}
+	end, _, err := m.parseWhen(value(CSVEnd), value(CSVEndDate), value(CSVEndTime), loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end: %v", err)
+	}
+
+	allDay := dateOnly || parseCSVBool(value(CSVAllDay))
+	if allDay {
+		y, mo, d := start.Date()
+		start = time.Date(y, mo, d, 0, 0, 0, 0, loc)
+		if end.IsZero() {
+			end = start
+		} else {
+			y, mo, d = end.Date()
+			end = time.Date(y, mo, d, 0, 0, 0, 0, loc)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("end before start")
+		}
+		// DTEND is the day after the last day
+		end = end.AddDate(0, 0, 1)
+	} else if end.IsZero() {
+		end = start
+	} else if end.Before(start) {
+		return nil, fmt.Errorf("end before start")
+	}
+
+	v := NewEvent()
+	uid := value(CSVUID)
+	if uid == "" {
+		uid = newUID()
+	}
+	setProperty("UID", uid, &v.Properties)
+	setProperty("DTSTAMP", formatDateTime(time.Now()), &v.Properties)
+	v.Properties = append(v.Properties, dateProperties("DTSTART", []time.Time{start}, allDay, false)...)
+	if end.After(start) {
+		v.Properties = append(v.Properties, dateProperties("DTEND", []time.Time{end}, allDay, false)...)
+	}
+	for _, prop := range []struct {
+		name  string
+		field CSVField
+	}{{"SUMMARY", CSVSummary}, {"DESCRIPTION", CSVDescription}, {"LOCATION", CSVLocation}} {
+		if text := value(prop.field); text != "" {
+			setProperty(prop.name, escapeText(text), &v.Properties)
+		}
+	}
+	for _, attendee := range m.split(value(CSVAttendees)) {
+		if !strings.Contains(attendee, ":") {
+			attendee = "mailto:" + attendee
+		}
+		v.Properties = append(v.Properties, &Property{Name: "ATTENDEE", Value: attendee, Params: make(map[string]*Param)})
+	}
+	if categories := m.split(value(CSVCategories)); len(categories) > 0 {
+		for i, category := range categories {
+			categories[i] = escapeText(category)
+		}
+		setProperty("CATEGORIES", strings.Join(categories, ","), &v.Properties)
+	}
+	return v, nil
+}
+
+// parseWhen parses a start or an end, from a single column or from a date
+// and a time column, and checks if it is a date alone
+func (m CSVMapping) parseWhen(when, date, clock string, loc *time.Location) (time.Time, bool, error) {
+	if when == "" {
+		when = strings.TrimSpace(date + " " + clock)
+	}
+	if when == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.ParseInLocation(m.dateLayout()+" "+m.timeLayout(), when, loc); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation(m.dateLayout(), when, loc)
+	if err != nil {
+		return t, false, fmt.Errorf("%q doesn't match %q", when, m.dateLayout()+" "+m.timeLayout())
+	}
+	return t, true, nil
+}
+
+// split splits a list of attendees or categories
+func (m CSVMapping) split(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, m.separator()) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func (m CSVMapping) dateLayout() string {
+	if m.DateLayout == "" {
+		return "2006-01-02"
+	}
+	return m.DateLayout
+}
+
+func (m CSVMapping) timeLayout() string {
+	if m.TimeLayout == "" {
+		return "15:04"
+	}
+	return m.TimeLayout
+}
+
+func (m CSVMapping) separator() string {
+	if m.Separator == "" {
+		return ";"
+	}
+	return m.Separator
+}
+
+// parseCSVBool checks if a cell is checked, as spreadsheets write it
+func parseCSVBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "x", "1":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVEncode(t *testing.T) {
+	c := parseFixture(t, "fixtures/invitation.ics")
+	setProperty("CATEGORIES", "Work,Q1\\, planning", &c.Events[0].Properties)
+
+	var b bytes.Buffer
+	if err := DefaultCSV.Encode(&b, c.Events); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Summary,Start,End,All Day,Location,Attendees,Categories\n" +
+		"Planning,2019-01-10 10:00,2019-01-10 11:00,False,Room 1,alice@example.com;bob@example.com;carol@example.com,\"Work;Q1, planning\"\n"
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+
+	c = parseFixture(t, "fixtures/recurring.ics")
+	paris, _ := time.LoadLocation("Europe/Paris")
+	from, to := time.Date(2019, 1, 7, 0, 0, 0, 0, paris), time.Date(2019, 1, 12, 0, 0, 0, 0, paris)
+	b.Reset()
+	if err := OutlookCSV.EncodeOccurrences(&b, c.EventsBetween(from, to)); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	expectedLines := []string{
+		"Subject,Start Date,Start Time,End Date,End Time,All day event,Required Attendees,Categories,Description,Location",
+		"Offsite,1/4/2019,,1/7/2019,,True,,,,",
+		"Standup,1/7/2019,9:30:00 AM,1/7/2019,9:45:00 AM,False,,,,",
+		"Day off,1/10/2019,,1/10/2019,,True,,,,",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expectedLines, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expectedLines, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestCSVDecode(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	data := "\ufeffSubject,Start Date,Start Time,End Date,End Time,All day event,Required Attendees,Private\n" +
+		"Budget review,3/4/2019,2:00:00 PM,3/4/2019,3:30:00 PM,False,alice@example.com; bob@example.com,False\n" +
+		",,,,,,,\n" +
+		"Closing,3/29/2019,,3/29/2019,,True,,False\n" +
+		"Offsite,3/1/2019,,3/2/2019,,True,,False\n"
+	m := OutlookCSV
+	m.Location = paris
+	c, err := m.Decode(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(c.Events))
+	}
+
+	v := c.Events[0]
+	if v.Summary != "Budget review" || v.UID == "" || v.AllDay {
+		t.Errorf("unexpected event %+v", v)
+	}
+	if !v.StartDate.Equal(time.Date(2019, 3, 4, 14, 0, 0, 0, paris)) || !v.EndDate.Equal(time.Date(2019, 3, 4, 15, 30, 0, 0, paris)) {
+		t.Errorf("unexpected dates %s %s", v.StartDate, v.EndDate)
+	}
+	if prop := getProperty("DTSTART", v.Properties); prop == nil || encodeProperty(prop) != "DTSTART;TZID=Europe/Paris:20190304T140000" {
+		t.Errorf("unexpected DTSTART %v", prop)
+	}
+	if len(c.Timezones) != 1 || timezoneID(c.Timezones[0]) != "Europe/Paris" {
+		t.Errorf("expected the VTIMEZONE of Europe/Paris, got %d timezones", len(c.Timezones))
+	}
+	var attendees []string
+	for _, prop := range v.Properties {
+		if prop.Name == "ATTENDEE" {
+			attendees = append(attendees, prop.Value)
+		}
+	}
+	if strings.Join(attendees, " ") != "mailto:alice@example.com mailto:bob@example.com" {
+		t.Errorf("unexpected attendees %v", attendees)
+	}
+
+	v = c.Events[1]
+	if !v.AllDay || v.StartDate.Format(dateLayout) != "20190329" || v.EndDate.Format(dateLayout) != "20190330" {
+		t.Errorf("expected an all-day event on 2019-03-29, got %s %s", v.StartDate, v.EndDate)
+	}
+	v = c.Events[2]
+	if !v.AllDay || v.StartDate.Format(dateLayout) != "20190301" || v.EndDate.Format(dateLayout) != "20190303" {
+		t.Errorf("expected an all-day event on 2019-03-01 and 02, got %s %s", v.StartDate, v.EndDate)
+	}
+	var b bytes.Buffer
+	m.Encode(&b, c.Events[1:])
+	expected := "Subject,Start Date,Start Time,End Date,End Time,All day event,Required Attendees,Categories,Description,Location\n" +
+		"Closing,3/29/2019,,3/29/2019,,True,,,,\n" +
+		"Offsite,3/1/2019,,3/2/2019,,True,,,,\n"
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+	if c.Events[0].UID == c.Events[1].UID {
+		t.Error("expected distinct UIDs")
+	}
+
+	// a custom mapping, read back from its own export
+	custom := CSVMapping{
+		Columns: []CSVColumn{
+			{"ID", CSVUID}, {"Title", CSVSummary}, {"From", CSVStart}, {"To", CSVEnd}, {"Tags", CSVCategories},
+		},
+		DateLayout: "02.01.2006",
+		Comma:      ';',
+		Separator:  "|",
+		Location:   time.UTC,
+	}
+	c, err = custom.Decode(strings.NewReader("Title;From;To;ID;Tags\nAudit;01.04.2019 09:00;01.04.2019 17:00;audit@test;Finance|Q2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Reset()
+	custom.Encode(&b, c.Events)
+	expected = "ID;Title;From;To;Tags\naudit@test;Audit;01.04.2019 09:00;01.04.2019 17:00;Finance|Q2\n"
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+
+	// no end column
+	c, err = DefaultCSV.Decode(strings.NewReader("Summary,Start\nCall,2019-01-02 10:00\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := c.Events[0]; hasProperty("DTEND", v.Properties) || !v.occurrence(v.StartDate).End.Equal(v.StartDate) {
+		t.Errorf("expected an event without end, got %v", v.Properties)
+	}
+
+	for _, invalid := range []string{
+		"Title\nAudit\n",
+		"Title;From\nAudit;April 1st\n",
+		"Title;From;To\nAudit;01.04.2019 09:00;01.04.2019 08:00\n",
+		"Title;From;To\nAudit;02.04.2019;01.04.2019\n",
+	} {
+		if _, err := custom.Decode(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package ical
+
+import (
+	"strings"
+	"time"
+)
+
+// A ChangeKind tells how a component changed between two calendars
+type ChangeKind int
+
+// Kinds of change
+const (
+	ChangeAdded    ChangeKind = iota // The component is only in the new calendar
+	ChangeRemoved                    // The component is only in the old calendar
+	ChangeModified                   // The component is in both calendars, with differences
+)
+
+// A ChangeSet holds the differences between two calendars
+type ChangeSet struct {
+	Events    []EventChange
+	Todos     []TodoChange
+	Timezones []TimezoneChange
+}
+
+// Empty checks if there is no change at all
+func (s ChangeSet) Empty() bool {
+	return len(s.Events) == 0 && len(s.Todos) == 0 && len(s.Timezones) == 0
+}
+
+// An EventChange is an event added, removed or modified
+type EventChange struct {
+	Kind          ChangeKind
+	UID           string
+	RecurrenceID  string           // RECURRENCE-ID of an overridden instance, as an UTC date-time
+	Old           *Event           // Event of the old calendar, nil when added
+	New           *Event           // Event of the new calendar, nil when removed
+	Properties    []PropertyChange // Changed properties of a modified event
+	AlarmsChanged bool             // Whether the alarms of a modified event changed
+}
+
+// A TodoChange is a todo added, removed or modified
+type TodoChange struct {
+	Kind          ChangeKind
+	UID           string
+	RecurrenceID  string           // RECURRENCE-ID of an overridden instance, as an UTC date-time
+	Old           *Todo            // Todo of the old calendar, nil when added
+	New           *Todo            // Todo of the new calendar, nil when removed
+	Properties    []PropertyChange // Changed properties of a modified todo
+	AlarmsChanged bool             // Whether the alarms of a modified todo changed
+}
+
+// A TimezoneChange is a timezone added, removed or modified, matched by TZID
+type TimezoneChange struct {
+	Kind         ChangeKind
+	TZID         string
+	Old          *Timezone        // Timezone of the old calendar, nil when added
+	New          *Timezone        // Timezone of the new calendar, nil when removed
+	Properties   []PropertyChange // Changed properties of the VTIMEZONE itself
+	RulesChanged bool             // Whether its STANDARD or DAYLIGHT rules changed
+}
+
+// A PropertyChange is a property added, removed, or with a new value or new
+// params
+type PropertyChange struct {
+	Name string
+	Old  *Property // nil when added
+	New  *Property // nil when removed
+}
+
+// Diff returns the changes from an old to a new version of a calendar.
+// Events and todos are matched by UID and RECURRENCE-ID, timezones by TZID.
+// Repeated properties like ATTENDEE are matched by value, so that a change
+// of PARTSTAT is reported as a change of params.
+func Diff(old, new *Calendar) ChangeSet {
+	var s ChangeSet
+
+	var oldEvents, newEvents []diffComponent
+	for _, v := range old.Events {
+		oldEvents = append(oldEvents, diffComponent{key: componentKey(v.UID, v.RecurrenceID), properties: v.Properties, children: alarmsContent(v.Alarms), value: v})
+	}
+	for _, v := range new.Events {
+		newEvents = append(newEvents, diffComponent{key: componentKey(v.UID, v.RecurrenceID), properties: v.Properties, children: alarmsContent(v.Alarms), value: v})
+	}
+	for _, d := range diffComponents(oldEvents, newEvents) {
+		c := EventChange{Kind: d.kind, Properties: d.properties, AlarmsChanged: d.childrenChanged}
+		if d.old != nil {
+			c.Old = d.old.value.(*Event)
+		}
+		if d.new != nil {
+			c.New = d.new.value.(*Event)
+		}
+		v := c.New
+		if v == nil {
+			v = c.Old
+		}
+		c.UID, c.RecurrenceID = v.UID, formatRecurrenceID(v.RecurrenceID)
+		s.Events = append(s.Events, c)
+	}
+
+	var oldTodos, newTodos []diffComponent
+	for _, t := range old.Todos {
+		oldTodos = append(oldTodos, diffComponent{key: componentKey(t.UID, t.RecurrenceID), properties: t.Properties, children: alarmsContent(t.Alarms), value: t})
+	}
+	for _, t := range new.Todos {
+		newTodos = append(newTodos, diffComponent{key: componentKey(t.UID, t.RecurrenceID), properties: t.Properties, children: alarmsContent(t.Alarms), value: t})
+	}
+	for _, d := range diffComponents(oldTodos, newTodos) {
+		c := TodoChange{Kind: d.kind, Properties: d.properties, AlarmsChanged: d.childrenChanged}
+		if d.old != nil {
+			c.Old = d.old.value.(*Todo)
+		}
+		if d.new != nil {
+			c.New = d.new.value.(*Todo)
+		}
+		t := c.New
+		if t == nil {
+			t = c.Old
+		}
+		c.UID, c.RecurrenceID = t.UID, formatRecurrenceID(t.RecurrenceID)
+		s.Todos = append(s.Todos, c)
+	}
+
+	var oldTimezones, newTimezones []diffComponent
+	for _, tz := range old.Timezones {
+		oldTimezones = append(oldTimezones, diffComponent{key: timezoneID(tz), properties: tz.Properties, children: rulesContent(tz), value: tz})
+	}
+	for _, tz := range new.Timezones {
+		newTimezones = append(newTimezones, diffComponent{key: timezoneID(tz), properties: tz.Properties, children: rulesContent(tz), value: tz})
+	}
+	for _, d := range diffComponents(oldTimezones, newTimezones) {
+		c := TimezoneChange{Kind: d.kind, Properties: d.properties, RulesChanged: d.childrenChanged}
+		if d.old != nil {
+			c.Old = d.old.value.(*Timezone)
+			c.TZID = d.old.key
+		}
+		if d.new != nil {
+			c.New = d.new.value.(*Timezone)
+			c.TZID = d.new.key
+		}
+		s.Timezones = append(s.Timezones, c)
+	}
+
+	return s
+}
+
+// a diffComponent is a component being compared: its properties, and its
+// sub-components as content lines
+type diffComponent struct {
+	key        string
+	properties []*Property
+	children   string
+	value      interface{}
+}
+
+// a componentDiff is a change of a component
+type componentDiff struct {
+	kind            ChangeKind
+	old             *diffComponent
+	new             *diffComponent
+	properties      []PropertyChange
+	childrenChanged bool
+}
+
+// diffComponents pairs the components by key, in order for a key used
+// several times, and returns the changes: the added and modified ones in the
+// order of the new components, then the removed ones
+func diffComponents(old, new []diffComponent) []componentDiff {
+	byKey := make(map[string][]int)
+	for i, c := range old {
+		byKey[c.key] = append(byKey[c.key], i)
+	}
+
+	var diffs []componentDiff
+	matched := make([]bool, len(old))
+	for i := range new {
+		n := &new[i]
+		candidates := byKey[n.key]
+		if len(candidates) == 0 {
+			diffs = append(diffs, componentDiff{kind: ChangeAdded, new: n})
+			continue
+		}
+		o := &old[candidates[0]]
+		matched[candidates[0]] = true
+		byKey[n.key] = candidates[1:]
+
+		d := componentDiff{
+			kind:            ChangeModified,
+			old:             o,
+			new:             n,
+			properties:      diffProperties(o.properties, n.properties),
+			childrenChanged: o.children != n.children,
+		}
+		if len(d.properties) > 0 || d.childrenChanged {
+			diffs = append(diffs, d)
+		}
+	}
+
+	for i := range old {
+		if !matched[i] {
+			diffs = append(diffs, componentDiff{kind: ChangeRemoved, old: &old[i]})
+		}
+	}
+	return diffs
+}
+
+// diffProperties returns the changes between two lists of properties, by
+// name in order of first appearance
+func diffProperties(old, new []*Property) []PropertyChange {
+	var names []string
+	byName := func(properties []*Property) map[string][]*Property {
+		m := make(map[string][]*Property)
+		for _, prop := range properties {
+			if _, ok := m[prop.Name]; !ok && !containsString(names, prop.Name) {
+				names = append(names, prop.Name)
+			}
+			m[prop.Name] = append(m[prop.Name], prop)
+		}
+		return m
+	}
+	oldByName, newByName := byName(old), byName(new)
+
+	var changes []PropertyChange
+	for _, name := range names {
+		changes = append(changes, diffPropertyList(name, oldByName[name], newByName[name])...)
+	}
+	return changes
+}
+
+// diffPropertyList returns the changes between the properties of a name.
+// Identical properties are unchanged, then properties with the same value
+// have changed params, and the remaining ones are paired in order.
+func diffPropertyList(name string, old, new []*Property) []PropertyChange {
+	old = append([]*Property(nil), old...)
+	new = append([]*Property(nil), new...)
+
+	pair := func(match func(a, b *Property) bool, changed bool) []PropertyChange {
+		var changes []PropertyChange
+		for i := 0; i < len(new); i++ {
+			for j := 0; j < len(old); j++ {
+				if !match(old[j], new[i]) {
+					continue
+				}
+				if changed {
+					changes = append(changes, PropertyChange{Name: name, Old: old[j], New: new[i]})
+				}
+				old = append(old[:j], old[j+1:]...)
+				new = append(new[:i], new[i+1:]...)
+				i--
+				break
+			}
+		}
+		return changes
+	}
+
+	pair(func(a, b *Property) bool { return formatProperty(a) == formatProperty(b) }, false)
+	changes := pair(func(a, b *Property) bool { return sameAddress(a.Value, b.Value) }, true)
+	changes = append(changes, pair(func(a, b *Property) bool { return true }, true)...)
+
+	for _, prop := range new {
+		changes = append(changes, PropertyChange{Name: name, New: prop})
+	}
+	for _, prop := range old {
+		changes = append(changes, PropertyChange{Name: name, Old: prop})
+	}
+	return changes
+}
+
+// componentKey identifies an event or a todo by its UID and RECURRENCE-ID
+func componentKey(uid string, recurrenceID time.Time) string {
+	return uid + "\n" + formatRecurrenceID(recurrenceID)
+}
+
+// formatRecurrenceID formats a RECURRENCE-ID as an UTC date-time, empty if
+// not set
+func formatRecurrenceID(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return formatDateTime(t)
+}
+
+// timezoneID returns the TZID of a timezone
+func timezoneID(tz *Timezone) string {
+	if prop := getProperty("TZID", tz.Properties); prop != nil {
+		return prop.Value
+	}
+	return ""
+}
+
+// rulesContent returns the content lines of the STANDARD and DAYLIGHT rules
+// of a timezone
+func rulesContent(tz *Timezone) string {
+	var b strings.Builder
+	for _, r := range tz.Standards {
+		b.WriteString(beginStandard + crlf)
+		for _, prop := range r.Properties {
+			b.WriteString(formatProperty(prop) + crlf)
+		}
+	}
+	for _, r := range tz.Daylights {
+		b.WriteString(beginDaylight + crlf)
+		for _, prop := range r.Properties {
+			b.WriteString(formatProperty(prop) + crlf)
+		}
+	}
+	return b.String()
+}
+
+// alarmsContent returns the content lines of alarms
+func alarmsContent(alarms []*Alarm) string {
+	var b strings.Builder
+	for _, a := range alarms {
+		b.WriteString(beginValarm + crlf)
+		for _, prop := range a.Properties {
+			b.WriteString(formatProperty(prop) + crlf)
+		}
+	}
+	return b.String()
+}
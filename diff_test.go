@@ -0,0 +1,90 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := invitation(t)
+	oldCalendar := NewCalendar()
+	oldCalendar.Events = append(oldCalendar.Events, old)
+	oldCalendar.Timezones = append(oldCalendar.Timezones, &Timezone{
+		Properties: []*Property{{Name: "TZID", Value: "Europe/Paris", Params: map[string]*Param{}}},
+		Standards:  []*Standard{{Properties: []*Property{{Name: "TZOFFSETTO", Value: "+0100", Params: map[string]*Param{}}}}},
+	})
+	removed := NewTodo()
+	removed.UID = "todo"
+	removed.Properties = append(removed.Properties, &Property{Name: "UID", Value: "todo", Params: map[string]*Param{}})
+	oldCalendar.Todos = append(oldCalendar.Todos, removed)
+
+	v := old.clone()
+	setProperty("LOCATION", "Room 2", &v.Properties)
+	removeProperty("SUMMARY", &v.Properties)
+	getProperty("ORGANIZER", v.Properties).Params["CN"] = &Param{Values: []string{"Alice Smith"}}
+	for _, prop := range v.Properties {
+		if prop.Name == "ATTENDEE" && sameAddress(prop.Value, "mailto:bob@example.com") {
+			prop.Params["PARTSTAT"] = &Param{Values: []string{"ACCEPTED"}}
+		}
+	}
+	v.Properties = append(v.Properties, &Property{Name: "ATTENDEE", Value: "mailto:dave@example.com", Params: map[string]*Param{}})
+
+	override := old.clone()
+	override.Alarms = nil
+	override.RecurrenceID = override.StartDate.AddDate(0, 0, 7)
+	setProperty("RECURRENCE-ID", formatDateTime(override.RecurrenceID), &override.Properties)
+
+	newCalendar := NewCalendar()
+	newCalendar.Events = append(newCalendar.Events, v, override)
+	newCalendar.Timezones = append(newCalendar.Timezones, &Timezone{
+		Properties: []*Property{{Name: "TZID", Value: "Europe/Paris", Params: map[string]*Param{}}},
+		Standards:  []*Standard{{Properties: []*Property{{Name: "TZOFFSETTO", Value: "+0200", Params: map[string]*Param{}}}}},
+	})
+
+	s := Diff(oldCalendar, newCalendar)
+
+	if len(s.Events) != 2 {
+		t.Fatalf("expected 2 event changes, got %+v", s.Events)
+	}
+	modified := s.Events[0]
+	if modified.Kind != ChangeModified || modified.UID != "meeting@example.com" || modified.RecurrenceID != "" || modified.AlarmsChanged {
+		t.Errorf("unexpected modified event %+v", modified)
+	}
+	var got []string
+	for _, c := range modified.Properties {
+		var before, after string
+		if c.Old != nil {
+			before = encodeProperty(c.Old)
+		}
+		if c.New != nil {
+			after = encodeProperty(c.New)
+		}
+		got = append(got, before+" -> "+after)
+	}
+	expected := []string{
+		"ORGANIZER;CN=Alice:mailto:alice@example.com -> ORGANIZER;CN=Alice Smith:mailto:alice@example.com",
+		"ATTENDEE;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:bob@example.com -> ATTENDEE;PARTSTAT=ACCEPTED;RSVP=TRUE:mailto:bob@example.com",
+		" -> ATTENDEE:mailto:dave@example.com",
+		"SUMMARY:Planning -> ",
+		"LOCATION:Room 1 -> LOCATION:Room 2",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected property changes\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	added := s.Events[1]
+	if added.Kind != ChangeAdded || added.New != override || added.RecurrenceID != "20190117T100000Z" {
+		t.Errorf("unexpected added event %+v", added)
+	}
+
+	if len(s.Todos) != 1 || s.Todos[0].Kind != ChangeRemoved || s.Todos[0].Old != removed {
+		t.Errorf("unexpected todo changes %+v", s.Todos)
+	}
+	if len(s.Timezones) != 1 || s.Timezones[0].TZID != "Europe/Paris" || !s.Timezones[0].RulesChanged || len(s.Timezones[0].Properties) != 0 {
+		t.Errorf("unexpected timezone changes %+v", s.Timezones)
+	}
+
+	if s := Diff(oldCalendar, oldCalendar); !s.Empty() {
+		t.Errorf("expected no change, got %+v", s)
+	}
+}
@@ -0,0 +1,360 @@
+package ical
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineOctets is the longest line allowed before folding, from rfc5545-3.1
+const maxLineOctets = 75
+
+// Encode writes the calendar in the iCalendar format.
+// The Properties of each component are written as they are, typed fields
+// like Event.Summary are not taken into account. A parsed calendar is
+// written back as it was read, apart from folding: properties and
+// components in the same order, unknown components included, and
+// properties left unchanged byte for byte.
+func Encode(w io.Writer, c *Calendar) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.component(c)
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// inOrder sorts components in the order they were parsed, the ones added
+// since after them in their default order
+func inOrder(order []interface{}, components []interface{}) []interface{} {
+	if len(order) == 0 {
+		return components
+	}
+
+	present := make(map[interface{}]bool, len(components))
+	for _, c := range components {
+		present[c] = true
+	}
+	sorted := make([]interface{}, 0, len(components))
+	for _, c := range order {
+		if present[c] {
+			sorted = append(sorted, c)
+			delete(present, c)
+		}
+	}
+	for _, c := range components {
+		if present[c] {
+			sorted = append(sorted, c)
+		}
+	}
+	return sorted
+}
+
+// layout returns the properties and subcomponents of a component in the
+// order to write them: a parsed subcomponent after the property it followed
+// then, or before every property when it came first, and the ones added
+// since after every property
+func layout(order []interface{}, properties []*Property, components []interface{}) []interface{} {
+	present := make(map[interface{}]bool, len(properties)+len(components))
+	for _, prop := range properties {
+		present[prop] = true
+	}
+	for _, c := range components {
+		present[c] = true
+	}
+
+	// subcomponents by the property they follow, nil for none
+	after := make(map[*Property][]interface{})
+	placed := make(map[interface{}]bool)
+	var last *Property
+	for _, item := range order {
+		if !present[item] {
+			continue
+		}
+		if prop, ok := item.(*Property); ok {
+			last = prop
+			continue
+		}
+		after[last] = append(after[last], item)
+		placed[item] = true
+	}
+
+	items := make([]interface{}, 0, len(properties)+len(components))
+	items = append(items, after[nil]...)
+	for _, prop := range properties {
+		items = append(items, prop)
+		items = append(items, after[prop]...)
+	}
+	for _, c := range components {
+		if !placed[c] {
+			items = append(items, c)
+		}
+	}
+	return items
+}
+
+// contents returns the name of a component, its parse order, its properties
+// and its subcomponents in the order they were parsed
+func contents(component interface{}) (string, []interface{}, []*Property, []interface{}) {
+	var children []interface{}
+	var unknown []*Component
+	var name string
+	var order []interface{}
+	var properties []*Property
+
+	switch c := component.(type) {
+	case *Calendar:
+		name, order, properties, unknown = "VCALENDAR", c.order, c.Properties, c.Components
+		for _, t := range c.Timezones {
+			children = append(children, t)
+		}
+		for _, v := range c.Events {
+			children = append(children, v)
+		}
+		for _, t := range c.Todos {
+			children = append(children, t)
+		}
+		for _, f := range c.FreeBusys {
+			children = append(children, f)
+		}
+	case *Timezone:
+		name, order, properties, unknown = "VTIMEZONE", c.order, c.Properties, c.Components
+		for _, s := range c.Standards {
+			children = append(children, s)
+		}
+		for _, d := range c.Daylights {
+			children = append(children, d)
+		}
+	case *Standard:
+		name, order, properties, unknown = "STANDARD", c.order, c.Properties, c.Components
+	case *Daylight:
+		name, order, properties, unknown = "DAYLIGHT", c.order, c.Properties, c.Components
+	case *Event:
+		name, order, properties, unknown = "VEVENT", c.order, c.Properties, c.Components
+		for _, a := range c.Alarms {
+			children = append(children, a)
+		}
+	case *Todo:
+		name, order, properties, unknown = "VTODO", c.order, c.Properties, c.Components
+		for _, a := range c.Alarms {
+			children = append(children, a)
+		}
+	case *Alarm:
+		name, order, properties, unknown = "VALARM", c.order, c.Properties, c.Components
+	case *FreeBusy:
+		name, order, properties, unknown = "VFREEBUSY", c.order, c.Properties, c.Components
+	case *Component:
+		name, order, properties, unknown = c.Name, c.order, c.Properties, c.Components
+	}
+
+	for _, u := range unknown {
+		children = append(children, u)
+	}
+	return name, order, properties, inOrder(order, children)
+}
+
+// encoder writes content lines, keeping the first error
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// component writes a component with its properties and subcomponents
+func (e *encoder) component(component interface{}) {
+	name, order, properties, children := contents(component)
+	e.line("BEGIN:" + name)
+	for _, item := range layout(order, properties, children) {
+		if prop, ok := item.(*Property); ok {
+			e.line(encodeProperty(prop))
+		} else {
+			e.component(item)
+		}
+	}
+	e.line("END:" + name)
+}
+
+// line writes a content line, folded
+func (e *encoder) line(text string) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.WriteString(fold(text) + crlf)
+}
+
+// encodeProperty formats a property as a content line. A parsed property
+// left unchanged is written as it was read. Otherwise its params are written
+// as returned by ParamEntries.
+func encodeProperty(prop *Property) string {
+	if prop.line != "" && formatProperty(prop) == prop.parsed {
+		return prop.line
+	}
+
+	var b strings.Builder
+	b.WriteString(prop.Name)
+	for _, entry := range prop.ParamEntries() {
+		writeParam(&b, entry.Name, entry.Values, entry.Quoted)
+	}
+	b.WriteString(":")
+	b.WriteString(prop.Value)
+	return b.String()
+}
+
+// formatProperty formats a property as a content line in a canonical form,
+// params sorted by name and quoted when needed
+func formatProperty(prop *Property) string {
+	names := make([]string, 0, len(prop.Params))
+	for name := range prop.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(prop.Name)
+	for _, name := range names {
+		writeParam(&b, name, prop.Params[name].Values, nil)
+	}
+	b.WriteString(":")
+	b.WriteString(prop.Value)
+	return b.String()
+}
+
+// writeParam writes a param, values quoted when needed or when set in quoted
+func writeParam(b *strings.Builder, name string, values []string, quoted []bool) {
+	b.WriteString(";")
+	b.WriteString(name)
+	b.WriteString("=")
+	for i, value := range values {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		if i < len(quoted) && quoted[i] {
+			b.WriteString(`"` + value + `"`)
+		} else {
+			b.WriteString(encodeParamValue(value))
+		}
+	}
+}
+
+// splitParam checks if a param repeated when parsed still holds the values
+// of every repetition, to write them again as they were
+func splitParam(entries []ParamEntry, name string, param *Param) bool {
+	var values []string
+	count := 0
+	for _, entry := range entries {
+		if entry.Name == name {
+			values = append(values, entry.Values...)
+			count++
+		}
+	}
+	if count < 2 || len(values) != len(param.Values) {
+		return false
+	}
+	for i := range values {
+		if values[i] != param.Values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// quotedValues returns which values of a param were quoted when parsed
+func quotedValues(entries []ParamEntry, name string, values []string) []bool {
+	quoted := make([]bool, len(values))
+	for i, value := range values {
+		for _, entry := range entries {
+			for j, v := range entry.Values {
+				if entry.Name == name && v == value && j < len(entry.Quoted) && entry.Quoted[j] {
+					quoted[i] = true
+				}
+			}
+		}
+	}
+	return quoted
+}
+
+// encodeParamValue quotes a param-value holding a character not allowed in paramtext
+func encodeParamValue(value string) string {
+	if strings.ContainsAny(value, ";:,") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// fold splits a content line into lines of at most 75 octets, without
+// breaking a multi-byte character
+// from rfc5545-3.1
+func fold(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString(crlf + " ")
+		line = line[cut:]
+		limit = maxLineOctets - 1 // the leading space counts
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+// formatDateTime formats a time as an UTC date-time value
+func formatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayoutUTC)
+}
+
+// formatDuration formats a duration as a dur-value in days, hours, minutes
+// and seconds
+// from rfc5545-3.3.6
+func formatDuration(d time.Duration) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteString("-")
+		d = -d
+	}
+	b.WriteString("P")
+
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	if days > 0 {
+		b.WriteString(strconv.FormatInt(int64(days), 10) + "D")
+	}
+	if d == 0 && days > 0 {
+		return b.String()
+	}
+
+	b.WriteString("T")
+	h, m, s := d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second
+	if h > 0 {
+		b.WriteString(strconv.FormatInt(int64(h), 10) + "H")
+	}
+	if m > 0 {
+		b.WriteString(strconv.FormatInt(int64(m), 10) + "M")
+	}
+	if s > 0 || d < time.Minute {
+		b.WriteString(strconv.FormatInt(int64(s), 10) + "S")
+	}
+	return b.String()
+}
+
+// formatDateLike formats a time in the same form as the value of a date
+// property: a date, an UTC date-time or a local date-time
+func formatDateLike(prop *Property, t time.Time) string {
+	switch {
+	case isDate(prop):
+		return t.Format(dateLayout)
+	case strings.HasSuffix(prop.Value, "Z"):
+		return formatDateTime(t)
+	}
+	return t.Format(dateTimeLayoutLocalized)
+}
@@ -0,0 +1,286 @@
+package ical
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func Test_fold(t *testing.T) {
+	t.Run("short line", func(t *testing.T) {
+		line := "SUMMARY:short"
+		if got := fold(line); got != line {
+			t.Errorf("got '%s' want '%s'", got, line)
+		}
+	})
+
+	t.Run("long line", func(t *testing.T) {
+		line := "DESCRIPTION:" + strings.Repeat("a", 200)
+		got := fold(line)
+		for _, l := range strings.Split(got, crlf) {
+			if len(l) > maxLineOctets {
+				t.Errorf("line of %d octets: '%s'", len(l), l)
+			}
+		}
+		if unfold(got) != line {
+			t.Errorf("unfolded line differs: '%s'", unfold(got))
+		}
+	})
+
+	t.Run("multi-byte characters", func(t *testing.T) {
+		line := "SUMMARY:" + strings.Repeat("日本", 40)
+		got := fold(line)
+		for _, l := range strings.Split(got, crlf) {
+			if len(l) > maxLineOctets || !utf8.ValidString(l) {
+				t.Errorf("invalid line '%s'", l)
+			}
+		}
+		if unfold(got) != line {
+			t.Errorf("unfolded line differs: '%s'", unfold(got))
+		}
+	})
+}
+
+func Test_encodeProperty(t *testing.T) {
+	prop := &Property{
+		Name: "ATTENDEE",
+		Params: map[string]*Param{
+			"ROLE":     &Param{Values: []string{"REQ-PARTICIPANT"}},
+			"CN":       &Param{Values: []string{"Doe, John"}},
+			"DELEGATE": &Param{Values: []string{"mailto:a@example.com", "mailto:b@example.com"}},
+		},
+		Value: "mailto:john@example.com",
+	}
+	want := `ATTENDEE;CN="Doe, John";DELEGATE="mailto:a@example.com","mailto:b@example.com";ROLE=REQ-PARTICIPANT:mailto:john@example.com`
+	if got := encodeProperty(prop); got != want {
+		t.Errorf("got '%s' want '%s'", got, want)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, filename := range append(calendarList, "fixtures/icalendar.ics", "fixtures/work.ics") {
+		t.Run(filename, func(t *testing.T) {
+			file, _ := os.Open(filename)
+			c, err := Parse(file, nil)
+			file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, c); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Parse(&buf, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, c) {
+				t.Errorf("calendar differs after encoding")
+			}
+		})
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	for _, filename := range append(calendarList, "fixtures/icalendar.ics", "fixtures/work.ics", "fixtures/invitation.ics") {
+		t.Run(filename, func(t *testing.T) {
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, err := Parse(bytes.NewReader(data), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, c); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := unfold(buf.String()), unfold(string(data)); got != want {
+				t.Errorf("encoded calendar differs from the input:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestEncodeEditedProperty(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//EN",
+		"BEGIN:VEVENT",
+		"UID:edit@example.com",
+		"DTSTAMP:20190101T000000Z",
+		"DTSTART:20190110T100000Z",
+		`ATTENDEE;RSVP=TRUE;CN="Bob";X-TAG=a;X-TAG=b;PARTSTAT=NEEDS-ACTION:mailto:bob@example.com`,
+		`ORGANIZER;CN="Alice";SENT-BY="mailto:assistant@example.com":mailto:alice@example.com`,
+		"SUMMARY:Planning",
+		"END:VEVENT",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Paris",
+		"BEGIN:DAYLIGHT",
+		"DTSTART:19700329T020000",
+		"TZOFFSETFROM:+0100",
+		"TZOFFSETTO:+0200",
+		"END:DAYLIGHT",
+		"BEGIN:STANDARD",
+		"DTSTART:19701025T030000",
+		"TZOFFSETFROM:+0200",
+		"TZOFFSETTO:+0100",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	c, err := Parse(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attendee := getProperty("ATTENDEE", c.Events[0].Properties)
+	if values := attendee.Params["X-TAG"].Values; strings.Join(values, ",") != "a,b" {
+		t.Errorf("expected the values of the duplicate param, got %v", values)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	if unfold(buf.String()) != input {
+		t.Errorf("expected the input back, got\n%s", buf.String())
+	}
+
+	attendee.Params["PARTSTAT"].Values[0] = "ACCEPTED"
+	attendee.Params["DELEGATED-FROM"] = &Param{Values: []string{"mailto:carol@example.com"}}
+	organizer := getProperty("ORGANIZER", c.Events[0].Properties)
+	organizer.Value = "mailto:alice@example.org"
+	c.Events = append(c.Events, NewEvent())
+
+	buf.Reset()
+	if err := Encode(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(input, "PARTSTAT=NEEDS-ACTION:mailto:bob@example.com", `PARTSTAT=ACCEPTED;DELEGATED-FROM="mailto:carol@example.com":mailto:bob@example.com`, 1)
+	want = strings.Replace(want, "mailto:alice@example.com", "mailto:alice@example.org", 1)
+	want = strings.Replace(want, "END:VTIMEZONE\r\n", "END:VTIMEZONE\r\nBEGIN:VEVENT\r\nEND:VEVENT\r\n", 1)
+	if got := unfold(buf.String()); got != want {
+		t.Errorf("expected only the edited properties to change, got\n%s", got)
+	}
+}
+
+func TestEncodeComponentOrder(t *testing.T) {
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//test//EN",
+		"BEGIN:VEVENT",
+		"UID:order@example.com",
+		"DTSTAMP:20190101T000000Z",
+		"DTSTART:20190110T100000Z",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Planning",
+		"TRIGGER:-PT10M",
+		"END:VALARM",
+		"SUMMARY:after alarm",
+		"END:VEVENT",
+		"BEGIN:VJOURNAL",
+		"UID:journal@example.com",
+		"BEGIN:X-NOTE",
+		"X-TEXT:nested",
+		"END:X-NOTE",
+		"SUMMARY:journal",
+		"END:VJOURNAL",
+		"X-AFTER:components",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	c, err := Parse(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Components) != 1 || c.Components[0].Name != "VJOURNAL" || len(c.Components[0].Components) != 1 {
+		t.Fatalf("expected the VJOURNAL with its subcomponent, got %v", c.Components)
+	}
+	if hasProperty("BEGIN", c.Properties) || getProperty("UID", c.Components[0].Properties).Value != "journal@example.com" {
+		t.Errorf("expected the VJOURNAL properties kept in it, got %v", c.Properties)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, c); err != nil {
+		t.Fatal(err)
+	}
+	if got := unfold(buf.String()); got != input {
+		t.Errorf("expected the input back, got\n%s", got)
+	}
+
+	copied := NewCalendar()
+	copied.Events = append(copied.Events, c.Events[0].clone())
+	buf.Reset()
+	if err := Encode(&buf, copied); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "END:VALARM\r\nSUMMARY:after alarm\r\nEND:VEVENT\r\n") {
+		t.Errorf("expected the alarm kept in place in a copy, got\n%s", buf.String())
+	}
+
+	bad := strings.Replace(input, "END:X-NOTE", "END:X-OTHER", 1)
+	if _, err := Parse(strings.NewReader(bad), nil); err == nil {
+		t.Errorf("expected an error for a mismatched END")
+	}
+}
+
+func TestPropertyParamEntries(t *testing.T) {
+	line := `ATTENDEE;RSVP=TRUE;CN="Bob";X-TAG=a;X-TAG=b;PARTSTAT=NEEDS-ACTION:mailto:bob@example.com`
+	c, err := Parse(strings.NewReader("BEGIN:VCALENDAR\r\n"+line+"\r\nEND:VCALENDAR\r\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prop := c.Properties[0]
+
+	want := []ParamEntry{
+		{Name: "RSVP", Values: []string{"TRUE"}, Quoted: []bool{false}},
+		{Name: "CN", Values: []string{"Bob"}, Quoted: []bool{true}},
+		{Name: "X-TAG", Values: []string{"a"}, Quoted: []bool{false}},
+		{Name: "X-TAG", Values: []string{"b"}, Quoted: []bool{false}},
+		{Name: "PARTSTAT", Values: []string{"NEEDS-ACTION"}, Quoted: []bool{false}},
+	}
+	if got := prop.ParamEntries(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		edit func(p *Property)
+		want string
+	}{
+		{"set in place", func(p *Property) { p.SetParam("CN", "Bob") }, line},
+		{"set new value", func(p *Property) { p.SetParam("RSVP", "FALSE") }, strings.Replace(line, "RSVP=TRUE", "RSVP=FALSE", 1)},
+		{"set repeated", func(p *Property) { p.SetParam("X-TAG", "c") }, strings.Replace(line, "X-TAG=a;X-TAG=b", "X-TAG=c", 1)},
+		{"add", func(p *Property) { p.SetParam("ROLE", "CHAIR") }, strings.Replace(line, ":mailto", ";ROLE=CHAIR:mailto", 1)},
+		{"remove", func(p *Property) { p.RemoveParam("CN") }, strings.Replace(line, `;CN="Bob"`, "", 1)},
+		{"reorder", func(p *Property) {
+			entries := p.ParamEntries()
+			entries[0], entries[1] = entries[1], entries[0]
+			p.SetParamEntries(entries)
+		}, strings.Replace(line, `RSVP=TRUE;CN="Bob"`, `CN="Bob";RSVP=TRUE`, 1)},
+	}
+	for _, tt := range tests {
+		p := prop.clone()
+		tt.edit(p)
+		if got := encodeProperty(p); got != tt.want {
+			t.Errorf("%s: got '%s' want '%s'", tt.name, got, tt.want)
+		}
+		if tt.name == "set repeated" && strings.Join(p.Params["X-TAG"].Values, ",") != "c" {
+			t.Errorf("%s: Params not updated: %v", tt.name, p.Params["X-TAG"].Values)
+		}
+	}
+}
@@ -35,7 +35,7 @@ func main() {
 	fmt.Println("{{ VEVENT }}")
 
 	for _, event := range calendar.Events {
-		data, err := json.Marshal(event)
+		data, err := ical.JSONOptions{OmitProperties: true}.Marshal(event)
 		if err != nil {
 			fmt.Println(err)
 		}
@@ -54,4 +54,11 @@ func main() {
 		fmt.Printf("%s\n", pretty.Pretty(data))
 	}
 
+	fmt.Println("{{ jCal }}")
+
+	jcal, err := ical.MarshalJCal(calendar)
+	if err != nil {
+		fmt.Println(err)
+	}
+	fmt.Printf("%s\n", pretty.Pretty(jcal))
 }
@@ -0,0 +1,256 @@
+package ical
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A FeedHandler serves a calendar as an .ics feed. The calendar can be
+// filtered with query parameters:
+//
+//	start, end  only the events with an occurrence within [start, end), as
+//	            dates (20060102) or date-times (20060102T150405Z or RFC 3339)
+//	category    only the events and todos with one of the categories, may be
+//	            repeated or comma separated, a comma escaped as in iCalendar
+type FeedHandler struct {
+	calendar func() (*Calendar, error)
+}
+
+// NewFeedHandler creates a FeedHandler serving the calendar returned by a
+// function, called for every request
+func NewFeedHandler(calendar func() (*Calendar, error)) *FeedHandler {
+	return &FeedHandler{calendar: calendar}
+}
+
+// ServeHTTP implements http.Handler
+func (h *FeedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseFeedFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, err := h.calendar()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := Encode(&body, filter.apply(c)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body.Bytes())
+	etag := hex.EncodeToString(sum[:])
+
+	// a compressed representation has its own strong ETag
+	compress := acceptsGzip(r)
+	if compress {
+		etag += "-gzip"
+	}
+	etag = `"` + etag + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Vary", "Accept-Encoding")
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if compress {
+		var gz bytes.Buffer
+		zw := gzip.NewWriter(&gz)
+		if _, err := zw.Write(body.Bytes()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := zw.Close(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body = gz
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != "HEAD" {
+		body.WriteTo(w)
+	}
+}
+
+// acceptsGzip checks if a request accepts a gzip content coding
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(coding, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		if len(parts) > 1 && strings.Replace(strings.TrimSpace(parts[1]), " ", "", -1) == "q=0" {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// matchETag checks if an If-None-Match header holds an entity tag
+func matchETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// feedFilter holds the filters of a feed request
+type feedFilter struct {
+	start      time.Time
+	end        time.Time
+	categories []string
+}
+
+// parseFeedFilter reads the filters of the query of a feed request
+func parseFeedFilter(r *http.Request) (*feedFilter, error) {
+	q := r.URL.Query()
+	f := &feedFilter{}
+
+	var err error
+	if v := q.Get("start"); v != "" {
+		if f.start, err = parseFeedTime(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := q.Get("end"); v != "" {
+		if f.end, err = parseFeedTime(v); err != nil {
+			return nil, err
+		}
+	}
+	if !f.start.IsZero() && !f.end.IsZero() && !f.end.After(f.start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+
+	for _, v := range q["category"] {
+		for _, category := range splitText(v, ',') {
+			if category = strings.TrimSpace(category); category != "" {
+				f.categories = append(f.categories, category)
+			}
+		}
+	}
+	return f, nil
+}
+
+// parseFeedTime parses a time of a feed query, a date being in UTC
+func parseFeedTime(value string) (time.Time, error) {
+	for _, layout := range []string{dateLayout, dateTimeLayoutUTC, time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// apply returns the calendar with only the events and todos matching the
+// filter. Recurring events are kept whole when an occurrence is within the
+// window, todos are only filtered by category.
+func (f *feedFilter) apply(c *Calendar) *Calendar {
+	if f.start.IsZero() && f.end.IsZero() && len(f.categories) == 0 {
+		return c
+	}
+
+	r := *c
+	r.Events = make([]*Event, 0, len(c.Events))
+	r.Todos = make([]*Todo, 0, len(c.Todos))
+
+	within := f.eventsWithin(c)
+	for _, v := range c.Events {
+		if within[v] && f.hasCategory(v.Properties) {
+			r.Events = append(r.Events, v)
+		}
+	}
+	for _, t := range c.Todos {
+		if f.hasCategory(t.Properties) {
+			r.Todos = append(r.Todos, t)
+		}
+	}
+	return &r
+}
+
+// eventsWithin returns the events with an occurrence within the window, with
+// every event of the same UID so that overrides keep their master. An
+// unbounded recurring event is always within a window without end.
+func (f *feedFilter) eventsWithin(c *Calendar) map[*Event]bool {
+	within := make(map[*Event]bool)
+	if f.start.IsZero() && f.end.IsZero() {
+		for _, v := range c.Events {
+			within[v] = true
+		}
+		return within
+	}
+
+	end := f.end
+	bounded := c
+	if end.IsZero() {
+		end = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+		bounded = &Calendar{}
+		for _, v := range c.Events {
+			if v.RRule != nil && v.RRule.Count == 0 && v.RRule.Until.IsZero() && v.RecurrenceID.IsZero() {
+				within[v] = true
+				continue
+			}
+			bounded.Events = append(bounded.Events, v)
+		}
+	}
+
+	for _, o := range bounded.EventsBetween(f.start, end) {
+		within[o.Event] = true
+	}
+
+	uids := make(map[string]bool)
+	for v := range within {
+		uids[v.UID] = true
+	}
+	for _, v := range c.Events {
+		if v.UID != "" && uids[v.UID] {
+			within[v] = true
+		}
+	}
+	return within
+}
+
+// hasCategory checks if a component has one of the categories of the
+// filter, case insensitively
+func (f *feedFilter) hasCategory(properties []*Property) bool {
+	if len(f.categories) == 0 {
+		return true
+	}
+	for _, prop := range properties {
+		if prop.Name != "CATEGORIES" {
+			continue
+		}
+		for _, category := range splitText(prop.Value, ',') {
+			for _, wanted := range f.categories {
+				if strings.EqualFold(strings.TrimSpace(category), wanted) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
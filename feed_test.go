@@ -0,0 +1,140 @@
+package ical
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFeedHandler(t *testing.T) {
+	file, _ := os.Open("fixtures/recurring.ics")
+	c, err := Parse(file, nil)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewFeedHandler(func() (*Calendar, error) { return c, nil })
+
+	get := func(target string, headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/rota.ics")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/calendar; charset=utf-8" {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Header())
+	}
+	etag := w.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || strings.Count(w.Body.String(), "BEGIN:VEVENT") != len(c.Events) {
+		t.Errorf("unexpected etag %s or body %s", etag, w.Body)
+	}
+	if w := get("/rota.ics", "If-None-Match", etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("expected status 304, got %d", w.Code)
+	}
+
+	w = get("/rota.ics", "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("ETag") == etag {
+		t.Fatalf("expected a gzip representation with its own etag, got %s", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(zr)
+	if _, err := Parse(strings.NewReader(string(data)), nil); err != nil {
+		t.Errorf("invalid gzip body: %v", err)
+	}
+
+	if w := get("/rota.ics?start=tomorrow"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid start, got %d", w.Code)
+	}
+	if w := get("/rota.ics?start=20190101&end=20180101"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an end before the start, got %d", w.Code)
+	}
+}
+
+func Test_feedFilter(t *testing.T) {
+	c, err := Parse(strings.NewReader(strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"PRODID:-//test//EN",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"DTSTAMP:20190101T000000Z",
+		"DTSTART:20190107T090000Z",
+		"DTEND:20190107T091500Z",
+		"RRULE:FREQ=DAILY",
+		"CATEGORIES:Team,Daily",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:standup",
+		"DTSTAMP:20190101T000000Z",
+		"RECURRENCE-ID:20190108T090000Z",
+		"DTSTART:20190108T100000Z",
+		"DTEND:20190108T101500Z",
+		"CATEGORIES:Team",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:oncall",
+		"DTSTAMP:20190101T000000Z",
+		"DTSTART:20190110T000000Z",
+		"DTEND:20190117T000000Z",
+		"CATEGORIES:Rota",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:review",
+		"DTSTAMP:20190101T000000Z",
+		"DTSTART:20190301T100000Z",
+		"DTEND:20190301T110000Z",
+		"RRULE:FREQ=WEEKLY;COUNT=2",
+		"CATEGORIES:Sales\\, EMEA",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\r\n")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"", "standup standup oncall review"},
+		{"category=rota", "oncall"},
+		{"category=daily&category=rota", "standup oncall"},
+		{"category=Team,Rota", "standup standup oncall"},
+		{"start=20190115&end=20190116", "standup standup oncall"},
+		{"start=2019-01-20T00:00:00Z&end=2019-02-01T00:00:00Z", "standup standup"},
+		{"end=20190107", ""},
+		{"start=20190305", "standup standup review"},
+		{"start=20190320", "standup standup"},
+		{"start=20190115&category=rota", "oncall"},
+		{"category=sales%5C,%20emea", "review"},
+		{"category=sales", ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/feed.ics?"+tt.query, nil)
+		f, err := parseFeedFilter(r)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		var uids []string
+		for _, v := range f.apply(c).Events {
+			uids = append(uids, v.UID)
+		}
+		if got := strings.Join(uids, " "); got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.query, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package ical
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// ComputeFreeBusy returns the busy time of the calendars within [start, end)
+// as a FreeBusy, with its properties ready to be written as a VFREEBUSY.
+//
+// Cancelled and transparent events are skipped, tentative events are
+// tentatively busy. When attendee is set, only the events it organizes or
+// attends without declining are taken into account.
+func ComputeFreeBusy(start, end time.Time, attendee string, calendars ...*Calendar) *FreeBusy {
+	var busy, tentative []Period
+
+	for _, c := range calendars {
+		for _, o := range c.EventsBetween(start, end) {
+			status, ok := busyStatus(o.Event, attendee)
+			if !ok {
+				continue
+			}
+
+			p := Period{Start: o.Start, End: o.End}
+			if p.Start.Before(start) {
+				p.Start = start
+			}
+			if p.End.After(end) {
+				p.End = end
+			}
+			if !p.End.After(p.Start) {
+				continue
+			}
+
+			if status == "BUSY-TENTATIVE" {
+				tentative = append(tentative, p)
+			} else {
+				busy = append(busy, p)
+			}
+		}
+	}
+
+	f := NewFreeBusy()
+	f.UID = newUID()
+	f.Timestamp = time.Now().UTC().Truncate(time.Second)
+	f.StartDate = start
+	f.EndDate = end
+	f.Busy = mergePeriods(busy)
+	f.BusyTentative = subtractPeriods(mergePeriods(tentative), f.Busy)
+
+	setProperty("UID", f.UID, &f.Properties)
+	setProperty("DTSTAMP", formatDateTime(f.Timestamp), &f.Properties)
+	setProperty("DTSTART", formatDateTime(start), &f.Properties)
+	setProperty("DTEND", formatDateTime(end), &f.Properties)
+	if attendee != "" {
+		setProperty("ORGANIZER", attendee, &f.Properties)
+	}
+	if len(f.Busy) > 0 {
+		prop := setProperty("FREEBUSY", formatPeriods(f.Busy), &f.Properties)
+		prop.Params["FBTYPE"] = &Param{Values: []string{"BUSY"}}
+	}
+	if len(f.BusyTentative) > 0 {
+		prop := NewProperty()
+		prop.Name = "FREEBUSY"
+		prop.Value = formatPeriods(f.BusyTentative)
+		prop.Params["FBTYPE"] = &Param{Values: []string{"BUSY-TENTATIVE"}}
+		f.Properties = append(f.Properties, prop)
+	}
+
+	return f
+}
+
+// busyStatus returns the free/busy type of an event, and false if the event
+// doesn't take time for the attendee
+func busyStatus(v *Event, attendee string) (string, bool) {
+	if prop := getProperty("TRANSP", v.Properties); prop != nil && prop.Value == "TRANSPARENT" {
+		return "", false
+	}
+
+	status := "BUSY"
+	if prop := getProperty("STATUS", v.Properties); prop != nil {
+		switch prop.Value {
+		case "CANCELLED":
+			return "", false
+		case "TENTATIVE":
+			status = "BUSY-TENTATIVE"
+		}
+	}
+
+	if attendee == "" {
+		return status, true
+	}
+
+	for _, prop := range v.Properties {
+		if !sameAddress(prop.Value, attendee) {
+			continue
+		}
+		if prop.Name == "ORGANIZER" {
+			return status, true
+		}
+		if prop.Name == "ATTENDEE" {
+			if partstat, ok := prop.Params["PARTSTAT"]; ok {
+				switch partstat.Values[0] {
+				case "DECLINED":
+					return "", false
+				case "TENTATIVE":
+					status = "BUSY-TENTATIVE"
+				}
+			}
+			return status, true
+		}
+	}
+	return "", false
+}
+
+// sameAddress compares two calendar user addresses, ignoring the case and
+// the "mailto:" scheme
+func sameAddress(a, b string) bool {
+	normalize := func(address string) string {
+		address = strings.ToLower(strings.TrimSpace(address))
+		return strings.TrimPrefix(address, "mailto:")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// mergePeriods sorts the periods and merges the overlapping ones
+func mergePeriods(periods []Period) []Period {
+	sorted := append([]Period{}, periods...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	var merged []Period
+	for _, p := range sorted {
+		if n := len(merged); n > 0 && !p.Start.After(merged[n-1].End) {
+			if p.End.After(merged[n-1].End) {
+				merged[n-1].End = p.End
+			}
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// subtractPeriods removes the sorted, merged periods of b from the sorted periods of a
+func subtractPeriods(a, b []Period) []Period {
+	var result []Period
+	for _, p := range a {
+		for _, q := range b {
+			if !q.End.After(p.Start) || !q.Start.Before(p.End) {
+				continue
+			}
+			if q.Start.After(p.Start) {
+				result = append(result, Period{Start: p.Start, End: q.Start})
+			}
+			p.Start = q.End
+			if !p.End.After(p.Start) {
+				break
+			}
+		}
+		if p.End.After(p.Start) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// formatPeriods formats periods as an UTC period list
+func formatPeriods(periods []Period) string {
+	values := make([]string, len(periods))
+	for i, p := range periods {
+		values[i] = formatDateTime(p.Start) + "/" + formatDateTime(p.End)
+	}
+	return strings.Join(values, ",")
+}
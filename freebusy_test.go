@@ -0,0 +1,96 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeFreeBusy(t *testing.T) {
+	at := func(h, m int) time.Time {
+		return time.Date(2019, time.January, 2, h, m, 0, 0, time.UTC)
+	}
+	event := func(start, end time.Time, props ...string) *Event {
+		v := NewEvent()
+		v.StartDate, v.EndDate = start, end
+		for _, p := range props {
+			kv := strings.SplitN(p, ":", 2)
+			prop := setProperty(kv[0], kv[1], &v.Properties)
+			if kv[0] == "ATTENDEE" {
+				prop.Params["PARTSTAT"] = &Param{Values: []string{"TENTATIVE"}}
+			}
+		}
+		return v
+	}
+
+	work := NewCalendar()
+	work.Events = append(work.Events,
+		event(at(9, 0), at(10, 0)),
+		event(at(9, 30), at(11, 0)),
+		event(at(12, 0), at(13, 0), "TRANSP:TRANSPARENT"),
+		event(at(14, 0), at(15, 0), "STATUS:CANCELLED"),
+		event(at(10, 30), at(12, 0), "STATUS:TENTATIVE"),
+		event(at(7, 0), at(8, 30)),
+	)
+	personal := NewCalendar()
+	personal.Events = append(personal.Events,
+		event(at(16, 0), at(20, 0), "ORGANIZER:mailto:me@example.com"),
+		event(at(17, 0), at(18, 0), "ATTENDEE:mailto:someone@example.com"),
+	)
+
+	f := ComputeFreeBusy(at(8, 0), at(18, 0), "", work, personal)
+
+	wantBusy := []Period{{at(8, 0), at(8, 30)}, {at(9, 0), at(11, 0)}, {at(16, 0), at(18, 0)}}
+	wantTentative := []Period{{at(11, 0), at(12, 0)}}
+	if !equalPeriods(f.Busy, wantBusy) {
+		t.Errorf("got busy %v want %v", f.Busy, wantBusy)
+	}
+	if !equalPeriods(f.BusyTentative, wantTentative) {
+		t.Errorf("got tentative %v want %v", f.BusyTentative, wantTentative)
+	}
+
+	t.Run("attendee", func(t *testing.T) {
+		f := ComputeFreeBusy(at(8, 0), at(18, 0), "MAILTO:someone@example.com", work, personal)
+		if len(f.Busy) != 0 || !equalPeriods(f.BusyTentative, []Period{{at(17, 0), at(18, 0)}}) {
+			t.Errorf("got busy %v tentative %v", f.Busy, f.BusyTentative)
+		}
+	})
+
+	t.Run("encode", func(t *testing.T) {
+		c := NewCalendar()
+		setProperty("PRODID", "-//test//EN", &c.Properties)
+		setProperty("VERSION", "2.0", &c.Properties)
+		c.FreeBusys = append(c.FreeBusys, f)
+
+		var buf bytes.Buffer
+		if err := Encode(&buf, c); err != nil {
+			t.Fatal(err)
+		}
+		want := "FREEBUSY;FBTYPE=BUSY:20190102T080000Z/20190102T083000Z,20190102T090000Z/20190102T110000Z,20190102T160000Z/20190102T180000Z\r\n"
+		if !strings.Contains(unfold(buf.String()), want) {
+			t.Errorf("missing busy time in\n%s", buf.String())
+		}
+
+		parsed, err := Parse(&buf, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := parsed.FreeBusys[0]
+		if !equalPeriods(got.Busy, wantBusy) || !equalPeriods(got.BusyTentative, wantTentative) {
+			t.Errorf("got busy %v tentative %v", got.Busy, got.BusyTentative)
+		}
+	})
+}
+
+func equalPeriods(a, b []Period) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Start.Equal(b[i].Start) || !a[i].End.Equal(b[i].End) {
+			return false
+		}
+	}
+	return true
+}
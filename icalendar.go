@@ -1,30 +1,87 @@
 package ical
 
 import (
+	"crypto/rand"
+	"fmt"
+	"sort"
 	"time"
 )
 
 // A Calendar represents the whole iCalendar
 type Calendar struct {
-	Properties []*Property // Properties
-	Events     []*Event    // Events
-	Timezones  []*Timezone // Timezones
-	Prodid     string      // Production Id
-	Version    string      // iCalendar version
-	Calscale   string      // Calscale: "GREGORIAN"
-	Method     string      // Method
+	Properties []*Property  // Properties
+	Events     []*Event     // Events
+	Todos      []*Todo      // Todos
+	FreeBusys  []*FreeBusy  // Free/busy time
+	Timezones  []*Timezone  // Timezones
+	Prodid     string       // Production Id
+	Version    string       // iCalendar version
+	Calscale   string       // Calscale: "GREGORIAN"
+	Method     string       // Method
+	Components []*Component // Components unknown to the parser, such as VJOURNAL
+
+	order []interface{} // Properties and components in the order they were parsed
 }
 
 // An Event represent a VEVENT component in an iCalendar
 type Event struct {
-	Properties  []*Property
-	Alarms      []*Alarm
-	UID         string
-	Timestamp   time.Time
-	StartDate   time.Time
-	EndDate     time.Time
-	Summary     string
-	Description string
+	Properties   []*Property
+	Alarms       []*Alarm
+	UID          string
+	Sequence     int
+	Timestamp    time.Time
+	StartDate    time.Time
+	EndDate      time.Time
+	AllDay       bool // DTSTART is a date
+	Summary      string
+	Description  string
+	RRule        *Recur       // Recurrence rule
+	RDates       []time.Time  // Additional recurrence dates
+	ExDates      []time.Time  // Excluded recurrence dates
+	RecurrenceID time.Time    // Instance overridden by this event
+	Components   []*Component // Components unknown to the parser
+
+	order []interface{} // Properties and components in the order they were parsed
+}
+
+// A Todo represent a VTODO component in an iCalendar
+type Todo struct {
+	Properties   []*Property
+	Alarms       []*Alarm
+	UID          string
+	Sequence     int
+	Timestamp    time.Time
+	StartDate    time.Time
+	DueDate      time.Time
+	Summary      string
+	Description  string
+	RRule        *Recur       // Recurrence rule
+	RDates       []time.Time  // Additional recurrence dates
+	ExDates      []time.Time  // Excluded recurrence dates
+	RecurrenceID time.Time    // Instance overridden by this todo
+	Components   []*Component // Components unknown to the parser
+
+	order []interface{} // Properties and components in the order they were parsed
+}
+
+// A FreeBusy represent a VFREEBUSY component in an iCalendar
+type FreeBusy struct {
+	Properties    []*Property
+	UID           string
+	Timestamp     time.Time
+	StartDate     time.Time
+	EndDate       time.Time
+	Busy          []Period     // Busy time, FBTYPE=BUSY or BUSY-UNAVAILABLE
+	BusyTentative []Period     // Tentatively busy time, FBTYPE=BUSY-TENTATIVE
+	Components    []*Component // Components unknown to the parser
+
+	order []interface{} // Properties and components in the order they were parsed
+}
+
+// A Period represent a time interval
+type Period struct {
+	Start time.Time
+	End   time.Time
 }
 
 // An Timezone represent a VTimezone component in an iCalendar
@@ -32,23 +89,57 @@ type Timezone struct {
 	Properties []*Property
 	Standards  []*Standard
 	Daylights  []*Daylight
+	Components []*Component // Components unknown to the parser
+
+	order []interface{} // Properties and components in the order they were parsed
 }
 
 // An Standard represent a Standard component in an iCalendar
 type Standard struct {
 	Properties []*Property
+	Components []*Component // Components unknown to the parser
+
+	order []interface{} // Properties and components in the order they were parsed
 }
 
 // An Daylight represent a Daylight component in an iCalendar
 type Daylight struct {
 	Properties []*Property
+	Components []*Component // Components unknown to the parser
+
+	order []interface{} // Properties and components in the order they were parsed
 }
 
 // An Alarm represent a VALARM component in an iCalendar
 type Alarm struct {
+	Properties   []*Property
+	UID          string
+	Action       string
+	Trigger      string
+	Related      string        // Related: "START" or "END", for a relative trigger
+	Offset       time.Duration // Relative trigger, from the start or the end of the component
+	TriggerDate  time.Time     // Absolute trigger, when TRIGGER is a DATE-TIME
+	Repeat       int           // Number of additional repetitions
+	Duration     time.Duration // Delay between repetitions
+	Description  string
+	Summary      string
+	Attendees    []string     // Recipients of an EMAIL alarm
+	Attach       []string     // Sound of an AUDIO alarm, attachments of an EMAIL alarm
+	Acknowledged time.Time    // Last time the alarm was acknowledged, from rfc9074
+	SnoozeOf     string       // UID of the alarm snoozed by this one, from rfc9074
+	Components   []*Component // Components unknown to the parser
+
+	order []interface{} // Properties and components in the order they were parsed
+}
+
+// A Component represent a component unknown to the parser, such as VJOURNAL,
+// kept with its properties and subcomponents to be written back
+type Component struct {
+	Name       string // Name of the component, such as "VJOURNAL"
 	Properties []*Property
-	Action     string
-	Trigger    string
+	Components []*Component
+
+	order []interface{} // Properties and components in the order they were parsed
 }
 
 // A Property represent an unparsed property in an iCalendar component
@@ -56,6 +147,18 @@ type Property struct {
 	Name   string
 	Params map[string]*Param
 	Value  string
+
+	params []ParamEntry // Params in the order they were parsed, duplicates included
+	line   string       // Content line as parsed, unfolded
+	parsed string       // Canonical form when parsed, to tell if the property changed since
+}
+
+// A ParamEntry is a param as written in a content line, with the quoting
+// of its values. A param repeated in the line has an entry per repetition.
+type ParamEntry struct {
+	Name   string
+	Values []string
+	Quoted []bool // Values written in double quotes
 }
 
 // A Param represent a list of param for a property
@@ -70,6 +173,8 @@ func NewCalendar() *Calendar {
 	}
 	c.Properties = make([]*Property, 0)
 	c.Events = make([]*Event, 0)
+	c.Todos = make([]*Todo, 0)
+	c.FreeBusys = make([]*FreeBusy, 0)
 	c.Timezones = make([]*Timezone, 0)
 	return c
 }
@@ -96,10 +201,27 @@ func NewEvent() *Event {
 	return v
 }
 
+// NewTodo creates an empty Todo
+func NewTodo() *Todo {
+	t := &Todo{}
+	t.Properties = make([]*Property, 0)
+	t.Alarms = make([]*Alarm, 0)
+	return t
+}
+
+// NewFreeBusy creates an empty FreeBusy
+func NewFreeBusy() *FreeBusy {
+	f := &FreeBusy{}
+	f.Properties = make([]*Property, 0)
+	return f
+}
+
 // NewAlarm creates an empty Alarm
 func NewAlarm() *Alarm {
 	a := &Alarm{}
 	a.Properties = make([]*Property, 0)
+	a.Attendees = make([]string, 0)
+	a.Attach = make([]string, 0)
 	return a
 }
 
@@ -123,3 +245,257 @@ func NewStandard() *Standard {
 	v.Properties = make([]*Property, 0)
 	return v
 }
+
+// clone returns a deep copy of the property
+func (p *Property) clone() *Property {
+	c := NewProperty()
+	c.Name = p.Name
+	c.Value = p.Value
+	for name, param := range p.Params {
+		c.Params[name] = &Param{Values: append([]string{}, param.Values...)}
+	}
+	c.params = p.params
+	c.line = p.line
+	c.parsed = p.parsed
+	return c
+}
+
+// ParamEntries returns the params of the property in the order they are
+// written: the ones parsed in the order they were read, a param repeated in
+// the line kept so while its values are unchanged, values quoted alike,
+// followed by the ones added since sorted by name.
+func (p *Property) ParamEntries() []ParamEntry {
+	var entries []ParamEntry
+	written := make(map[string]bool)
+	for _, entry := range p.params {
+		param, ok := p.Params[entry.Name]
+		split := ok && splitParam(p.params, entry.Name, param)
+		if !ok || (written[entry.Name] && !split) {
+			continue
+		}
+		if split {
+			entries = append(entries, ParamEntry{
+				Name:   entry.Name,
+				Values: append([]string{}, entry.Values...),
+				Quoted: append([]bool{}, entry.Quoted...),
+			})
+		} else {
+			entries = append(entries, ParamEntry{
+				Name:   entry.Name,
+				Values: append([]string{}, param.Values...),
+				Quoted: quotedValues(p.params, entry.Name, param.Values),
+			})
+		}
+		written[entry.Name] = true
+	}
+
+	names := make([]string, 0, len(p.Params))
+	for name := range p.Params {
+		if !written[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := p.Params[name].Values
+		entries = append(entries, ParamEntry{
+			Name:   name,
+			Values: append([]string{}, values...),
+			Quoted: make([]bool, len(values)),
+		})
+	}
+	return entries
+}
+
+// SetParamEntries replaces the params of the property by entries, written
+// in this order. Params holds the values of the entries of each name.
+func (p *Property) SetParamEntries(entries []ParamEntry) {
+	p.params = make([]ParamEntry, 0, len(entries))
+	p.Params = make(map[string]*Param, len(entries))
+	for _, entry := range entries {
+		p.params = append(p.params, ParamEntry{
+			Name:   entry.Name,
+			Values: append([]string{}, entry.Values...),
+			Quoted: append([]bool{}, entry.Quoted...),
+		})
+		param, ok := p.Params[entry.Name]
+		if !ok {
+			param = NewParam()
+			p.Params[entry.Name] = param
+		}
+		param.Values = append(param.Values, entry.Values...)
+	}
+	p.line = ""
+}
+
+// SetParam sets the values of a param, in place of the param when the
+// property has it, last otherwise. Values already quoted stay quoted.
+func (p *Property) SetParam(name string, values ...string) {
+	entries := p.ParamEntries()
+	param := ParamEntry{Name: name, Values: values, Quoted: quotedValues(p.params, name, values)}
+	kept := entries[:0]
+	found := false
+	for _, entry := range entries {
+		if entry.Name != name {
+			kept = append(kept, entry)
+		} else if !found {
+			kept = append(kept, param)
+			found = true
+		}
+	}
+	if !found {
+		kept = append(kept, param)
+	}
+	p.SetParamEntries(kept)
+}
+
+// RemoveParam removes a param, the other ones keeping their order
+func (p *Property) RemoveParam(name string) {
+	entries := p.ParamEntries()
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Name != name {
+			kept = append(kept, entry)
+		}
+	}
+	p.SetParamEntries(kept)
+}
+
+// clone returns a deep copy of the event, typed fields included
+func (v *Event) clone() *Event {
+	c := *v
+	m := make(cloner)
+	c.Properties = m.properties(v.Properties)
+	c.Alarms = make([]*Alarm, len(v.Alarms))
+	for i, a := range v.Alarms {
+		c.Alarms[i] = a.clone()
+		m[a] = c.Alarms[i]
+	}
+	c.Components = m.components(v.Components)
+	c.order = m.order(v.order)
+	c.RDates = append([]time.Time{}, v.RDates...)
+	c.ExDates = append([]time.Time{}, v.ExDates...)
+	if v.RRule != nil {
+		rrule := *v.RRule
+		c.RRule = &rrule
+	}
+	return &c
+}
+
+// clone returns a deep copy of the todo, typed fields included
+func (t *Todo) clone() *Todo {
+	c := *t
+	m := make(cloner)
+	c.Properties = m.properties(t.Properties)
+	c.Alarms = make([]*Alarm, len(t.Alarms))
+	for i, a := range t.Alarms {
+		c.Alarms[i] = a.clone()
+		m[a] = c.Alarms[i]
+	}
+	c.Components = m.components(t.Components)
+	c.order = m.order(t.order)
+	c.RDates = append([]time.Time{}, t.RDates...)
+	c.ExDates = append([]time.Time{}, t.ExDates...)
+	if t.RRule != nil {
+		rrule := *t.RRule
+		c.RRule = &rrule
+	}
+	return &c
+}
+
+// clone returns a deep copy of the timezone
+func (tz *Timezone) clone() *Timezone {
+	m := make(cloner)
+	c := &Timezone{Properties: m.properties(tz.Properties)}
+	for _, s := range tz.Standards {
+		r := &Standard{}
+		n := make(cloner)
+		r.Properties, r.Components = n.properties(s.Properties), n.components(s.Components)
+		r.order = n.order(s.order)
+		c.Standards = append(c.Standards, r)
+		m[s] = r
+	}
+	for _, d := range tz.Daylights {
+		r := &Daylight{}
+		n := make(cloner)
+		r.Properties, r.Components = n.properties(d.Properties), n.components(d.Components)
+		r.order = n.order(d.order)
+		c.Daylights = append(c.Daylights, r)
+		m[d] = r
+	}
+	c.Components = m.components(tz.Components)
+	c.order = m.order(tz.order)
+	return c
+}
+
+// clone returns a deep copy of the alarm, typed fields included
+func (a *Alarm) clone() *Alarm {
+	c := *a
+	m := make(cloner)
+	c.Properties = m.properties(a.Properties)
+	c.Components = m.components(a.Components)
+	c.order = m.order(a.order)
+	c.Attendees = append([]string{}, a.Attendees...)
+	c.Attach = append([]string{}, a.Attach...)
+	return &c
+}
+
+// clone returns a deep copy of the component
+func (u *Component) clone() *Component {
+	m := make(cloner)
+	c := &Component{Name: u.Name, Properties: m.properties(u.Properties)}
+	c.Components = m.components(u.Components)
+	c.order = m.order(u.order)
+	return c
+}
+
+// cloneProperties returns a deep copy of a list of properties
+func cloneProperties(properties []*Property) []*Property {
+	c := make([]*Property, len(properties))
+	for i, prop := range properties {
+		c[i] = prop.clone()
+	}
+	return c
+}
+
+// A cloner copies the properties and subcomponents of a component, keeping
+// the copy of each to set the parse order of the copied component
+type cloner map[interface{}]interface{}
+
+func (m cloner) properties(properties []*Property) []*Property {
+	c := cloneProperties(properties)
+	for i, prop := range properties {
+		m[prop] = c[i]
+	}
+	return c
+}
+
+func (m cloner) components(components []*Component) []*Component {
+	var c []*Component
+	for _, u := range components {
+		r := u.clone()
+		c = append(c, r)
+		m[u] = r
+	}
+	return c
+}
+
+// order returns a parse order with the copies of its items
+func (m cloner) order(order []interface{}) []interface{} {
+	var c []interface{}
+	for _, item := range order {
+		if clone, ok := m[item]; ok {
+			c = append(c, clone)
+		}
+	}
+	return c
+}
+
+// newUID generates a random UUID to identify a new component
+func newUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+	return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
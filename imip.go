@@ -0,0 +1,220 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EncodeMIME writes an iMIP email: a multipart/alternative message with a
+// text/plain body and the calendar as text/calendar, with the METHOD of the
+// calendar. The header holds the mail headers such as From, To and Subject:
+// the address lists are written as RFC 5322 addresses, the other headers
+// encoded when not ASCII.
+// from rfc6047-2
+func EncodeMIME(w io.Writer, header textproto.MIMEHeader, text string, c *Calendar) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, text); err != nil {
+		return err
+	}
+	if err := qp.Close(); err != nil {
+		return err
+	}
+
+	contentType := "text/calendar; charset=utf-8"
+	if c.Method != "" {
+		contentType = mime.FormatMediaType("text/calendar", map[string]string{
+			"charset": "utf-8",
+			"method":  c.Method,
+		})
+	}
+	part, err = mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return err
+	}
+	var ics bytes.Buffer
+	if err := Encode(&ics, c); err != nil {
+		return err
+	}
+	if err := writeBase64(part, ics.Bytes()); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var h bytes.Buffer
+	for _, name := range names {
+		for _, value := range header[name] {
+			if addressHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+				list, err := formatAddressList(value)
+				if err != nil {
+					return fmt.Errorf("invalid %s header: %v", name, err)
+				}
+				value = list
+			} else {
+				value = mime.QEncoding.Encode("utf-8", value)
+			}
+			fmt.Fprintf(&h, "%s: %s\r\n", name, value)
+		}
+	}
+	fmt.Fprintf(&h, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&h, "Content-Type: %s\r\n\r\n", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": mw.Boundary()}))
+
+	if _, err := h.WriteTo(w); err != nil {
+		return err
+	}
+	_, err = body.WriteTo(w)
+	return err
+}
+
+// addressHeaders are the mail headers holding a list of addresses
+// from rfc5322-3.6
+var addressHeaders = map[string]bool{
+	"From": true, "Sender": true, "Reply-To": true, "To": true, "Cc": true, "Bcc": true,
+}
+
+// formatAddressList formats a list of addresses, display names quoted or
+// encoded as needed
+func formatAddressList(value string) (string, error) {
+	addresses, err := mail.ParseAddressList(value)
+	if err != nil {
+		return "", err
+	}
+	list := make([]string, len(addresses))
+	for i, address := range addresses {
+		list[i] = address.String()
+	}
+	return strings.Join(list, ", "), nil
+}
+
+// writeBase64 writes base64 encoded data in lines of 76 characters
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 0 {
+		n := 76
+		if len(encoded) < n {
+			n = len(encoded)
+		}
+		if _, err := io.WriteString(w, encoded[:n]+crlf); err != nil {
+			return err
+		}
+		encoded = encoded[n:]
+	}
+	return nil
+}
+
+// ParseMIME parses every text/calendar part and .ics attachment of an email.
+// A part which can't be parsed is skipped: the calendars of the other parts
+// are returned along with an error reporting the failed ones.
+// It's up to the caller to close the io.Reader
+// if the time.Location parameter is not set, it will default to the system location
+func ParseMIME(r io.Reader, l *time.Location) ([]*Calendar, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendars []*Calendar
+	var errs []string
+	if err := parseMIMEPart(textproto.MIMEHeader(msg.Header), msg.Body, l, &calendars, &errs); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) > 0 {
+		return calendars, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return calendars, nil
+}
+
+// parseMIMEPart parses the calendars of a MIME entity, walking through
+// multipart entities. The errors of the parts are added to errs, the walk
+// going on with the next part.
+func parseMIMEPart(header textproto.MIMEHeader, body io.Reader, l *time.Location, calendars *[]*Calendar, errs *[]string) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := parseMIMEPart(part.Header, part, l, calendars, errs); err != nil {
+				*errs = append(*errs, err.Error())
+			}
+		}
+	}
+
+	if !isCalendarPart(mediaType, params, header) {
+		return nil
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	c, err := Parse(bytes.NewReader(data), l)
+	if err != nil {
+		return err
+	}
+	*calendars = append(*calendars, c)
+	return nil
+}
+
+// isCalendarPart checks if a MIME entity is a calendar, by its media type or
+// the .ics extension of its file name
+func isCalendarPart(mediaType string, params map[string]string, header textproto.MIMEHeader) bool {
+	if mediaType == "text/calendar" || mediaType == "application/ics" {
+		return true
+	}
+
+	name := params["name"]
+	if _, dispositionParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && dispositionParams["filename"] != "" {
+		name = dispositionParams["filename"]
+	}
+	return strings.EqualFold(path.Ext(name), ".ics")
+}
@@ -0,0 +1,120 @@
+package ical
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMIME(t *testing.T) {
+	c, err := NewRequest(invitation(t), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := textproto.MIMEHeader{
+		"From":    {`"Smith, Alice" <alice@example.com>`},
+		"To":      {"Bob <bob@example.com>, Zoë <zoe@example.com>"},
+		"Subject": {"Invitation: Planning à 10h"},
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeMIME(&buf, header, "You are invited to the planning.", c); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := buf.String()
+	for _, want := range []string{
+		"Subject: =?utf-8?q?Invitation:_Planning_=C3=A0_10h?=\r\n",
+		"From: \"Smith, Alice\" <alice@example.com>\r\n",
+		"To: \"Bob\" <bob@example.com>, =?utf-8?q?Zo=C3=AB?= <zoe@example.com>\r\n",
+		"Content-Type: multipart/alternative; boundary=",
+		"Content-Type: text/plain; charset=utf-8\r\n",
+		"Content-Type: text/calendar; charset=utf-8; method=REQUEST\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in\n%s", want, msg)
+		}
+	}
+
+	calendars, err := ParseMIME(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calendars) != 1 || calendars[0].Method != MethodRequest || calendars[0].Events[0].UID != "meeting@example.com" {
+		t.Errorf("got %d calendars", len(calendars))
+	}
+}
+
+func TestParseMIME(t *testing.T) {
+	ics, _ := ioutil.ReadFile("fixtures/invitation.ics")
+
+	var qp bytes.Buffer
+	w := quotedprintable.NewWriter(&qp)
+	w.Write([]byte(strings.Replace(string(ics), "SUMMARY:Planning", "SUMMARY:Planning à 10h", 1)))
+	w.Close()
+
+	msg := strings.Join([]string{
+		"From: alice@example.com",
+		"Subject: Invitations",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/mixed; boundary="outer"`,
+		"",
+		"--outer",
+		`Content-Type: multipart/alternative; boundary="inner"`,
+		"",
+		"--inner",
+		"Content-Type: text/plain",
+		"",
+		"Hello",
+		"--inner",
+		"Content-Type: text/calendar; method=REQUEST",
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		qp.String(),
+		"--inner--",
+		"--outer",
+		"Content-Type: application/octet-stream",
+		`Content-Disposition: attachment; filename="invite.ICS"`,
+		"Content-Transfer-Encoding: base64",
+		"",
+		base64Lines(ics),
+		"--outer",
+		"Content-Type: text/calendar",
+		"",
+		"BEGIN:VCALENDAR",
+		"BEGIN:VEVENT",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"--outer",
+		"Content-Type: application/pdf",
+		"",
+		"%PDF-1.4",
+		"--outer--",
+		"",
+	}, "\r\n")
+
+	// the invalid calendar is reported, the valid ones returned
+	calendars, err := ParseMIME(strings.NewReader(msg), nil)
+	if err == nil {
+		t.Errorf("expected an error for the invalid calendar")
+	}
+	if len(calendars) != 2 {
+		t.Fatalf("got %d calendars want 2", len(calendars))
+	}
+	if got := calendars[0].Events[0].Summary; got != "Planning à 10h" {
+		t.Errorf("got summary %q", got)
+	}
+	if got := calendars[1].Events[0].Summary; got != "Planning" {
+		t.Errorf("got summary %q", got)
+	}
+}
+
+func base64Lines(data []byte) string {
+	var buf bytes.Buffer
+	writeBase64(&buf, data)
+	return strings.TrimSuffix(buf.String(), "\r\n")
+}
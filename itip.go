@@ -0,0 +1,260 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProdID is the PRODID of the calendars created by this package
+var ProdID = "-//iswangwenbin//ical//EN"
+
+// iTIP methods
+// from rfc5546-1.4
+const (
+	MethodPublish        = "PUBLISH"
+	MethodRequest        = "REQUEST"
+	MethodReply          = "REPLY"
+	MethodAdd            = "ADD"
+	MethodCancel         = "CANCEL"
+	MethodRefresh        = "REFRESH"
+	MethodCounter        = "COUNTER"
+	MethodDeclineCounter = "DECLINECOUNTER"
+)
+
+// NewRequest creates a REQUEST message inviting the attendees of the event,
+// or updating their copy. SEQUENCE is incremented when revise is set, for a
+// change of date, time, location or recurrence.
+// from rfc5546-3.2.2
+func NewRequest(v *Event, revise bool) (*Calendar, error) {
+	if err := requireProperties(v, "ORGANIZER", "ATTENDEE", "DTSTART"); err != nil {
+		return nil, err
+	}
+
+	r := itipEvent(v)
+	if !hasProperty("SUMMARY", r.Properties) {
+		setProperty("SUMMARY", "", &r.Properties)
+	}
+	if revise {
+		incrementSequence(r)
+	}
+
+	return itipCalendar(MethodRequest, r)
+}
+
+// NewCancel creates a CANCEL message. Without attendees, the whole event is
+// cancelled for everyone, otherwise only the given attendees are removed.
+// SEQUENCE is incremented.
+// from rfc5546-3.2.5
+func NewCancel(v *Event, attendees ...string) (*Calendar, error) {
+	if err := requireProperties(v, "ORGANIZER", "ATTENDEE"); err != nil {
+		return nil, err
+	}
+
+	r := itipEvent(v)
+	incrementSequence(r)
+
+	if len(attendees) == 0 {
+		setProperty("STATUS", "CANCELLED", &r.Properties)
+	} else {
+		kept := r.Properties[:0]
+		for _, prop := range r.Properties {
+			if prop.Name == "ATTENDEE" && !containsAddress(attendees, prop.Value) {
+				continue
+			}
+			kept = append(kept, prop)
+		}
+		r.Properties = kept
+		if !hasProperty("ATTENDEE", r.Properties) {
+			return nil, fmt.Errorf("no attendee %v in the event", attendees)
+		}
+	}
+
+	return itipCalendar(MethodCancel, r)
+}
+
+// NewReply creates the REPLY of an attendee to a request, with its
+// participation status: ACCEPTED, DECLINED, TENTATIVE, DELEGATED or
+// NEEDS-ACTION.
+// from rfc5546-3.2.3
+func NewReply(v *Event, attendee, partstat string) (*Calendar, error) {
+	if !containsString(eventPartStats, partstat) {
+		return nil, fmt.Errorf("invalid participation status %q of an event", partstat)
+	}
+
+	r, err := attendeeMessage(MethodReply, v, attendee, "SEQUENCE", "DTSTART", "DTEND", "DURATION", "SUMMARY")
+	if err != nil {
+		return nil, err
+	}
+
+	prop := getProperty("ATTENDEE", r.Properties)
+	prop.Params["PARTSTAT"] = &Param{Values: []string{partstat}}
+	delete(prop.Params, "RSVP")
+
+	return itipCalendar(MethodReply, r)
+}
+
+// eventPartStats are the participation statuses of an event attendee
+// from rfc5545-3.2.12
+var eventPartStats = []string{"NEEDS-ACTION", "ACCEPTED", "DECLINED", "TENTATIVE", "DELEGATED"}
+
+// NewCounter creates a COUNTER message, the proposal of an attendee to
+// change the event. The event holds the proposed changes.
+// from rfc5546-3.2.7
+func NewCounter(proposal *Event) (*Calendar, error) {
+	if err := requireProperties(proposal, "ORGANIZER", "ATTENDEE", "DTSTART"); err != nil {
+		return nil, err
+	}
+
+	return itipCalendar(MethodCounter, itipEvent(proposal))
+}
+
+// NewDeclineCounter creates the DECLINECOUNTER message of the organizer,
+// rejecting the counter proposal of an attendee.
+// from rfc5546-3.2.8
+func NewDeclineCounter(v *Event, attendee string) (*Calendar, error) {
+	r, err := attendeeMessage(MethodDeclineCounter, v, attendee, "SEQUENCE")
+	if err != nil {
+		return nil, err
+	}
+
+	return itipCalendar(MethodDeclineCounter, r)
+}
+
+// NewRefresh creates the REFRESH message of an attendee asking the organizer
+// for the latest version of the event.
+// from rfc5546-3.2.6
+func NewRefresh(v *Event, attendee string) (*Calendar, error) {
+	r, err := attendeeMessage(MethodRefresh, v, attendee)
+	if err != nil {
+		return nil, err
+	}
+
+	return itipCalendar(MethodRefresh, r)
+}
+
+// NewAdd creates an ADD message, adding the instances of the event to an
+// existing recurring event with the same UID. Only the new instances are
+// sent, without the recurrence of the event. SEQUENCE is incremented.
+// from rfc5546-3.2.4
+func NewAdd(v *Event) (*Calendar, error) {
+	if err := requireProperties(v, "ORGANIZER", "ATTENDEE", "DTSTART"); err != nil {
+		return nil, err
+	}
+
+	r := itipEvent(v)
+	removeProperty("RRULE", &r.Properties)
+	removeProperty("RDATE", &r.Properties)
+	removeProperty("EXDATE", &r.Properties)
+	removeProperty("RECURRENCE-ID", &r.Properties)
+	r.RRule = nil
+	r.RDates, r.ExDates = nil, nil
+	r.RecurrenceID = time.Time{}
+	if !hasProperty("SUMMARY", r.Properties) {
+		setProperty("SUMMARY", "", &r.Properties)
+	}
+	incrementSequence(r)
+
+	return itipCalendar(MethodAdd, r)
+}
+
+// itipCalendar creates the calendar of an iTIP message, with a VTIMEZONE
+// for each TZID of the event from the IANA time zone database
+func itipCalendar(method string, v *Event) (*Calendar, error) {
+	c := NewCalendar()
+	c.Prodid = ProdID
+	c.Version = "2.0"
+	c.Method = method
+	setProperty("PRODID", c.Prodid, &c.Properties)
+	setProperty("VERSION", c.Version, &c.Properties)
+	setProperty("METHOD", c.Method, &c.Properties)
+	c.Events = append(c.Events, v)
+	if err := addTimezones(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// itipEvent copies an event for an iTIP message, without its alarms and
+// with a new DTSTAMP
+func itipEvent(v *Event) *Event {
+	r := v.clone()
+	r.Alarms = make([]*Alarm, 0)
+	r.Timestamp = time.Now().UTC().Truncate(time.Second)
+	setProperty("DTSTAMP", formatDateTime(r.Timestamp), &r.Properties)
+	return r
+}
+
+// attendeeMessage copies the identifying properties of an event, the
+// organizer and a single attendee, with some extra properties, for a
+// message of a method. The typed fields are set again from the properties.
+func attendeeMessage(method string, v *Event, attendee string, extra ...string) (*Event, error) {
+	if err := requireProperties(v, "ORGANIZER", "UID"); err != nil {
+		return nil, err
+	}
+
+	names := append([]string{"UID", "ORGANIZER", "RECURRENCE-ID"}, extra...)
+
+	r := itipEvent(v)
+	kept := r.Properties[:0]
+	found := false
+	for _, prop := range r.Properties {
+		switch {
+		case prop.Name == "DTSTAMP":
+		case prop.Name == "ATTENDEE":
+			if found || !sameAddress(prop.Value, attendee) {
+				continue
+			}
+			found = true
+		case !containsString(names, prop.Name):
+			continue
+		}
+		kept = append(kept, prop)
+	}
+	r.Properties = kept
+
+	if !found {
+		return nil, fmt.Errorf("no attendee %q in the event", attendee)
+	}
+
+	if err := decodeEvent(r, method, floatingLocation(v.Properties, v.StartDate)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// requireProperties checks that an event has the given properties
+func requireProperties(v *Event, names ...string) error {
+	for _, name := range append([]string{"UID"}, names...) {
+		if !hasProperty(name, v.Properties) {
+			return fmt.Errorf("missing required property \"%s\"", strings.ToLower(name))
+		}
+	}
+	return nil
+}
+
+// incrementSequence increments the SEQUENCE of an event
+func incrementSequence(v *Event) {
+	v.Sequence++
+	setProperty("SEQUENCE", strconv.Itoa(v.Sequence), &v.Properties)
+}
+
+// containsAddress checks if a list of calendar user addresses holds an address
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if sameAddress(a, address) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package ical
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func invitation(t *testing.T) *Event {
+	t.Helper()
+	file, _ := os.Open("fixtures/invitation.ics")
+	c, err := Parse(file, nil)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c.Events[0]
+}
+
+// propertyValues returns the values of every property with a given name
+func propertyValues(name string, properties []*Property) []string {
+	var values []string
+	for _, prop := range properties {
+		if prop.Name == name {
+			values = append(values, prop.Value)
+		}
+	}
+	return values
+}
+
+func TestITIP(t *testing.T) {
+	v := invitation(t)
+
+	tests := []struct {
+		name      string
+		build     func() (*Calendar, error)
+		method    string
+		sequence  string
+		attendees int
+		absent    []string
+	}{
+		{
+			name:      "request",
+			build:     func() (*Calendar, error) { return NewRequest(v, false) },
+			method:    MethodRequest,
+			sequence:  "1",
+			attendees: 3,
+		},
+		{
+			name:      "revised request",
+			build:     func() (*Calendar, error) { return NewRequest(v, true) },
+			method:    MethodRequest,
+			sequence:  "2",
+			attendees: 3,
+		},
+		{
+			name:      "cancel",
+			build:     func() (*Calendar, error) { return NewCancel(v) },
+			method:    MethodCancel,
+			sequence:  "2",
+			attendees: 3,
+		},
+		{
+			name:      "cancel for an attendee",
+			build:     func() (*Calendar, error) { return NewCancel(v, "mailto:carol@example.com") },
+			method:    MethodCancel,
+			sequence:  "2",
+			attendees: 1,
+			absent:    []string{"STATUS"},
+		},
+		{
+			name:      "reply",
+			build:     func() (*Calendar, error) { return NewReply(v, "mailto:bob@example.com", "ACCEPTED") },
+			method:    MethodReply,
+			sequence:  "1",
+			attendees: 1,
+			absent:    []string{"RRULE", "LOCATION"},
+		},
+		{
+			name:      "counter",
+			build:     func() (*Calendar, error) { return NewCounter(v) },
+			method:    MethodCounter,
+			sequence:  "1",
+			attendees: 3,
+		},
+		{
+			name:      "decline counter",
+			build:     func() (*Calendar, error) { return NewDeclineCounter(v, "mailto:bob@example.com") },
+			method:    MethodDeclineCounter,
+			sequence:  "1",
+			attendees: 1,
+			absent:    []string{"DTSTART", "SUMMARY"},
+		},
+		{
+			name:      "refresh",
+			build:     func() (*Calendar, error) { return NewRefresh(v, "mailto:bob@example.com") },
+			method:    MethodRefresh,
+			attendees: 1,
+			absent:    []string{"SEQUENCE", "DTSTART"},
+		},
+		{
+			name:      "add",
+			build:     func() (*Calendar, error) { return NewAdd(v) },
+			method:    MethodAdd,
+			sequence:  "2",
+			attendees: 3,
+			absent:    []string{"RRULE", "RDATE", "EXDATE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, c); err != nil {
+				t.Fatal(err)
+			}
+			c, err = Parse(&buf, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if c.Method != tt.method || len(c.Events) != 1 {
+				t.Fatalf("got method %s with %d events", c.Method, len(c.Events))
+			}
+			r := c.Events[0]
+			if got := propertyValues("SEQUENCE", r.Properties); tt.sequence != "" && (len(got) != 1 || got[0] != tt.sequence) {
+				t.Errorf("got sequence %v want %s", got, tt.sequence)
+			}
+			if got := propertyValues("ATTENDEE", r.Properties); len(got) != tt.attendees {
+				t.Errorf("got attendees %v want %d", got, tt.attendees)
+			}
+			for _, name := range append(tt.absent, "ACTION") {
+				if hasProperty(name, r.Properties) {
+					t.Errorf("unexpected property %s", name)
+				}
+			}
+			if len(r.Alarms) != 0 || r.UID != v.UID || !hasProperty("ORGANIZER", r.Properties) || r.Timestamp.IsZero() {
+				t.Errorf("got alarms %d uid %s, missing organizer or dtstamp", len(r.Alarms), r.UID)
+			}
+		})
+	}
+
+	if v.Sequence != 1 || len(propertyValues("ATTENDEE", v.Properties)) != 3 {
+		t.Errorf("the original event was modified")
+	}
+
+	t.Run("reply partstat", func(t *testing.T) {
+		c, _ := NewReply(v, "MAILTO:Bob@example.com", "DECLINED")
+		prop := getProperty("ATTENDEE", c.Events[0].Properties)
+		if prop.Value != "mailto:bob@example.com" || prop.Params["PARTSTAT"].Values[0] != "DECLINED" || prop.Params["RSVP"] != nil {
+			t.Errorf("got attendee %s", encodeProperty(prop))
+		}
+	})
+
+	t.Run("typed fields", func(t *testing.T) {
+		c, _ := NewRefresh(v, "mailto:bob@example.com")
+		if r := c.Events[0]; !r.StartDate.IsZero() || r.Summary != "" || r.RRule != nil || r.Sequence != 0 {
+			t.Errorf("got stale typed fields %+v", r)
+		}
+
+		w := v.clone()
+		setProperty("RDATE", "20190201T100000Z", &w.Properties)
+		setProperty("EXDATE", "20190117T100000Z", &w.Properties)
+		w.RDates = []time.Time{time.Date(2019, time.February, 1, 10, 0, 0, 0, time.UTC)}
+		w.ExDates = []time.Time{time.Date(2019, time.January, 17, 10, 0, 0, 0, time.UTC)}
+		c, _ = NewAdd(w)
+		if r := c.Events[0]; len(r.RDates) != 0 || len(r.ExDates) != 0 {
+			t.Errorf("got recurrence dates %v %v", r.RDates, r.ExDates)
+		}
+	})
+
+	t.Run("timezone", func(t *testing.T) {
+		w := v.clone()
+		for _, name := range []string{"DTSTART", "DTEND"} {
+			prop := getProperty(name, w.Properties)
+			prop.Value = strings.TrimSuffix(prop.Value, "Z")
+			prop.Params["TZID"] = &Param{Values: []string{"Europe/Paris"}}
+		}
+		for _, build := range []func() (*Calendar, error){
+			func() (*Calendar, error) { return NewRequest(w, false) },
+			func() (*Calendar, error) { return NewCancel(w) },
+			func() (*Calendar, error) { return NewReply(w, "mailto:bob@example.com", "ACCEPTED") },
+			func() (*Calendar, error) { return NewAdd(w) },
+		} {
+			c, err := build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(c.Timezones) != 1 || timezoneID(c.Timezones[0]) != "Europe/Paris" {
+				t.Errorf("%s: expected the VTIMEZONE of Europe/Paris, got %d timezones", c.Method, len(c.Timezones))
+			}
+		}
+	})
+
+	t.Run("unknown attendee", func(t *testing.T) {
+		if _, err := NewReply(v, "mailto:mallory@example.com", "ACCEPTED"); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("invalid partstat", func(t *testing.T) {
+		for _, partstat := range []string{"COMPLETED", "IN-PROCESS", "accepted", ""} {
+			if _, err := NewReply(v, "mailto:bob@example.com", partstat); err == nil {
+				t.Errorf("%q: expected an error", partstat)
+			}
+		}
+	})
+
+	t.Run("missing organizer", func(t *testing.T) {
+		w := v.clone()
+		removeProperty("ORGANIZER", &w.Properties)
+		if _, err := NewRequest(w, false); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalJCal returns the jCal form of a calendar, a JSON array
+// ["vcalendar", [properties], [components]]
+// from rfc7265
+func MarshalJCal(c *Calendar) ([]byte, error) {
+	return json.Marshal(jcalCalendar(c))
+}
+
+// jcalCalendar returns the jCal array of a calendar
+func jcalCalendar(c *Calendar) []interface{} {
+	return jcalComponent(c)
+}
+
+// jcalComponent returns the jCal array of a component
+func jcalComponent(component interface{}) []interface{} {
+	name, _, properties, components := contents(component)
+	children := make([]interface{}, 0, len(components))
+	for _, c := range components {
+		children = append(children, jcalComponent(c))
+	}
+	return []interface{}{strings.ToLower(name), jcalProperties(properties), children}
+}
+
+// jcalProperties returns the jCal arrays of properties:
+// [name, {params}, type, values...]
+func jcalProperties(properties []*Property) []interface{} {
+	list := make([]interface{}, 0, len(properties))
+	for _, prop := range properties {
+		typ := valueType(prop)
+
+		params := make(map[string]interface{})
+		for name, param := range prop.Params {
+			if name == "VALUE" {
+				continue
+			}
+			if len(param.Values) == 1 {
+				params[strings.ToLower(name)] = param.Values[0]
+			} else {
+				params[strings.ToLower(name)] = param.Values
+			}
+		}
+
+		p := []interface{}{strings.ToLower(prop.Name), params, typ}
+		switch {
+		case typ == "recur":
+			p = append(p, jcalRecur(prop.Value))
+		case structured[prop.Name]:
+			var parts []interface{}
+			for _, v := range formatValues(prop, typ) {
+				parts = append(parts, jcalValue(typ, v))
+			}
+			p = append(p, parts)
+		default:
+			for _, v := range formatValues(prop, typ) {
+				p = append(p, jcalValue(typ, v))
+			}
+		}
+		list = append(list, p)
+	}
+	return list
+}
+
+// jcalValue returns a value as a JSON number or boolean for these types
+func jcalValue(typ, value string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		return strings.EqualFold(value, "TRUE")
+	}
+	return value
+}
+
+// jcalRecur returns the jCal object of a recurrence rule
+// from rfc7265-3.6.10
+func jcalRecur(value string) map[string]interface{} {
+	names, parts := splitRecur(value)
+	recur := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		var values []interface{}
+		for _, v := range parts[name] {
+			if n, err := strconv.Atoi(v); err == nil && name != "until" {
+				values = append(values, n)
+			} else {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 1 {
+			recur[name] = values[0]
+		} else {
+			recur[name] = values
+		}
+	}
+	return recur
+}
+
+// UnmarshalJCal parses the jCal form of a calendar, as Parse does for the
+// iCalendar format.
+// if the time.Location parameter is not set, it will default to the system location
+func UnmarshalJCal(data []byte, l *time.Location) (*Calendar, error) {
+	var root []interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&root); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	if err := writeJCalComponent(&b, root); err != nil {
+		return nil, err
+	}
+	return Parse(strings.NewReader(b.String()), l)
+}
+
+// writeJCalComponent writes a jCal component in the iCalendar format
+func writeJCalComponent(b *strings.Builder, component []interface{}) error {
+	if len(component) != 3 {
+		return fmt.Errorf("invalid jCal component, expected 3 items, got %d", len(component))
+	}
+	name, ok := component[0].(string)
+	properties, ok2 := component[1].([]interface{})
+	children, ok3 := component[2].([]interface{})
+	if !ok || !ok2 || !ok3 {
+		return fmt.Errorf("invalid jCal component %v", component[0])
+	}
+
+	b.WriteString("BEGIN:" + strings.ToUpper(name) + crlf)
+	for _, p := range properties {
+		prop, ok := p.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid jCal property in %s", name)
+		}
+		line, err := jcalContentLine(prop)
+		if err != nil {
+			return err
+		}
+		b.WriteString(line + crlf)
+	}
+	for _, c := range children {
+		child, ok := c.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid jCal component in %s", name)
+		}
+		if err := writeJCalComponent(b, child); err != nil {
+			return err
+		}
+	}
+	b.WriteString("END:" + strings.ToUpper(name) + crlf)
+	return nil
+}
+
+// jcalContentLine returns the content line of a jCal property
+func jcalContentLine(prop []interface{}) (string, error) {
+	if len(prop) < 4 {
+		return "", fmt.Errorf("invalid jCal property, expected at least 4 items, got %d", len(prop))
+	}
+	name, ok := prop[0].(string)
+	params, ok2 := prop[1].(map[string]interface{})
+	typ, ok3 := prop[2].(string)
+	if !ok || !ok2 || !ok3 {
+		return "", fmt.Errorf("invalid jCal property %v", prop[0])
+	}
+
+	p := typedProperty(name, typ)
+	for name, value := range params {
+		param := NewParam()
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				param.Values = append(param.Values, fmt.Sprint(item))
+			}
+		default:
+			param.Values = append(param.Values, fmt.Sprint(v))
+		}
+		p.Params[strings.ToUpper(name)] = param
+	}
+
+	var values []string
+	for _, value := range prop[3:] {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			p.Value = joinRecur(jcalRecurParts(v))
+			return encodeProperty(p), nil
+		case []interface{}:
+			for _, part := range v {
+				values = append(values, jcalString(part))
+			}
+		default:
+			values = append(values, jcalString(v))
+		}
+	}
+	p.Value = parseValues(p.Name, typ, values)
+	return encodeProperty(p), nil
+}
+
+// jcalRecurParts returns the parts of a jCal recurrence rule
+func jcalRecurParts(recur map[string]interface{}) map[string][]string {
+	parts := make(map[string][]string, len(recur))
+	for name, value := range recur {
+		switch v := value.(type) {
+		case []interface{}:
+			for _, item := range v {
+				parts[name] = append(parts[name], jcalString(item))
+			}
+		default:
+			parts[name] = []string{jcalString(v)}
+		}
+	}
+	return parts
+}
+
+// jcalString returns a JSON value as a string
+func jcalString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return fmt.Sprint(value)
+}
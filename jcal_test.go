@@ -0,0 +1,126 @@
+package ical
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJCal(t *testing.T) {
+	file, _ := os.Open("fixtures/invitation.ics")
+	c, err := Parse(file, nil)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := c.Events[0]
+	v.Properties = append(v.Properties,
+		&Property{Name: "CATEGORIES", Value: `Work,R\,D`, Params: map[string]*Param{}},
+		&Property{Name: "DESCRIPTION", Value: `Agenda:\nBudget\; hiring`, Params: map[string]*Param{}},
+		&Property{Name: "GEO", Value: "48.85;2.35", Params: map[string]*Param{}},
+		&Property{Name: "EXDATE", Value: "20190117,20190124", Params: map[string]*Param{"VALUE": {Values: []string{"DATE"}}}},
+		&Property{Name: "X-SECRET", Value: "1", Params: map[string]*Param{}},
+	)
+	c.Components = append(c.Components, &Component{
+		Name:       "VJOURNAL",
+		Properties: []*Property{{Name: "UID", Value: "journal@example.com", Params: map[string]*Param{}}},
+	})
+
+	data, err := MarshalJCal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`["vcalendar",[["prodid",{},"text"`,
+		`["sequence",{},"integer",1]`,
+		`["organizer",{"cn":"Alice"},"cal-address","mailto:alice@example.com"]`,
+		`["dtstart",{},"date-time","2019-01-10T10:00:00Z"]`,
+		`["rrule",{},"recur",{"count":4,"freq":"WEEKLY"}]`,
+		`["categories",{},"text","Work","R,D"]`,
+		`["description",{},"text","Agenda:\nBudget; hiring"]`,
+		`["geo",{},"float",[48.85,2.35]]`,
+		`["exdate",{},"date","2019-01-17","2019-01-24"]`,
+		`["x-secret",{},"unknown","1"]`,
+		`[["valarm",[["action",{},"text","DISPLAY"],["description",{},"text","Planning"],["trigger",{},"duration","-PT10M"]],[]]]`,
+		`["vjournal",[["uid",{},"text","journal@example.com"]],[]]`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %s in %s", want, data)
+		}
+	}
+
+	got, err := UnmarshalJCal(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := Diff(c, got); !s.Empty() {
+		for _, change := range s.Events {
+			for _, p := range change.Properties {
+				t.Errorf("%s changed: %v -> %v", p.Name, p.Old, p.New)
+			}
+		}
+	}
+	if got.Events[0].RRule == nil || got.Events[0].RRule.Count != 4 || len(got.Events[0].ExDates) != 2 {
+		t.Errorf("unexpected typed fields %+v", got.Events[0])
+	}
+}
+
+func TestJCalRoundTrip(t *testing.T) {
+	for _, filename := range append(calendarList, "fixtures/icalendar.ics", "fixtures/work.ics") {
+		t.Run(filename, func(t *testing.T) {
+			file, _ := os.Open(filename)
+			c, err := Parse(file, nil)
+			file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := MarshalJCal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalJCal(data, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := MarshalJCal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("expected %s, got %s", data, again)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJCal(t *testing.T) {
+	data := `["vcalendar",
+	  [["prodid",{},"text","-//test//EN"],["version",{},"text","2.0"]],
+	  [["vevent",
+	    [["uid",{},"text","jcal@example.com"],
+	     ["dtstamp",{},"date-time","2019-01-01T00:00:00Z"],
+	     ["dtstart",{"tzid":"Europe/Paris"},"date-time","2019-01-10T10:00:00"],
+	     ["duration",{},"duration","PT1H"],
+	     ["rrule",{},"recur",{"freq":"WEEKLY","byday":["MO","TH"],"until":"2019-02-01T00:00:00Z"}],
+	     ["attendee",{"cn":"Doe, John","delegated-from":["mailto:a@example.com","mailto:b@example.com"]},"cal-address","mailto:john@example.com"]],
+	    []]]]`
+	c, err := UnmarshalJCal([]byte(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := c.Events[0]
+	if v.UID != "jcal@example.com" || v.StartDate.Location().String() != "Europe/Paris" || v.EndDate.Sub(v.StartDate).Hours() != 1 {
+		t.Errorf("unexpected event %+v", v)
+	}
+	if rrule := getProperty("RRULE", v.Properties).Value; rrule != "FREQ=WEEKLY;UNTIL=20190201T000000Z;BYDAY=MO,TH" {
+		t.Errorf("unexpected rrule %s", rrule)
+	}
+	if attendee := formatProperty(getProperty("ATTENDEE", v.Properties)); attendee != `ATTENDEE;CN="Doe, John";DELEGATED-FROM="mailto:a@example.com","mailto:b@example.com":mailto:john@example.com` {
+		t.Errorf("unexpected attendee %s", attendee)
+	}
+
+	if _, err := UnmarshalJCal([]byte(`["vcalendar",[]]`), nil); err == nil {
+		t.Error("expected an error for an invalid component")
+	}
+}
@@ -0,0 +1,1354 @@
+package ical
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsLocalLayout is the layout of a LocalDateTime, from rfc8984-1.4.4
+const jsLocalLayout = "2006-01-02T15:04:05"
+
+// A JSGroup represents a JSCalendar Group, a collection of Events and Tasks
+// from rfc8984-5.3
+type JSGroup struct {
+	Type    string        `json:"@type"`
+	UID     string        `json:"uid"`
+	ProdID  string        `json:"prodId,omitempty"`
+	Title   string        `json:"title,omitempty"`
+	Entries []interface{} `json:"entries"` // *JSEvent or *JSTask
+}
+
+// A JSEvent represents a JSCalendar Event
+// from rfc8984-5.1
+type JSEvent struct {
+	JSCommon
+	Start    string `json:"start,omitempty"`    // LocalDateTime in TimeZone
+	Duration string `json:"duration,omitempty"` // e.g. "PT1H"
+	Status   string `json:"status,omitempty"`   // confirmed, cancelled or tentative
+}
+
+// A JSTask represents a JSCalendar Task
+// from rfc8984-5.2
+type JSTask struct {
+	JSCommon
+	Start             string `json:"start,omitempty"` // LocalDateTime in TimeZone
+	Due               string `json:"due,omitempty"`   // LocalDateTime in TimeZone
+	EstimatedDuration string `json:"estimatedDuration,omitempty"`
+	PercentComplete   int    `json:"percentComplete,omitempty"`
+	Progress          string `json:"progress,omitempty"` // needs-action, in-process, completed, failed or cancelled
+}
+
+// JSCommon holds the properties common to Events and Tasks
+// from rfc8984-4
+type JSCommon struct {
+	Type                string                    `json:"@type"`
+	UID                 string                    `json:"uid"`
+	Created             string                    `json:"created,omitempty"` // UTCDateTime
+	Updated             string                    `json:"updated,omitempty"` // UTCDateTime
+	Sequence            int                       `json:"sequence,omitempty"`
+	Title               string                    `json:"title,omitempty"`
+	Description         string                    `json:"description,omitempty"`
+	Color               string                    `json:"color,omitempty"`
+	Keywords            map[string]bool           `json:"keywords,omitempty"`
+	Locations           map[string]*JSLocation    `json:"locations,omitempty"`
+	TimeZone            string                    `json:"timeZone,omitempty"` // IANA time zone, empty for floating dates
+	ShowWithoutTime     bool                      `json:"showWithoutTime,omitempty"`
+	RecurrenceID        string                    `json:"recurrenceId,omitempty"` // Instance overridden by this object
+	RecurrenceRules     []*JSRecurrenceRule       `json:"recurrenceRules,omitempty"`
+	RecurrenceOverrides map[string]JSPatch        `json:"recurrenceOverrides,omitempty"`
+	Excluded            bool                      `json:"excluded,omitempty"`
+	Priority            int                       `json:"priority,omitempty"`
+	FreeBusyStatus      string                    `json:"freeBusyStatus,omitempty"` // free or busy
+	Privacy             string                    `json:"privacy,omitempty"`        // public, private or secret
+	ReplyTo             map[string]string         `json:"replyTo,omitempty"`
+	Participants        map[string]*JSParticipant `json:"participants,omitempty"`
+	Alerts              map[string]*JSAlert       `json:"alerts,omitempty"`
+}
+
+// A JSPatch sets the properties at the given JSON pointer paths of an
+// object, a null value removing the property
+// from rfc8984-1.4.9
+type JSPatch map[string]interface{}
+
+// A JSLocation represents a JSCalendar Location
+// from rfc8984-4.2.5
+type JSLocation struct {
+	Type        string `json:"@type"`
+	Name        string `json:"name,omitempty"`
+	Coordinates string `json:"coordinates,omitempty"` // geo: URI
+}
+
+// A JSParticipant represents a JSCalendar Participant
+// from rfc8984-4.4.6
+type JSParticipant struct {
+	Type                string            `json:"@type"`
+	Name                string            `json:"name,omitempty"`
+	Email               string            `json:"email,omitempty"`
+	SendTo              map[string]string `json:"sendTo,omitempty"`
+	Kind                string            `json:"kind,omitempty"`
+	Roles               map[string]bool   `json:"roles,omitempty"`
+	ParticipationStatus string            `json:"participationStatus,omitempty"`
+	ExpectReply         bool              `json:"expectReply,omitempty"`
+	DelegatedTo         map[string]bool   `json:"delegatedTo,omitempty"`
+	DelegatedFrom       map[string]bool   `json:"delegatedFrom,omitempty"`
+}
+
+// A JSAlert represents a JSCalendar Alert
+// from rfc8984-4.5.2
+type JSAlert struct {
+	Type         string     `json:"@type"`
+	Trigger      *JSTrigger `json:"trigger"`
+	Acknowledged string     `json:"acknowledged,omitempty"` // UTCDateTime
+	Action       string     `json:"action,omitempty"`       // display or email
+}
+
+// A JSTrigger is the OffsetTrigger or the AbsoluteTrigger of an alert
+// from rfc8984-4.5.2
+type JSTrigger struct {
+	Type       string `json:"@type"`
+	Offset     string `json:"offset,omitempty"`     // OffsetTrigger, e.g. "-PT10M"
+	RelativeTo string `json:"relativeTo,omitempty"` // OffsetTrigger, start or end
+	When       string `json:"when,omitempty"`       // AbsoluteTrigger, UTCDateTime
+}
+
+// A JSRecurrenceRule represents a JSCalendar RecurrenceRule
+// from rfc8984-4.3.3
+type JSRecurrenceRule struct {
+	Type           string   `json:"@type"`
+	Frequency      string   `json:"frequency"`
+	Interval       int      `json:"interval,omitempty"`
+	FirstDayOfWeek string   `json:"firstDayOfWeek,omitempty"`
+	ByDay          []JSNDay `json:"byDay,omitempty"`
+	ByMonthDay     []int    `json:"byMonthDay,omitempty"`
+	ByMonth        []string `json:"byMonth,omitempty"`
+	ByYearDay      []int    `json:"byYearDay,omitempty"`
+	ByWeekNo       []int    `json:"byWeekNo,omitempty"`
+	ByHour         []int    `json:"byHour,omitempty"`
+	ByMinute       []int    `json:"byMinute,omitempty"`
+	BySecond       []int    `json:"bySecond,omitempty"`
+	BySetPosition  []int    `json:"bySetPosition,omitempty"`
+	Count          int      `json:"count,omitempty"`
+	Until          string   `json:"until,omitempty"` // LocalDateTime in the time zone of the object
+}
+
+// A JSNDay is a day of the week of a recurrence rule, e.g. the last Sunday
+// for {"day": "su", "nthOfPeriod": -1}
+type JSNDay struct {
+	Type        string `json:"@type,omitempty"`
+	Day         string `json:"day"`
+	NthOfPeriod int    `json:"nthOfPeriod,omitempty"`
+}
+
+var (
+	jsPrivacy = map[string]string{"PUBLIC": "public", "PRIVATE": "private", "CONFIDENTIAL": "secret"}
+	jsKinds   = map[string]string{"INDIVIDUAL": "individual", "GROUP": "group", "RESOURCE": "resource", "ROOM": "location", "UNKNOWN": "unknown"}
+	jsRoles   = map[string][]string{
+		"CHAIR":           {"attendee", "chair"},
+		"REQ-PARTICIPANT": {"attendee"},
+		"OPT-PARTICIPANT": {"attendee", "optional"},
+		"NON-PARTICIPANT": {"informational"},
+	}
+)
+
+// NewJSGroup converts a calendar to a Group of Events and Tasks. The events
+// and todos overriding an instance of a recurring one are folded into its
+// recurrence overrides.
+func NewJSGroup(c *Calendar) *JSGroup {
+	g := &JSGroup{Type: "Group", ProdID: c.Prodid, Entries: make([]interface{}, 0)}
+	if prop := getProperty("UID", c.Properties); prop != nil {
+		g.UID = prop.Value
+	} else {
+		g.UID = newUID()
+	}
+	for _, name := range []string{"X-WR-CALNAME", "NAME"} {
+		if prop := getProperty(name, c.Properties); prop != nil {
+			g.Title = unescapeText(prop.Value)
+		}
+	}
+
+	recurring := make(map[string]bool)
+	for _, v := range c.Events {
+		if v.RecurrenceID.IsZero() {
+			recurring[v.UID] = true
+		}
+	}
+	overrides := make(map[string][]*Event)
+	for _, v := range c.Events {
+		if !v.RecurrenceID.IsZero() && recurring[v.UID] {
+			overrides[v.UID] = append(overrides[v.UID], v)
+		}
+	}
+	for _, v := range c.Events {
+		switch {
+		case v.RecurrenceID.IsZero():
+			g.Entries = append(g.Entries, NewJSEvent(v, overrides[v.UID]...))
+		case !recurring[v.UID]:
+			g.Entries = append(g.Entries, NewJSEvent(v))
+		}
+	}
+
+	recurring = make(map[string]bool)
+	for _, t := range c.Todos {
+		if t.RecurrenceID.IsZero() {
+			recurring[t.UID] = true
+		}
+	}
+	todoOverrides := make(map[string][]*Todo)
+	for _, t := range c.Todos {
+		if !t.RecurrenceID.IsZero() && recurring[t.UID] {
+			todoOverrides[t.UID] = append(todoOverrides[t.UID], t)
+		}
+	}
+	for _, t := range c.Todos {
+		switch {
+		case t.RecurrenceID.IsZero():
+			g.Entries = append(g.Entries, NewJSTask(t, todoOverrides[t.UID]...))
+		case !recurring[t.UID]:
+			g.Entries = append(g.Entries, NewJSTask(t))
+		}
+	}
+	return g
+}
+
+// Calendar converts a Group to a calendar, with the events and todos of its
+// entries. Time zones are referenced by their IANA name, with a VTIMEZONE
+// built from the time zone database for the years of their dates.
+// if the time.Location parameter is not set, floating dates default to the system location
+func (g *JSGroup) Calendar(l *time.Location) (*Calendar, error) {
+	c := NewCalendar()
+	c.Prodid = g.ProdID
+	if c.Prodid == "" {
+		c.Prodid = ProdID
+	}
+	c.Version = "2.0"
+	setProperty("PRODID", c.Prodid, &c.Properties)
+	setProperty("VERSION", c.Version, &c.Properties)
+	if g.UID != "" {
+		setProperty("UID", g.UID, &c.Properties)
+	}
+	if g.Title != "" {
+		setProperty("NAME", escapeText(g.Title), &c.Properties)
+	}
+
+	for _, entry := range g.Entries {
+		switch e := entry.(type) {
+		case *JSEvent:
+			events, err := e.Events(l)
+			if err != nil {
+				return nil, err
+			}
+			c.Events = append(c.Events, events...)
+		case *JSTask:
+			todos, err := e.Todos(l)
+			if err != nil {
+				return nil, err
+			}
+			c.Todos = append(c.Todos, todos...)
+		default:
+			return nil, fmt.Errorf("unsupported JSCalendar entry %T", entry)
+		}
+	}
+	if err := addTimezones(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UnmarshalJSON decodes a Group, its entries as *JSEvent or *JSTask by
+// their @type
+func (g *JSGroup) UnmarshalJSON(data []byte) error {
+	var group struct {
+		Type    string            `json:"@type"`
+		UID     string            `json:"uid"`
+		ProdID  string            `json:"prodId"`
+		Title   string            `json:"title"`
+		Entries []json.RawMessage `json:"entries"`
+	}
+	if err := json.Unmarshal(data, &group); err != nil {
+		return err
+	}
+	g.Type, g.UID, g.ProdID, g.Title = group.Type, group.UID, group.ProdID, group.Title
+
+	g.Entries = make([]interface{}, 0, len(group.Entries))
+	for _, raw := range group.Entries {
+		var entry struct {
+			Type string `json:"@type"`
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		var e interface{}
+		switch entry.Type {
+		case "Event":
+			e = &JSEvent{}
+		case "Task":
+			e = &JSTask{}
+		default:
+			return fmt.Errorf("unsupported JSCalendar entry %q", entry.Type)
+		}
+		if err := json.Unmarshal(raw, e); err != nil {
+			return err
+		}
+		g.Entries = append(g.Entries, e)
+	}
+	return nil
+}
+
+// NewJSEvent converts an event to an Event. The overrides are the events
+// overriding some instances of a recurring event, turned into patches of
+// its recurrence overrides.
+func NewJSEvent(v *Event, overrides ...*Event) *JSEvent {
+	e := &JSEvent{JSCommon: newJSCommon("Event", v.Properties, v.Alarms)}
+
+	zone := newJSZone(getProperty("DTSTART", v.Properties), v.StartDate)
+	e.TimeZone, e.ShowWithoutTime = zone.name, zone.date
+	if !v.StartDate.IsZero() {
+		e.Start = zone.local(v.StartDate)
+	}
+	if prop := getProperty("DURATION", v.Properties); prop != nil {
+		e.Duration = prop.Value
+	} else if end := v.occurrence(v.StartDate).End; end.After(v.StartDate) {
+		e.Duration = formatDuration(end.Sub(v.StartDate))
+	}
+	if prop := getProperty("STATUS", v.Properties); prop != nil {
+		e.Status = strings.ToLower(prop.Value)
+	}
+	if prop := getProperty("TRANSP", v.Properties); prop != nil && prop.Value == "TRANSPARENT" {
+		e.FreeBusyStatus = "free"
+	}
+	if !v.RecurrenceID.IsZero() {
+		e.RecurrenceID = zone.local(v.RecurrenceID)
+	}
+	e.recurrence(v.Properties, v.RDates, v.ExDates, zone)
+
+	for _, o := range overrides {
+		key := zone.local(o.RecurrenceID)
+		e.override(key, jsPatch(e, NewJSEvent(o), "start", key))
+	}
+	return e
+}
+
+// Events converts an Event to events: the event itself, followed by an
+// event for each instance overridden by a patch.
+// if the time.Location parameter is not set, floating dates default to the system location
+func (e *JSEvent) Events(l *time.Location) ([]*Event, error) {
+	if l == nil {
+		l = time.Local
+	}
+
+	v, err := e.event(l)
+	if err != nil {
+		return nil, err
+	}
+	events := []*Event{v}
+
+	for _, key := range e.patched() {
+		var o JSEvent
+		if err := jsInstance(e, key, e.RecurrenceOverrides[key], "start", &o); err != nil {
+			return nil, err
+		}
+		instance, err := o.event(l)
+		if err != nil {
+			return nil, err
+		}
+		if !instance.RecurrenceID.Equal(v.StartDate) && !jsOccurs(v.RRule, v.StartDate, instance.RecurrenceID) {
+			v.RDates = append(v.RDates, instance.RecurrenceID)
+			v.Properties = append(v.Properties, jsDateProperty("RDATE", instance.RecurrenceID, newJSZoneOf(e.TimeZone, e.ShowWithoutTime)))
+		}
+		events = append(events, instance)
+	}
+	return events, nil
+}
+
+// event converts an Event, without the instances overridden by a patch
+func (e *JSEvent) event(l *time.Location) (*Event, error) {
+	zone := newJSZoneOf(e.TimeZone, e.ShowWithoutTime)
+	properties, err := e.properties()
+	if err != nil {
+		return nil, err
+	}
+	if e.Start != "" {
+		prop, err := jsLocalProperty("DTSTART", e.Start, zone)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, prop)
+	}
+	if e.Duration != "" {
+		properties = append(properties, jsProperty("DURATION", e.Duration))
+	}
+	if e.Status != "" {
+		properties = append(properties, jsProperty("STATUS", strings.ToUpper(e.Status)))
+	}
+	if e.FreeBusyStatus == "free" {
+		properties = append(properties, jsProperty("TRANSP", "TRANSPARENT"))
+	}
+	recurrence, err := e.recurrenceProperties(zone)
+	if err != nil {
+		return nil, err
+	}
+	properties = append(properties, recurrence...)
+
+	v := NewEvent()
+	v.Properties = properties
+	if v.Alarms, err = e.alarms(l); err != nil {
+		return nil, err
+	}
+	if err := decodeEvent(v, "", l); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewJSTask converts a todo to a Task. The overrides are the todos
+// overriding some instances of a recurring todo, turned into patches of its
+// recurrence overrides.
+func NewJSTask(t *Todo, overrides ...*Todo) *JSTask {
+	task := &JSTask{JSCommon: newJSCommon("Task", t.Properties, t.Alarms)}
+
+	anchor, date := "start", t.StartDate
+	prop := getProperty("DTSTART", t.Properties)
+	if prop == nil {
+		anchor, date = "due", t.DueDate
+		prop = getProperty("DUE", t.Properties)
+	}
+	zone := newJSZone(prop, date)
+	task.TimeZone, task.ShowWithoutTime = zone.name, zone.date
+	if !t.StartDate.IsZero() {
+		task.Start = zone.local(t.StartDate)
+	}
+	if !t.DueDate.IsZero() {
+		task.Due = zone.local(t.DueDate)
+	}
+	if prop := getProperty("DURATION", t.Properties); prop != nil {
+		task.EstimatedDuration = prop.Value
+	}
+	if prop := getProperty("PERCENT-COMPLETE", t.Properties); prop != nil {
+		task.PercentComplete, _ = strconv.Atoi(prop.Value)
+	}
+	if prop := getProperty("STATUS", t.Properties); prop != nil {
+		task.Progress = strings.ToLower(prop.Value)
+	}
+	if !t.RecurrenceID.IsZero() {
+		task.RecurrenceID = zone.local(t.RecurrenceID)
+	}
+	task.recurrence(t.Properties, t.RDates, t.ExDates, zone)
+
+	for _, o := range overrides {
+		key := zone.local(o.RecurrenceID)
+		task.override(key, jsPatch(task, NewJSTask(o), anchor, key))
+	}
+	return task
+}
+
+// Todos converts a Task to todos: the todo itself, followed by a todo for
+// each instance overridden by a patch.
+// if the time.Location parameter is not set, floating dates default to the system location
+func (t *JSTask) Todos(l *time.Location) ([]*Todo, error) {
+	if l == nil {
+		l = time.Local
+	}
+
+	todo, err := t.todo(l)
+	if err != nil {
+		return nil, err
+	}
+	todos := []*Todo{todo}
+
+	anchor, start := "start", todo.StartDate
+	if t.Start == "" {
+		anchor, start = "due", todo.DueDate
+	}
+	for _, key := range t.patched() {
+		var o JSTask
+		if err := jsInstance(t, key, t.RecurrenceOverrides[key], anchor, &o); err != nil {
+			return nil, err
+		}
+		instance, err := o.todo(l)
+		if err != nil {
+			return nil, err
+		}
+		if !instance.RecurrenceID.Equal(start) && !jsOccurs(todo.RRule, start, instance.RecurrenceID) {
+			todo.RDates = append(todo.RDates, instance.RecurrenceID)
+			todo.Properties = append(todo.Properties, jsDateProperty("RDATE", instance.RecurrenceID, newJSZoneOf(t.TimeZone, t.ShowWithoutTime)))
+		}
+		todos = append(todos, instance)
+	}
+	return todos, nil
+}
+
+// todo converts a Task, without the instances overridden by a patch
+func (t *JSTask) todo(l *time.Location) (*Todo, error) {
+	zone := newJSZoneOf(t.TimeZone, t.ShowWithoutTime)
+	properties, err := t.properties()
+	if err != nil {
+		return nil, err
+	}
+	for _, date := range []struct{ name, value string }{{"DTSTART", t.Start}, {"DUE", t.Due}} {
+		if date.value == "" {
+			continue
+		}
+		prop, err := jsLocalProperty(date.name, date.value, zone)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, prop)
+	}
+	if t.EstimatedDuration != "" {
+		properties = append(properties, jsProperty("DURATION", t.EstimatedDuration))
+	}
+	if t.PercentComplete > 0 {
+		properties = append(properties, jsProperty("PERCENT-COMPLETE", strconv.Itoa(t.PercentComplete)))
+	}
+	if t.Progress != "" {
+		properties = append(properties, jsProperty("STATUS", strings.ToUpper(t.Progress)))
+	}
+	recurrence, err := t.recurrenceProperties(zone)
+	if err != nil {
+		return nil, err
+	}
+	properties = append(properties, recurrence...)
+
+	todo := NewTodo()
+	todo.Properties = properties
+	if todo.Alarms, err = t.alarms(l); err != nil {
+		return nil, err
+	}
+	if err := decodeTodo(todo, "", l); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// newJSCommon converts the properties common to events and todos
+func newJSCommon(typ string, properties []*Property, alarms []*Alarm) JSCommon {
+	c := JSCommon{Type: typ}
+	ids := make(map[string]string)
+	participant := func(address string) *JSParticipant {
+		for a, id := range ids {
+			if sameAddress(a, address) {
+				return c.Participants[id]
+			}
+		}
+		if c.Participants == nil {
+			c.Participants = make(map[string]*JSParticipant)
+		}
+		id := strconv.Itoa(len(ids) + 1)
+		ids[address] = id
+		p := &JSParticipant{Type: "Participant", SendTo: map[string]string{"imip": address}, Roles: make(map[string]bool)}
+		if strings.HasPrefix(strings.ToLower(address), "mailto:") {
+			p.Email = address[len("mailto:"):]
+		}
+		c.Participants[id] = p
+		return p
+	}
+	location := func(id string) *JSLocation {
+		if c.Locations == nil {
+			c.Locations = make(map[string]*JSLocation)
+		}
+		if c.Locations[id] == nil {
+			c.Locations[id] = &JSLocation{Type: "Location"}
+		}
+		return c.Locations[id]
+	}
+
+	for _, prop := range properties {
+		switch prop.Name {
+		case "UID":
+			c.UID = prop.Value
+		case "SEQUENCE":
+			c.Sequence, _ = strconv.Atoi(prop.Value)
+		case "CREATED":
+			c.Created = jsUTC(prop)
+		case "DTSTAMP":
+			if c.Updated == "" {
+				c.Updated = jsUTC(prop)
+			}
+		case "LAST-MODIFIED":
+			c.Updated = jsUTC(prop)
+		case "SUMMARY":
+			c.Title = unescapeText(prop.Value)
+		case "DESCRIPTION":
+			c.Description = unescapeText(prop.Value)
+		case "COLOR":
+			c.Color = prop.Value
+		case "PRIORITY":
+			c.Priority, _ = strconv.Atoi(prop.Value)
+		case "CLASS":
+			c.Privacy = jsPrivacy[prop.Value]
+		case "CATEGORIES":
+			if c.Keywords == nil {
+				c.Keywords = make(map[string]bool)
+			}
+			for _, keyword := range splitText(prop.Value, ',') {
+				c.Keywords[keyword] = true
+			}
+		case "LOCATION":
+			location("1").Name = unescapeText(prop.Value)
+		case "GEO":
+			location("1").Coordinates = "geo:" + strings.Replace(prop.Value, ";", ",", 1)
+		case "X-JS-LOCATION":
+			id := paramValue(prop, "X-ID")
+			if id == "" || id == "1" {
+				id = "x" + strconv.Itoa(len(c.Locations)+1)
+			}
+			l := location(id)
+			l.Name = unescapeText(prop.Value)
+			l.Coordinates = paramValue(prop, "X-COORDINATES")
+		case "ORGANIZER":
+			c.ReplyTo = map[string]string{"imip": prop.Value}
+			p := participant(prop.Value)
+			if cn := paramValue(prop, "CN"); cn != "" {
+				p.Name = cn
+			}
+			p.Roles["owner"] = true
+		case "ATTENDEE":
+			p := participant(prop.Value)
+			if cn := paramValue(prop, "CN"); cn != "" {
+				p.Name = cn
+			}
+			role := paramValue(prop, "ROLE")
+			if _, ok := jsRoles[role]; !ok {
+				role = "REQ-PARTICIPANT"
+			}
+			for _, r := range jsRoles[role] {
+				p.Roles[r] = true
+			}
+			p.ParticipationStatus = strings.ToLower(paramValue(prop, "PARTSTAT"))
+			p.ExpectReply = paramValue(prop, "RSVP") == "TRUE"
+			p.Kind = jsKinds[paramValue(prop, "CUTYPE")]
+		}
+	}
+
+	// delegates are referenced by their participant id
+	for _, prop := range properties {
+		if prop.Name != "ATTENDEE" {
+			continue
+		}
+		p := participant(prop.Value)
+		for _, param := range []string{"DELEGATED-TO", "DELEGATED-FROM"} {
+			if prop.Params[param] == nil {
+				continue
+			}
+			delegates := make(map[string]bool)
+			for _, address := range prop.Params[param].Values {
+				for a, id := range ids {
+					if sameAddress(a, address) {
+						delegates[id] = true
+					}
+				}
+			}
+			if param == "DELEGATED-TO" {
+				p.DelegatedTo = delegates
+			} else {
+				p.DelegatedFrom = delegates
+			}
+		}
+	}
+
+	for i, a := range alarms {
+		if c.Alerts == nil {
+			c.Alerts = make(map[string]*JSAlert)
+		}
+		alert := &JSAlert{Type: "Alert", Action: "display"}
+		if a.Action == "EMAIL" {
+			alert.Action = "email"
+		}
+		if !a.TriggerDate.IsZero() {
+			alert.Trigger = &JSTrigger{Type: "AbsoluteTrigger", When: a.TriggerDate.UTC().Format(time.RFC3339)}
+		} else {
+			alert.Trigger = &JSTrigger{Type: "OffsetTrigger", Offset: a.Trigger}
+			if a.Related == "END" {
+				alert.Trigger.RelativeTo = "end"
+			}
+		}
+		if !a.Acknowledged.IsZero() {
+			alert.Acknowledged = a.Acknowledged.UTC().Format(time.RFC3339)
+		}
+		c.Alerts[strconv.Itoa(i+1)] = alert
+	}
+	return c
+}
+
+// recurrence converts the recurrence rules and dates: a recurrence date is
+// an empty patch, an exception date excludes the instance
+func (c *JSCommon) recurrence(properties []*Property, rdates, exdates []time.Time, zone jsZone) {
+	for _, prop := range properties {
+		if prop.Name == "RRULE" {
+			c.RecurrenceRules = append(c.RecurrenceRules, newJSRecurrenceRule(prop.Value, zone))
+		}
+	}
+	for _, t := range rdates {
+		c.override(zone.local(t), JSPatch{})
+	}
+	for _, t := range exdates {
+		c.override(zone.local(t), JSPatch{"excluded": true})
+	}
+}
+
+// override sets the patch of an instance
+func (c *JSCommon) override(key string, patch JSPatch) {
+	if c.RecurrenceOverrides == nil {
+		c.RecurrenceOverrides = make(map[string]JSPatch)
+	}
+	c.RecurrenceOverrides[key] = patch
+}
+
+// patched returns the sorted instances overridden by a patch, neither added
+// nor excluded only
+func (c *JSCommon) patched() []string {
+	var keys []string
+	for key, patch := range c.RecurrenceOverrides {
+		if len(patch) > 0 && patch["excluded"] != true {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// properties converts the properties common to Events and Tasks
+func (c *JSCommon) properties() ([]*Property, error) {
+	properties := []*Property{jsProperty("UID", c.UID)}
+
+	stamp := time.Now().UTC().Truncate(time.Second)
+	if c.Updated != "" {
+		t, err := time.Parse(time.RFC3339, c.Updated)
+		if err != nil {
+			return nil, fmt.Errorf("invalid updated %q", c.Updated)
+		}
+		stamp = t
+	}
+	properties = append(properties, jsProperty("DTSTAMP", formatDateTime(stamp)))
+	if c.Created != "" {
+		t, err := time.Parse(time.RFC3339, c.Created)
+		if err != nil {
+			return nil, fmt.Errorf("invalid created %q", c.Created)
+		}
+		properties = append(properties, jsProperty("CREATED", formatDateTime(t)))
+	}
+	if c.Sequence > 0 {
+		properties = append(properties, jsProperty("SEQUENCE", strconv.Itoa(c.Sequence)))
+	}
+	if c.Title != "" {
+		properties = append(properties, jsProperty("SUMMARY", escapeText(c.Title)))
+	}
+	if c.Description != "" {
+		properties = append(properties, jsProperty("DESCRIPTION", escapeText(c.Description)))
+	}
+	for class, privacy := range jsPrivacy {
+		if c.Privacy == privacy {
+			properties = append(properties, jsProperty("CLASS", class))
+		}
+	}
+	if c.Priority > 0 {
+		properties = append(properties, jsProperty("PRIORITY", strconv.Itoa(c.Priority)))
+	}
+	if c.Color != "" {
+		properties = append(properties, jsProperty("COLOR", c.Color))
+	}
+	if len(c.Keywords) > 0 {
+		var keywords []string
+		for keyword, ok := range c.Keywords {
+			if ok {
+				keywords = append(keywords, escapeText(keyword))
+			}
+		}
+		sort.Strings(keywords)
+		properties = append(properties, jsProperty("CATEGORIES", strings.Join(keywords, ",")))
+	}
+	// LOCATION and GEO hold a single location, the others are X-JS-LOCATION
+	for i, id := range sortedIDs(c.Locations) {
+		location := c.Locations[id]
+		if i > 0 {
+			prop := jsProperty("X-JS-LOCATION", escapeText(location.Name))
+			prop.Params["X-ID"] = &Param{Values: []string{id}}
+			if location.Coordinates != "" {
+				prop.Params["X-COORDINATES"] = &Param{Values: []string{location.Coordinates}}
+			}
+			properties = append(properties, prop)
+			continue
+		}
+		if location.Name != "" {
+			properties = append(properties, jsProperty("LOCATION", escapeText(location.Name)))
+		}
+		if strings.HasPrefix(location.Coordinates, "geo:") {
+			geo := strings.SplitN(strings.TrimPrefix(location.Coordinates, "geo:"), ";", 2)[0]
+			properties = append(properties, jsProperty("GEO", strings.Replace(geo, ",", ";", 1)))
+		}
+	}
+	properties = append(properties, c.participantProperties()...)
+	return properties, nil
+}
+
+// participantProperties converts the participants to an ORGANIZER, the
+// owner, and ATTENDEE properties
+func (c *JSCommon) participantProperties() []*Property {
+	ids := sortedIDs(c.Participants)
+	addresses := make(map[string]string, len(ids))
+	for _, id := range ids {
+		addresses[id] = c.Participants[id].address()
+	}
+
+	var properties []*Property
+	organizer := false
+	for _, id := range ids {
+		p := c.Participants[id]
+		if !p.Roles["owner"] || addresses[id] == "" || organizer {
+			continue
+		}
+		prop := jsProperty("ORGANIZER", addresses[id])
+		if p.Name != "" {
+			prop.Params["CN"] = &Param{Values: []string{p.Name}}
+		}
+		properties = append(properties, prop)
+		organizer = true
+	}
+	if !organizer && c.ReplyTo["imip"] != "" {
+		properties = append(properties, jsProperty("ORGANIZER", c.ReplyTo["imip"]))
+	}
+
+	for _, id := range ids {
+		p := c.Participants[id]
+		if addresses[id] == "" || (p.Roles["owner"] && !p.Roles["attendee"] && !p.Roles["informational"]) {
+			continue
+		}
+		prop := jsProperty("ATTENDEE", addresses[id])
+		params := map[string]string{"CN": p.Name, "PARTSTAT": strings.ToUpper(p.ParticipationStatus)}
+		switch {
+		case p.Roles["chair"]:
+			params["ROLE"] = "CHAIR"
+		case p.Roles["informational"]:
+			params["ROLE"] = "NON-PARTICIPANT"
+		case p.Roles["optional"]:
+			params["ROLE"] = "OPT-PARTICIPANT"
+		}
+		if p.ExpectReply {
+			params["RSVP"] = "TRUE"
+		}
+		for cutype, kind := range jsKinds {
+			if p.Kind == kind {
+				params["CUTYPE"] = cutype
+			}
+		}
+		for name, value := range params {
+			if value != "" {
+				prop.Params[name] = &Param{Values: []string{value}}
+			}
+		}
+		for name, delegates := range map[string]map[string]bool{"DELEGATED-TO": p.DelegatedTo, "DELEGATED-FROM": p.DelegatedFrom} {
+			var values []string
+			for _, delegate := range ids {
+				if delegates[delegate] && addresses[delegate] != "" {
+					values = append(values, addresses[delegate])
+				}
+			}
+			if len(values) > 0 {
+				prop.Params[name] = &Param{Values: values}
+			}
+		}
+		properties = append(properties, prop)
+	}
+	return properties
+}
+
+// address returns the calendar user address of a participant
+func (p *JSParticipant) address() string {
+	if p.SendTo["imip"] != "" {
+		return p.SendTo["imip"]
+	}
+	if p.Email != "" {
+		return "mailto:" + p.Email
+	}
+	return ""
+}
+
+// recurrenceProperties converts the recurrence rules and overrides to
+// RRULE, RDATE, EXDATE and RECURRENCE-ID properties
+func (c *JSCommon) recurrenceProperties(zone jsZone) ([]*Property, error) {
+	var properties []*Property
+	for _, rule := range c.RecurrenceRules {
+		value, err := rule.value(zone)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, jsProperty("RRULE", value))
+	}
+
+	var keys []string
+	for key := range c.RecurrenceOverrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		patch := c.RecurrenceOverrides[key]
+		name := ""
+		switch {
+		case len(patch) == 0:
+			name = "RDATE"
+		case patch["excluded"] == true:
+			name = "EXDATE"
+		default:
+			continue
+		}
+		prop, err := jsLocalProperty(name, key, zone)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, prop)
+	}
+
+	if c.RecurrenceID != "" {
+		prop, err := jsLocalProperty("RECURRENCE-ID", c.RecurrenceID, zone)
+		if err != nil {
+			return nil, err
+		}
+		properties = append(properties, prop)
+	}
+	return properties, nil
+}
+
+// alarms converts the alerts to alarms. An email alert is sent to the owner
+// of the object, it's displayed when there is none.
+func (c *JSCommon) alarms(l *time.Location) ([]*Alarm, error) {
+	var owners []string
+	for _, id := range sortedIDs(c.Participants) {
+		if participant := c.Participants[id]; participant.Roles["owner"] && participant.address() != "" {
+			owners = append(owners, participant.address())
+		}
+	}
+	description := escapeText(c.Title)
+	if description == "" {
+		description = "Reminder"
+	}
+
+	alarms := make([]*Alarm, 0, len(c.Alerts))
+	for _, id := range sortedIDs(c.Alerts) {
+		alert := c.Alerts[id]
+		if alert.Trigger == nil {
+			return nil, fmt.Errorf("missing trigger in alert %q", id)
+		}
+
+		a := NewAlarm()
+		if alert.Action == "email" && len(owners) > 0 {
+			a.Properties = append(a.Properties, jsProperty("ACTION", "EMAIL"), jsProperty("SUMMARY", description))
+			for _, owner := range owners {
+				a.Properties = append(a.Properties, jsProperty("ATTENDEE", owner))
+			}
+		} else {
+			a.Properties = append(a.Properties, jsProperty("ACTION", "DISPLAY"))
+		}
+		a.Properties = append(a.Properties, jsProperty("DESCRIPTION", description))
+
+		switch alert.Trigger.Type {
+		case "AbsoluteTrigger":
+			t, err := time.Parse(time.RFC3339, alert.Trigger.When)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trigger %q in alert %q", alert.Trigger.When, id)
+			}
+			prop := jsProperty("TRIGGER", formatDateTime(t))
+			prop.Params["VALUE"] = &Param{Values: []string{"DATE-TIME"}}
+			a.Properties = append(a.Properties, prop)
+		default:
+			offset := alert.Trigger.Offset
+			if offset == "" {
+				offset = "PT0S"
+			}
+			prop := jsProperty("TRIGGER", offset)
+			if alert.Trigger.RelativeTo == "end" {
+				prop.Params["RELATED"] = &Param{Values: []string{"END"}}
+			}
+			a.Properties = append(a.Properties, prop)
+		}
+		if alert.Acknowledged != "" {
+			t, err := time.Parse(time.RFC3339, alert.Acknowledged)
+			if err != nil {
+				return nil, fmt.Errorf("invalid acknowledged %q in alert %q", alert.Acknowledged, id)
+			}
+			a.Properties = append(a.Properties, jsProperty("ACKNOWLEDGED", formatDateTime(t)))
+		}
+
+		if err := decodeAlarm(a, l); err != nil {
+			return nil, err
+		}
+		alarms = append(alarms, a)
+	}
+	return alarms, nil
+}
+
+// newJSRecurrenceRule converts the value of a RRULE property
+func newJSRecurrenceRule(value string, zone jsZone) *JSRecurrenceRule {
+	r := &JSRecurrenceRule{Type: "RecurrenceRule"}
+	names, parts := splitRecur(value)
+	for _, name := range names {
+		values := parts[name]
+		switch name {
+		case "freq":
+			r.Frequency = strings.ToLower(values[0])
+		case "interval":
+			r.Interval, _ = strconv.Atoi(values[0])
+		case "count":
+			r.Count, _ = strconv.Atoi(values[0])
+		case "until":
+			r.Until = zone.until(values[0])
+		case "wkst":
+			r.FirstDayOfWeek = strings.ToLower(values[0])
+		case "byday":
+			for _, v := range values {
+				day := JSNDay{Type: "NDay", Day: strings.ToLower(v[len(v)-2:])}
+				day.NthOfPeriod, _ = strconv.Atoi(v[:len(v)-2])
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "bymonth":
+			r.ByMonth = values
+		case "bymonthday":
+			r.ByMonthDay = jsInts(values)
+		case "byyearday":
+			r.ByYearDay = jsInts(values)
+		case "byweekno":
+			r.ByWeekNo = jsInts(values)
+		case "byhour":
+			r.ByHour = jsInts(values)
+		case "byminute":
+			r.ByMinute = jsInts(values)
+		case "bysecond":
+			r.BySecond = jsInts(values)
+		case "bysetpos":
+			r.BySetPosition = jsInts(values)
+		}
+	}
+	return r
+}
+
+// value returns the value of a RRULE property. UNTIL is in UTC when the
+// object has a time zone, from rfc5545-3.3.10
+func (r *JSRecurrenceRule) value(zone jsZone) (string, error) {
+	if r.Frequency == "" {
+		return "", fmt.Errorf("missing frequency in recurrence rule")
+	}
+	rule := []string{"FREQ=" + strings.ToUpper(r.Frequency)}
+	if r.Until != "" {
+		t, err := time.ParseInLocation(jsLocalLayout, r.Until, zone.location())
+		if err != nil {
+			return "", fmt.Errorf("invalid until %q", r.Until)
+		}
+		switch {
+		case zone.date:
+			rule = append(rule, "UNTIL="+t.Format(dateLayout))
+		case zone.name != "":
+			rule = append(rule, "UNTIL="+formatDateTime(t))
+		default:
+			rule = append(rule, "UNTIL="+t.Format(dateTimeLayoutLocalized))
+		}
+	}
+	if r.Count > 0 {
+		rule = append(rule, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Interval > 0 {
+		rule = append(rule, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	var days []string
+	for _, day := range r.ByDay {
+		n := ""
+		if day.NthOfPeriod != 0 {
+			n = strconv.Itoa(day.NthOfPeriod)
+		}
+		days = append(days, n+strings.ToUpper(day.Day))
+	}
+	for _, part := range []struct {
+		name   string
+		values []string
+	}{
+		{"BYSECOND", jsStrings(r.BySecond)},
+		{"BYMINUTE", jsStrings(r.ByMinute)},
+		{"BYHOUR", jsStrings(r.ByHour)},
+		{"BYDAY", days},
+		{"BYMONTHDAY", jsStrings(r.ByMonthDay)},
+		{"BYYEARDAY", jsStrings(r.ByYearDay)},
+		{"BYWEEKNO", jsStrings(r.ByWeekNo)},
+		{"BYMONTH", r.ByMonth},
+		{"BYSETPOS", jsStrings(r.BySetPosition)},
+	} {
+		if len(part.values) > 0 {
+			rule = append(rule, part.name+"="+strings.Join(part.values, ","))
+		}
+	}
+	if r.FirstDayOfWeek != "" {
+		rule = append(rule, "WKST="+strings.ToUpper(r.FirstDayOfWeek))
+	}
+	return strings.Join(rule, ";"), nil
+}
+
+// jsZone is the time zone of the dates of an Event or a Task
+type jsZone struct {
+	name string         // IANA time zone, empty for floating dates
+	loc  *time.Location // nil for floating dates
+	date bool           // dates without time
+}
+
+// newJSZone returns the time zone of a date property
+func newJSZone(prop *Property, t time.Time) jsZone {
+	switch {
+	case prop == nil:
+		return jsZone{}
+	case isDate(prop):
+		return jsZone{date: true}
+	case strings.HasSuffix(prop.Value, "Z"):
+		return jsZone{name: "Etc/UTC", loc: time.UTC}
+	case prop.Params["TZID"] != nil:
+		return jsZone{name: prop.Params["TZID"].Values[0], loc: t.Location()}
+	}
+	return jsZone{}
+}
+
+// newJSZoneOf returns the time zone of the timeZone of an object, UTC when
+// unknown as in parseDate
+func newJSZoneOf(name string, date bool) jsZone {
+	if date || name == "" {
+		return jsZone{date: date}
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = time.UTC
+	}
+	return jsZone{name: name, loc: loc}
+}
+
+// utc checks if the time zone is UTC, for dates written in UTC
+func (z jsZone) utc() bool {
+	switch z.name {
+	case "Etc/UTC", "UTC", "Etc/GMT", "GMT":
+		return true
+	}
+	return false
+}
+
+// location returns the location of the dates, UTC for floating dates
+func (z jsZone) location() *time.Location {
+	if z.loc != nil {
+		return z.loc
+	}
+	return time.UTC
+}
+
+// local formats a time as a LocalDateTime in the time zone
+func (z jsZone) local(t time.Time) string {
+	if z.loc != nil {
+		t = t.In(z.loc)
+	}
+	return t.Format(jsLocalLayout)
+}
+
+// until converts the UNTIL of a recurrence rule, as formatted by splitRecur,
+// to a LocalDateTime in the time zone
+func (z jsZone) until(value string) string {
+	if len(value) == len("2006-01-02") {
+		return value + "T00:00:00"
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return z.local(t)
+	}
+	return value
+}
+
+// jsLocalProperty returns a date property from a LocalDateTime
+func jsLocalProperty(name, local string, zone jsZone) (*Property, error) {
+	t, err := time.ParseInLocation(jsLocalLayout, local, zone.location())
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", strings.ToLower(name), local)
+	}
+	return jsDateProperty(name, t, zone), nil
+}
+
+// jsDateProperty returns a date property in the time zone: a date, an UTC
+// date-time, a date-time with a TZID param or a floating date-time
+func jsDateProperty(name string, t time.Time, zone jsZone) *Property {
+	prop := jsProperty(name, "")
+	switch {
+	case zone.date:
+		prop.Value = t.Format(dateLayout)
+		prop.Params["VALUE"] = &Param{Values: []string{"DATE"}}
+	case zone.utc():
+		prop.Value = formatDateTime(t)
+	case zone.name != "":
+		prop.Value = t.In(zone.loc).Format(dateTimeLayoutLocalized)
+		prop.Params["TZID"] = &Param{Values: []string{zone.name}}
+	default:
+		prop.Value = t.Format(dateTimeLayoutLocalized)
+	}
+	return prop
+}
+
+// jsPatch returns the patch turning an object into an instance, property by
+// property. The start of the instance is left out when it's the instance
+// start, its default.
+func jsPatch(object, instance interface{}, anchor, key string) JSPatch {
+	from, to := jsObject(object), jsObject(instance)
+	patch := make(JSPatch)
+	for name, value := range to {
+		if !jsPatchable(name) || (name == anchor && value == key) {
+			continue
+		}
+		if data, _ := json.Marshal(value); string(data) != jsonString(from[name]) {
+			patch[name] = value
+		}
+	}
+	for name := range from {
+		if _, ok := to[name]; !ok && jsPatchable(name) {
+			patch[name] = nil
+		}
+	}
+	return patch
+}
+
+// jsPatchable checks if a property may differ between an object and its
+// instances
+func jsPatchable(name string) bool {
+	switch name {
+	case "@type", "uid", "recurrenceId", "recurrenceRules", "recurrenceOverrides":
+		return false
+	}
+	return true
+}
+
+// jsInstance returns an instance of an object, its start at the
+// recurrence id and the patch applied
+// from rfc8984-4.3.5
+func jsInstance(object interface{}, key string, patch JSPatch, anchor string, instance interface{}) error {
+	m := jsObject(object)
+	delete(m, "recurrenceRules")
+	delete(m, "recurrenceOverrides")
+	m["recurrenceId"] = key
+	m[anchor] = key
+	if err := patch.apply(m); err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, instance)
+}
+
+// apply applies the patch to an object decoded from JSON
+func (p JSPatch) apply(object map[string]interface{}) error {
+	paths := make([]string, 0, len(p))
+	for path := range p {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		keys := strings.Split(strings.TrimPrefix(path, "/"), "/")
+		for i := range keys {
+			keys[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(keys[i])
+		}
+
+		m := object
+		for _, key := range keys[:len(keys)-1] {
+			next, ok := m[key].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("invalid patch path %q", path)
+			}
+			m = next
+		}
+		if value := p[path]; value == nil {
+			delete(m, keys[len(keys)-1])
+		} else {
+			m[keys[len(keys)-1]] = value
+		}
+	}
+	return nil
+}
+
+// jsObject returns the JSON object of a JSCalendar object
+func jsObject(object interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	data, _ := json.Marshal(object)
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// jsonString returns the JSON encoding of a value
+func jsonString(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// jsOccurs checks if the recurrence rule has an instance at a time
+func jsOccurs(rrule *Recur, start, t time.Time) bool {
+	return rrule != nil && len(rrule.Between(start, t, t.Add(time.Second))) > 0
+}
+
+// jsUTC formats the date of a property as an UTCDateTime
+func jsUTC(prop *Property) string {
+	t, err := parseDate(prop, time.UTC)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// jsProperty creates a property without params
+func jsProperty(name, value string) *Property {
+	prop := NewProperty()
+	prop.Name = name
+	prop.Value = value
+	return prop
+}
+
+// paramValue returns the first value of a param, empty if missing
+func paramValue(prop *Property, name string) string {
+	if param, ok := prop.Params[name]; ok && len(param.Values) > 0 {
+		return param.Values[0]
+	}
+	return ""
+}
+
+func jsInts(values []string) []int {
+	ints := make([]int, 0, len(values))
+	for _, v := range values {
+		n, _ := strconv.Atoi(v)
+		ints = append(ints, n)
+	}
+	return ints
+}
+
+func jsStrings(ints []int) []string {
+	var values []string
+	for _, n := range ints {
+		values = append(values, strconv.Itoa(n))
+	}
+	return values
+}
+
+// sortedIDs returns the ids of a map of JSCalendar objects, numeric ids in
+// numeric order
+func sortedIDs(m interface{}) []string {
+	var ids []string
+	switch m := m.(type) {
+	case map[string]*JSLocation:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*JSParticipant:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	case map[string]*JSAlert:
+		for id := range m {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if len(ids[i]) != len(ids[j]) {
+			return len(ids[i]) < len(ids[j])
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
@@ -0,0 +1,207 @@
+package ical
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, filename string) *Calendar {
+	t.Helper()
+	file, _ := os.Open(filename)
+	c, err := Parse(file, nil)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewJSEvent(t *testing.T) {
+	c := parseFixture(t, "fixtures/invitation.ics")
+	e := NewJSEvent(c.Events[0])
+
+	if e.Type != "Event" || e.UID != "meeting@example.com" || e.Title != "Planning" || e.Sequence != 1 {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e.Start != "2019-01-10T10:00:00" || e.TimeZone != "Etc/UTC" || e.Duration != "PT1H" || e.Updated != "2019-01-01T00:00:00Z" {
+		t.Errorf("unexpected dates %s %s %s %s", e.Start, e.TimeZone, e.Duration, e.Updated)
+	}
+	if len(e.RecurrenceRules) != 1 || e.RecurrenceRules[0].Frequency != "weekly" || e.RecurrenceRules[0].Count != 4 {
+		t.Errorf("unexpected recurrence rules %+v", e.RecurrenceRules)
+	}
+	if e.Locations["1"].Name != "Room 1" || e.ReplyTo["imip"] != "mailto:alice@example.com" {
+		t.Errorf("unexpected locations %+v or reply to %v", e.Locations, e.ReplyTo)
+	}
+
+	alice := &JSParticipant{
+		Type:                "Participant",
+		Name:                "Alice",
+		Email:               "alice@example.com",
+		SendTo:              map[string]string{"imip": "mailto:alice@example.com"},
+		Roles:               map[string]bool{"owner": true, "attendee": true},
+		ParticipationStatus: "accepted",
+	}
+	bob := &JSParticipant{
+		Type:                "Participant",
+		Email:               "bob@example.com",
+		SendTo:              map[string]string{"imip": "mailto:bob@example.com"},
+		Roles:               map[string]bool{"attendee": true},
+		ParticipationStatus: "needs-action",
+		ExpectReply:         true,
+	}
+	if len(e.Participants) != 3 || !reflect.DeepEqual(e.Participants["1"], alice) || !reflect.DeepEqual(e.Participants["2"], bob) {
+		t.Errorf("unexpected participants %+v %+v", e.Participants["1"], e.Participants["2"])
+	}
+
+	alert := &JSAlert{Type: "Alert", Action: "display", Trigger: &JSTrigger{Type: "OffsetTrigger", Offset: "-PT10M"}}
+	if !reflect.DeepEqual(e.Alerts, map[string]*JSAlert{"1": alert}) {
+		t.Errorf("unexpected alerts %+v", e.Alerts["1"])
+	}
+
+	events, err := e.Events(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := events[0]
+	if len(events) != 1 || v.UID != e.UID || !v.StartDate.Equal(c.Events[0].StartDate) || !v.EndDate.Equal(c.Events[0].EndDate) || v.RRule.Count != 4 {
+		t.Errorf("unexpected event %+v", v)
+	}
+	for _, want := range []string{
+		"ORGANIZER;CN=Alice:mailto:alice@example.com",
+		"ATTENDEE;CN=Alice;PARTSTAT=ACCEPTED:mailto:alice@example.com",
+		"ATTENDEE;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:bob@example.com",
+		"DTSTART:20190110T100000Z",
+		"RRULE:FREQ=WEEKLY;COUNT=4",
+		"LOCATION:Room 1",
+	} {
+		if !containsString(propertyLines(v.Properties), want) {
+			t.Errorf("missing %s in %v", want, propertyLines(v.Properties))
+		}
+	}
+	if len(v.Alarms) != 1 || v.Alarms[0].Offset != -10*time.Minute {
+		t.Errorf("unexpected alarms %+v", v.Alarms)
+	}
+}
+
+func TestNewJSGroup(t *testing.T) {
+	c := parseFixture(t, "fixtures/recurring.ics")
+	g := NewJSGroup(c)
+
+	if g.Type != "Group" || g.ProdID != "-//test//Recurring//EN" || len(g.Entries) != 4 {
+		t.Fatalf("unexpected group %+v", g)
+	}
+	standup := g.Entries[0].(*JSEvent)
+	if standup.Start != "2019-01-07T09:30:00" || standup.TimeZone != "Europe/Paris" || standup.Duration != "PT15M" {
+		t.Errorf("unexpected standup %+v", standup)
+	}
+	if rule := standup.RecurrenceRules[0]; rule.Frequency != "weekly" || !reflect.DeepEqual(rule.ByDay, []JSNDay{{Type: "NDay", Day: "mo"}, {Type: "NDay", Day: "we"}}) {
+		t.Errorf("unexpected recurrence rule %+v", rule)
+	}
+	overrides := map[string]JSPatch{
+		"2019-01-09T09:30:00": {"excluded": true},
+		"2019-01-14T09:30:00": {"start": "2019-01-14T16:00:00", "title": "Standup (moved)"},
+	}
+	if !reflect.DeepEqual(standup.RecurrenceOverrides, overrides) {
+		t.Errorf("expected overrides %v, got %v", overrides, standup.RecurrenceOverrides)
+	}
+	if holiday := g.Entries[1].(*JSEvent); holiday.Start != "2019-01-10T00:00:00" || !holiday.ShowWithoutTime || holiday.TimeZone != "" || holiday.Duration != "P1D" {
+		t.Errorf("unexpected holiday %+v", holiday)
+	}
+
+	// to JSON and back
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded JSGroup
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	got, err := decoded.Calendar(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Events) != 5 {
+		t.Fatalf("expected 5 events, got %d", len(got.Events))
+	}
+	if len(got.Timezones) != 1 || timezoneID(got.Timezones[0]) != "Europe/Paris" || len(got.Timezones[0].Daylights) != 1 {
+		t.Errorf("expected the VTIMEZONE of Europe/Paris, got %+v", got.Timezones)
+	} else if rule := getProperty("RRULE", got.Timezones[0].Daylights[0].Properties); rule == nil || rule.Value != "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU" {
+		t.Errorf("unexpected daylight rule %v", propertyLines(got.Timezones[0].Daylights[0].Properties))
+	}
+
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)
+	want, occurrences := c.EventsBetween(start, end), got.EventsBetween(start, end)
+	if len(occurrences) != len(want) {
+		t.Fatalf("expected %d occurrences, got %d", len(want), len(occurrences))
+	}
+	for i := range want {
+		if !occurrences[i].Start.Equal(want[i].Start) || !occurrences[i].End.Equal(want[i].End) || occurrences[i].Event.Summary != want[i].Event.Summary {
+			t.Errorf("expected %s %s, got %s %s", want[i].Start, want[i].Event.Summary, occurrences[i].Start, occurrences[i].Event.Summary)
+		}
+	}
+}
+
+func TestJSPatch(t *testing.T) {
+	e := &JSEvent{
+		JSCommon: JSCommon{
+			Type:      "Event",
+			UID:       "patch@example.com",
+			Updated:   "2019-01-01T00:00:00Z",
+			Title:     "Review",
+			TimeZone:  "America/New_York",
+			Locations: map[string]*JSLocation{"1": {Type: "Location", Name: "Room 1"}},
+			RecurrenceRules: []*JSRecurrenceRule{
+				{Type: "RecurrenceRule", Frequency: "monthly", ByDay: []JSNDay{{Day: "fr", NthOfPeriod: -1}}, Until: "2019-06-30T00:00:00"},
+			},
+			RecurrenceOverrides: map[string]JSPatch{
+				"2019-02-22T15:00:00": {"locations/1/name": "Room 2", "title": nil},
+				"2019-03-01T15:00:00": {},
+			},
+		},
+		Start:    "2019-01-25T15:00:00",
+		Duration: "PT30M",
+	}
+
+	events, err := e.Events(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	v, instance := events[0], events[1]
+	for _, want := range []string{
+		"DTSTART;TZID=America/New_York:20190125T150000",
+		"RRULE:FREQ=MONTHLY;UNTIL=20190630T040000Z;BYDAY=-1FR",
+		"RDATE;TZID=America/New_York:20190301T150000",
+	} {
+		if !containsString(propertyLines(v.Properties), want) {
+			t.Errorf("missing %s in %v", want, propertyLines(v.Properties))
+		}
+	}
+	if instance.Summary != "" || getProperty("LOCATION", instance.Properties).Value != "Room 2" || instance.RecurrenceID.Format(dateTimeLayoutLocalized) != "20190222T150000" {
+		t.Errorf("unexpected instance %v", propertyLines(instance.Properties))
+	}
+
+	if err := (JSPatch{"locations/2/name": "Room 3"}).apply(jsObject(e)); err == nil {
+		t.Error("expected an error for a patch of a missing object")
+	}
+
+	// the locations after the first are kept as X-JS-LOCATION
+	e.Locations["2"] = &JSLocation{Type: "Location", Name: "Lobby", Coordinates: "geo:48.85,2.35"}
+	events, err = e.Events(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `X-JS-LOCATION;X-COORDINATES="geo:48.85,2.35";X-ID=2:Lobby`; !containsString(propertyLines(events[0].Properties), want) {
+		t.Errorf("missing %s in %v", want, propertyLines(events[0].Properties))
+	}
+	if got := NewJSEvent(events[0]).Locations; len(got) != 2 || got["1"].Name != "Room 1" || *got["2"] != *e.Locations["2"] {
+		t.Errorf("unexpected locations %+v", got)
+	}
+}
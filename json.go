@@ -0,0 +1,1056 @@
+package ical
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonDateLayout is the layout of the dates of all-day components
+const jsonDateLayout = "2006-01-02"
+
+// jsonFloatingLayout is the layout of floating date-times, without offset
+const jsonFloatingLayout = "2006-01-02T15:04:05"
+
+// JSONOptions are the options of the JSON form of the calendar model.
+//
+// Dates are in ISO 8601: "2019-01-07T09:30:00+01:00", "2019-01-07T09:30:00"
+// without offset for a floating time, or "2019-01-07" for an all-day
+// component marked with "allDay", and "timeZone" holds the name of their
+// location. Texts are unescaped. Zero values are left out. Along with the
+// raw properties, the typed fields must agree with them when decoded.
+type JSONOptions struct {
+	// OmitProperties leaves out the raw properties, and the timezones of a
+	// calendar which are made of properties only. Properties are rebuilt
+	// from the typed fields when decoded.
+	OmitProperties bool
+}
+
+// Marshal returns the JSON form of a *Calendar, *Event, *Todo, *FreeBusy,
+// *Timezone or *Alarm
+func (o JSONOptions) Marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case *Calendar:
+		return json.Marshal(o.calendar(v))
+	case *Event:
+		return json.Marshal(o.event(v))
+	case *Todo:
+		return json.Marshal(o.todo(v))
+	case *FreeBusy:
+		return json.Marshal(o.freeBusy(v))
+	case *Timezone:
+		return json.Marshal(o.timezone(v))
+	case *Alarm:
+		return json.Marshal(o.alarm(v))
+	}
+	return nil, fmt.Errorf("unsupported type %T", v)
+}
+
+// MarshalJSON returns the JSON form of the calendar: prodid, version,
+// calscale, method, events, todos, freebusys, timezones, properties and
+// components, the ones unknown to the parser
+func (c *Calendar) MarshalJSON() ([]byte, error) {
+	return JSONOptions{}.Marshal(c)
+}
+
+// UnmarshalJSON decodes the JSON form of a calendar
+func (c *Calendar) UnmarshalJSON(data []byte) error {
+	var j jsonCalendar
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return j.decode(c)
+}
+
+// MarshalJSON returns the JSON form of the event: uid, sequence, timestamp,
+// start, end, allDay, timeZone, summary, description, rrule, rdates, exdates,
+// recurrenceId, alarms and properties
+func (v *Event) MarshalJSON() ([]byte, error) {
+	return JSONOptions{}.Marshal(v)
+}
+
+// UnmarshalJSON decodes the JSON form of an event
+func (v *Event) UnmarshalJSON(data []byte) error {
+	var j jsonEvent
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return j.decode(v)
+}
+
+// MarshalJSON returns the JSON form of the todo: uid, sequence, timestamp,
+// start, due, allDay, timeZone, summary, description, rrule, rdates, exdates,
+// recurrenceId, alarms and properties
+func (t *Todo) MarshalJSON() ([]byte, error) {
+	return JSONOptions{}.Marshal(t)
+}
+
+// UnmarshalJSON decodes the JSON form of a todo
+func (t *Todo) UnmarshalJSON(data []byte) error {
+	var j jsonTodo
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return j.decode(t)
+}
+
+// MarshalJSON returns the JSON form of the free/busy time: uid, timestamp,
+// start, end, busy, busyTentative and properties
+func (f *FreeBusy) MarshalJSON() ([]byte, error) {
+	return JSONOptions{}.Marshal(f)
+}
+
+// UnmarshalJSON decodes the JSON form of a free/busy time
+func (f *FreeBusy) UnmarshalJSON(data []byte) error {
+	var j jsonFreeBusy
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return j.decode(f)
+}
+
+// MarshalJSON returns the JSON form of the timezone: tzid, properties,
+// standards and daylights, the rules with their properties
+func (tz *Timezone) MarshalJSON() ([]byte, error) {
+	return JSONOptions{}.Marshal(tz)
+}
+
+// UnmarshalJSON decodes the JSON form of a timezone
+func (tz *Timezone) UnmarshalJSON(data []byte) error {
+	var j jsonTimezone
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	j.decode(tz)
+	return nil
+}
+
+// MarshalJSON returns the JSON form of the alarm: uid, action, trigger,
+// related, triggerDate, repeat, duration, description, summary, attendees,
+// attach, acknowledged, snoozeOf and properties
+func (a *Alarm) MarshalJSON() ([]byte, error) {
+	return JSONOptions{}.Marshal(a)
+}
+
+// UnmarshalJSON decodes the JSON form of an alarm
+func (a *Alarm) UnmarshalJSON(data []byte) error {
+	var j jsonAlarm
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	return j.decode(a)
+}
+
+// MarshalJSON returns the JSON form of the property: name, params, a list
+// of values by name, and value
+func (p *Property) MarshalJSON() ([]byte, error) {
+	params := make(map[string][]string, len(p.Params))
+	for name, param := range p.Params {
+		params[name] = param.Values
+	}
+	return json.Marshal(jsonProperty{Name: p.Name, Params: params, Value: p.Value})
+}
+
+// UnmarshalJSON decodes the JSON form of a property
+func (p *Property) UnmarshalJSON(data []byte) error {
+	var j jsonProperty
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*p = *NewProperty()
+	p.Name, p.Value = j.Name, j.Value
+	for name, values := range j.Params {
+		p.Params[name] = &Param{Values: values}
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON form of the period: start and end
+func (p Period) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonPeriod{Start: jsonTime(p.Start, false), End: jsonTime(p.End, false)})
+}
+
+// UnmarshalJSON decodes the JSON form of a period
+func (p *Period) UnmarshalJSON(data []byte) error {
+	var j jsonPeriod
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	d := &jsonDecoder{}
+	p.Start, p.End = d.time(j.Start, false), d.time(j.End, false)
+	return d.err
+}
+
+type jsonProperty struct {
+	Name   string              `json:"name"`
+	Params map[string][]string `json:"params,omitempty"`
+	Value  string              `json:"value"`
+}
+
+type jsonPeriod struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type jsonCalendar struct {
+	ProdID     string          `json:"prodid,omitempty"`
+	Version    string          `json:"version,omitempty"`
+	Calscale   string          `json:"calscale,omitempty"`
+	Method     string          `json:"method,omitempty"`
+	Events     []jsonEvent     `json:"events,omitempty"`
+	Todos      []jsonTodo      `json:"todos,omitempty"`
+	FreeBusys  []jsonFreeBusy  `json:"freebusys,omitempty"`
+	Timezones  []jsonTimezone  `json:"timezones,omitempty"`
+	Properties []*Property     `json:"properties,omitempty"`
+	Components []jsonComponent `json:"components,omitempty"`
+}
+
+type jsonComponent struct {
+	Name       string          `json:"name"`
+	Properties []*Property     `json:"properties,omitempty"`
+	Components []jsonComponent `json:"components,omitempty"`
+}
+
+type jsonEvent struct {
+	UID          string      `json:"uid,omitempty"`
+	Sequence     int         `json:"sequence,omitempty"`
+	Timestamp    string      `json:"timestamp,omitempty"`
+	Start        string      `json:"start,omitempty"`
+	End          string      `json:"end,omitempty"`
+	AllDay       bool        `json:"allDay,omitempty"`
+	TimeZone     string      `json:"timeZone,omitempty"`
+	Summary      string      `json:"summary,omitempty"`
+	Description  string      `json:"description,omitempty"`
+	RRule        string      `json:"rrule,omitempty"`
+	RDates       []string    `json:"rdates,omitempty"`
+	ExDates      []string    `json:"exdates,omitempty"`
+	RecurrenceID string      `json:"recurrenceId,omitempty"`
+	Alarms       []jsonAlarm `json:"alarms,omitempty"`
+	Properties   []*Property `json:"properties,omitempty"`
+}
+
+type jsonTodo struct {
+	UID          string      `json:"uid,omitempty"`
+	Sequence     int         `json:"sequence,omitempty"`
+	Timestamp    string      `json:"timestamp,omitempty"`
+	Start        string      `json:"start,omitempty"`
+	Due          string      `json:"due,omitempty"`
+	AllDay       bool        `json:"allDay,omitempty"`
+	TimeZone     string      `json:"timeZone,omitempty"`
+	Summary      string      `json:"summary,omitempty"`
+	Description  string      `json:"description,omitempty"`
+	RRule        string      `json:"rrule,omitempty"`
+	RDates       []string    `json:"rdates,omitempty"`
+	ExDates      []string    `json:"exdates,omitempty"`
+	RecurrenceID string      `json:"recurrenceId,omitempty"`
+	Alarms       []jsonAlarm `json:"alarms,omitempty"`
+	Properties   []*Property `json:"properties,omitempty"`
+}
+
+type jsonFreeBusy struct {
+	UID           string      `json:"uid,omitempty"`
+	Timestamp     string      `json:"timestamp,omitempty"`
+	Start         string      `json:"start,omitempty"`
+	End           string      `json:"end,omitempty"`
+	Busy          []Period    `json:"busy,omitempty"`
+	BusyTentative []Period    `json:"busyTentative,omitempty"`
+	Properties    []*Property `json:"properties,omitempty"`
+}
+
+type jsonTimezone struct {
+	TZID       string        `json:"tzid,omitempty"`
+	Properties []*Property   `json:"properties,omitempty"`
+	Standards  [][]*Property `json:"standards,omitempty"`
+	Daylights  [][]*Property `json:"daylights,omitempty"`
+}
+
+type jsonAlarm struct {
+	UID          string      `json:"uid,omitempty"`
+	Action       string      `json:"action,omitempty"`
+	Trigger      string      `json:"trigger,omitempty"`
+	Related      string      `json:"related,omitempty"`
+	TriggerDate  string      `json:"triggerDate,omitempty"`
+	Repeat       int         `json:"repeat,omitempty"`
+	Duration     string      `json:"duration,omitempty"`
+	Description  string      `json:"description,omitempty"`
+	Summary      string      `json:"summary,omitempty"`
+	Attendees    []string    `json:"attendees,omitempty"`
+	Attach       []string    `json:"attach,omitempty"`
+	Acknowledged string      `json:"acknowledged,omitempty"`
+	SnoozeOf     string      `json:"snoozeOf,omitempty"`
+	Properties   []*Property `json:"properties,omitempty"`
+}
+
+func (o JSONOptions) properties(properties []*Property) []*Property {
+	if o.OmitProperties {
+		return nil
+	}
+	return properties
+}
+
+func (o JSONOptions) calendar(c *Calendar) jsonCalendar {
+	j := jsonCalendar{
+		ProdID:     c.Prodid,
+		Version:    c.Version,
+		Calscale:   c.Calscale,
+		Method:     c.Method,
+		Properties: o.properties(c.Properties),
+		Components: o.components(c.Components),
+	}
+	for _, v := range c.Events {
+		j.Events = append(j.Events, o.event(v))
+	}
+	for _, t := range c.Todos {
+		j.Todos = append(j.Todos, o.todo(t))
+	}
+	for _, f := range c.FreeBusys {
+		j.FreeBusys = append(j.FreeBusys, o.freeBusy(f))
+	}
+	if !o.OmitProperties {
+		for _, tz := range c.Timezones {
+			j.Timezones = append(j.Timezones, o.timezone(tz))
+		}
+	}
+	return j
+}
+
+// components returns the unknown components, left out with the properties
+func (o JSONOptions) components(components []*Component) []jsonComponent {
+	if o.OmitProperties {
+		return nil
+	}
+	var j []jsonComponent
+	for _, c := range components {
+		j = append(j, jsonComponent{Name: c.Name, Properties: c.Properties, Components: o.components(c.Components)})
+	}
+	return j
+}
+
+func (o JSONOptions) event(v *Event) jsonEvent {
+	f := jsonForm{allDay: v.AllDay, floating: isFloating(getProperty("DTSTART", v.Properties))}
+	j := jsonEvent{
+		UID:          v.UID,
+		Sequence:     v.Sequence,
+		Timestamp:    jsonTime(v.Timestamp, false),
+		Start:        f.time(v.StartDate),
+		End:          f.time(v.EndDate),
+		AllDay:       v.AllDay,
+		TimeZone:     f.timeZone(v.StartDate),
+		Summary:      unescapeText(v.Summary),
+		Description:  unescapeText(v.Description),
+		RDates:       f.times(v.RDates),
+		ExDates:      f.times(v.ExDates),
+		RecurrenceID: f.time(v.RecurrenceID),
+		Properties:   o.properties(v.Properties),
+	}
+	if v.RRule != nil {
+		j.RRule = v.RRule.String()
+	}
+	for _, a := range v.Alarms {
+		j.Alarms = append(j.Alarms, o.alarm(a))
+	}
+	return j
+}
+
+func (o JSONOptions) todo(t *Todo) jsonTodo {
+	var f jsonForm
+	for _, name := range []string{"DTSTART", "DUE"} {
+		if prop := getProperty(name, t.Properties); prop != nil {
+			f = jsonForm{allDay: isDate(prop), floating: isFloating(prop)}
+			break
+		}
+	}
+	zone := t.StartDate
+	if zone.IsZero() {
+		zone = t.DueDate
+	}
+
+	j := jsonTodo{
+		UID:          t.UID,
+		Sequence:     t.Sequence,
+		Timestamp:    jsonTime(t.Timestamp, false),
+		Start:        f.time(t.StartDate),
+		Due:          f.time(t.DueDate),
+		AllDay:       f.allDay,
+		TimeZone:     f.timeZone(zone),
+		Summary:      unescapeText(t.Summary),
+		Description:  unescapeText(t.Description),
+		RDates:       f.times(t.RDates),
+		ExDates:      f.times(t.ExDates),
+		RecurrenceID: f.time(t.RecurrenceID),
+		Properties:   o.properties(t.Properties),
+	}
+	if t.RRule != nil {
+		j.RRule = t.RRule.String()
+	}
+	for _, a := range t.Alarms {
+		j.Alarms = append(j.Alarms, o.alarm(a))
+	}
+	return j
+}
+
+func (o JSONOptions) freeBusy(f *FreeBusy) jsonFreeBusy {
+	return jsonFreeBusy{
+		UID:           f.UID,
+		Timestamp:     jsonTime(f.Timestamp, false),
+		Start:         jsonTime(f.StartDate, false),
+		End:           jsonTime(f.EndDate, false),
+		Busy:          f.Busy,
+		BusyTentative: f.BusyTentative,
+		Properties:    o.properties(f.Properties),
+	}
+}
+
+func (o JSONOptions) timezone(tz *Timezone) jsonTimezone {
+	j := jsonTimezone{TZID: timezoneID(tz), Properties: tz.Properties}
+	for _, s := range tz.Standards {
+		j.Standards = append(j.Standards, s.Properties)
+	}
+	for _, d := range tz.Daylights {
+		j.Daylights = append(j.Daylights, d.Properties)
+	}
+	return j
+}
+
+func (o JSONOptions) alarm(a *Alarm) jsonAlarm {
+	j := jsonAlarm{
+		UID:          a.UID,
+		Action:       a.Action,
+		Trigger:      a.Trigger,
+		TriggerDate:  jsonTime(a.TriggerDate, false),
+		Repeat:       a.Repeat,
+		Description:  unescapeText(a.Description),
+		Summary:      unescapeText(a.Summary),
+		Attendees:    a.Attendees,
+		Attach:       a.Attach,
+		Acknowledged: jsonTime(a.Acknowledged, false),
+		SnoozeOf:     a.SnoozeOf,
+		Properties:   o.properties(a.Properties),
+	}
+	if a.TriggerDate.IsZero() {
+		j.Related = a.Related
+	}
+	if a.Duration != 0 {
+		j.Duration = formatDuration(a.Duration)
+	}
+	return j
+}
+
+func (j *jsonCalendar) decode(c *Calendar) error {
+	*c = *NewCalendar()
+	c.Prodid, c.Version, c.Method = j.ProdID, j.Version, j.Method
+	if j.Calscale != "" {
+		c.Calscale = j.Calscale
+	}
+	for i := range j.Events {
+		v := NewEvent()
+		if err := j.Events[i].decode(v); err != nil {
+			return err
+		}
+		c.Events = append(c.Events, v)
+	}
+	for i := range j.Todos {
+		t := NewTodo()
+		if err := j.Todos[i].decode(t); err != nil {
+			return err
+		}
+		c.Todos = append(c.Todos, t)
+	}
+	for i := range j.FreeBusys {
+		f := NewFreeBusy()
+		if err := j.FreeBusys[i].decode(f); err != nil {
+			return err
+		}
+		c.FreeBusys = append(c.FreeBusys, f)
+	}
+	for i := range j.Timezones {
+		tz := NewTimezone()
+		j.Timezones[i].decode(tz)
+		c.Timezones = append(c.Timezones, tz)
+	}
+
+	c.Components = decodeComponents(j.Components)
+	c.Properties = j.Properties
+	if c.Properties != nil {
+		value := func(name, zero string) string {
+			if prop := getProperty(name, c.Properties); prop != nil {
+				return prop.Value
+			}
+			return zero
+		}
+		return jsonAgree("calendar", "", []jsonField{
+			{"prodid", c.Prodid == value("PRODID", "")},
+			{"version", c.Version == value("VERSION", "")},
+			{"calscale", c.Calscale == value("CALSCALE", "GREGORIAN")},
+			{"method", c.Method == value("METHOD", "")},
+		})
+	}
+
+	c.Properties = make([]*Property, 0)
+	for _, prop := range []struct{ name, value string }{
+		{"PRODID", c.Prodid},
+		{"VERSION", c.Version},
+		{"CALSCALE", j.Calscale},
+		{"METHOD", c.Method},
+	} {
+		if prop.value != "" {
+			setProperty(prop.name, prop.value, &c.Properties)
+		}
+	}
+	return nil
+}
+
+// decodeComponents returns the unknown components of their JSON form
+func decodeComponents(j []jsonComponent) []*Component {
+	var components []*Component
+	for _, c := range j {
+		properties := c.Properties
+		if properties == nil {
+			properties = make([]*Property, 0)
+		}
+		components = append(components, &Component{Name: c.Name, Properties: properties, Components: decodeComponents(c.Components)})
+	}
+	return components
+}
+
+func (j *jsonEvent) decode(v *Event) error {
+	d := &jsonDecoder{}
+	loc := d.location(j.TimeZone)
+	floating := jsonFloating(j.Start)
+
+	*v = *NewEvent()
+	v.UID = j.UID
+	v.Sequence = j.Sequence
+	v.Timestamp = d.time(j.Timestamp, false)
+	v.StartDate = d.timeIn(j.Start, j.AllDay, loc)
+	v.EndDate = d.timeIn(j.End, j.AllDay, loc)
+	v.AllDay = j.AllDay
+	v.Summary = escapeText(j.Summary)
+	v.Description = escapeText(j.Description)
+	v.RRule = d.recur(j.RRule)
+	v.RDates = d.times(j.RDates, j.AllDay, loc)
+	v.ExDates = d.times(j.ExDates, j.AllDay, loc)
+	v.RecurrenceID = d.timeIn(j.RecurrenceID, j.AllDay, loc)
+	for i := range j.Alarms {
+		a := NewAlarm()
+		if err := j.Alarms[i].decode(a); err != nil {
+			return err
+		}
+		v.Alarms = append(v.Alarms, a)
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	if j.Properties != nil {
+		// checked as within an iTIP message, DTSTAMP and DTSTART not required
+		p := &Event{Properties: j.Properties, Alarms: v.Alarms}
+		if err := decodeEvent(p, MethodPublish, time.Local); err != nil {
+			return err
+		}
+		f := jsonForm{allDay: p.AllDay, floating: isFloating(getProperty("DTSTART", p.Properties))}
+		if err := jsonAgree("event", v.UID, []jsonField{
+			{"uid", v.UID == p.UID},
+			{"sequence", v.Sequence == p.Sequence},
+			{"timestamp", v.Timestamp.Equal(p.Timestamp)},
+			{"start", v.StartDate.Equal(p.StartDate)},
+			{"end", v.EndDate.Equal(p.EndDate)},
+			{"allDay", v.AllDay == p.AllDay},
+			{"timeZone", j.TimeZone == f.timeZone(p.StartDate)},
+			{"summary", j.Summary == unescapeText(p.Summary)},
+			{"description", j.Description == unescapeText(p.Description)},
+			{"rrule", recurString(v.RRule) == recurString(p.RRule)},
+			{"rdates", sameTimes(v.RDates, p.RDates)},
+			{"exdates", sameTimes(v.ExDates, p.ExDates)},
+			{"recurrenceId", v.RecurrenceID.Equal(p.RecurrenceID)},
+		}); err != nil {
+			return err
+		}
+		*v = *p
+		return nil
+	}
+
+	v.Properties = make([]*Property, 0)
+	setProperty("UID", v.UID, &v.Properties)
+	if !v.Timestamp.IsZero() {
+		setProperty("DTSTAMP", formatDateTime(v.Timestamp), &v.Properties)
+	}
+	if v.Sequence > 0 {
+		setProperty("SEQUENCE", strconv.Itoa(v.Sequence), &v.Properties)
+	}
+	v.Properties = append(v.Properties, dateProperties("DTSTART", []time.Time{v.StartDate}, v.AllDay, floating)...)
+	if v.EndDate.After(v.StartDate) {
+		v.Properties = append(v.Properties, dateProperties("DTEND", []time.Time{v.EndDate}, v.AllDay, floating)...)
+	}
+	v.Properties = append(v.Properties, componentProperties(v.Summary, v.Description, v.RRule, v.RDates, v.ExDates, v.RecurrenceID, v.AllDay, floating)...)
+	return nil
+}
+
+func (j *jsonTodo) decode(t *Todo) error {
+	d := &jsonDecoder{}
+	loc := d.location(j.TimeZone)
+	floating := jsonFloating(j.Start) || j.Start == "" && jsonFloating(j.Due)
+
+	*t = *NewTodo()
+	t.UID = j.UID
+	t.Sequence = j.Sequence
+	t.Timestamp = d.time(j.Timestamp, false)
+	t.StartDate = d.timeIn(j.Start, j.AllDay, loc)
+	t.DueDate = d.timeIn(j.Due, j.AllDay, loc)
+	t.Summary = escapeText(j.Summary)
+	t.Description = escapeText(j.Description)
+	t.RRule = d.recur(j.RRule)
+	t.RDates = d.times(j.RDates, j.AllDay, loc)
+	t.ExDates = d.times(j.ExDates, j.AllDay, loc)
+	t.RecurrenceID = d.timeIn(j.RecurrenceID, j.AllDay, loc)
+	for i := range j.Alarms {
+		a := NewAlarm()
+		if err := j.Alarms[i].decode(a); err != nil {
+			return err
+		}
+		t.Alarms = append(t.Alarms, a)
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	if j.Properties != nil {
+		// checked as within an iTIP message, DTSTAMP not required
+		p := &Todo{Properties: j.Properties, Alarms: t.Alarms}
+		if err := decodeTodo(p, MethodPublish, time.Local); err != nil {
+			return err
+		}
+		var f jsonForm
+		for _, name := range []string{"DTSTART", "DUE"} {
+			if prop := getProperty(name, p.Properties); prop != nil {
+				f = jsonForm{allDay: isDate(prop), floating: isFloating(prop)}
+				break
+			}
+		}
+		zone := p.StartDate
+		if zone.IsZero() {
+			zone = p.DueDate
+		}
+		if err := jsonAgree("todo", t.UID, []jsonField{
+			{"uid", t.UID == p.UID},
+			{"sequence", t.Sequence == p.Sequence},
+			{"timestamp", t.Timestamp.Equal(p.Timestamp)},
+			{"start", t.StartDate.Equal(p.StartDate)},
+			{"due", t.DueDate.Equal(p.DueDate)},
+			{"allDay", j.AllDay == f.allDay},
+			{"timeZone", j.TimeZone == f.timeZone(zone)},
+			{"summary", j.Summary == unescapeText(p.Summary)},
+			{"description", j.Description == unescapeText(p.Description)},
+			{"rrule", recurString(t.RRule) == recurString(p.RRule)},
+			{"rdates", sameTimes(t.RDates, p.RDates)},
+			{"exdates", sameTimes(t.ExDates, p.ExDates)},
+			{"recurrenceId", t.RecurrenceID.Equal(p.RecurrenceID)},
+		}); err != nil {
+			return err
+		}
+		*t = *p
+		return nil
+	}
+
+	t.Properties = make([]*Property, 0)
+	setProperty("UID", t.UID, &t.Properties)
+	if !t.Timestamp.IsZero() {
+		setProperty("DTSTAMP", formatDateTime(t.Timestamp), &t.Properties)
+	}
+	if t.Sequence > 0 {
+		setProperty("SEQUENCE", strconv.Itoa(t.Sequence), &t.Properties)
+	}
+	t.Properties = append(t.Properties, dateProperties("DTSTART", []time.Time{t.StartDate}, j.AllDay, floating)...)
+	t.Properties = append(t.Properties, dateProperties("DUE", []time.Time{t.DueDate}, j.AllDay, floating)...)
+	t.Properties = append(t.Properties, componentProperties(t.Summary, t.Description, t.RRule, t.RDates, t.ExDates, t.RecurrenceID, j.AllDay, floating)...)
+	return nil
+}
+
+func (j *jsonFreeBusy) decode(f *FreeBusy) error {
+	d := &jsonDecoder{}
+	*f = *NewFreeBusy()
+	f.UID = j.UID
+	f.Timestamp = d.time(j.Timestamp, false)
+	f.StartDate = d.time(j.Start, false)
+	f.EndDate = d.time(j.End, false)
+	f.Busy = j.Busy
+	f.BusyTentative = j.BusyTentative
+	if d.err != nil {
+		return d.err
+	}
+
+	f.Properties = j.Properties
+	if f.Properties == nil {
+		f.Properties = make([]*Property, 0)
+		setProperty("UID", f.UID, &f.Properties)
+		for _, prop := range []struct {
+			name string
+			t    time.Time
+		}{{"DTSTAMP", f.Timestamp}, {"DTSTART", f.StartDate}, {"DTEND", f.EndDate}} {
+			if !prop.t.IsZero() {
+				setProperty(prop.name, formatDateTime(prop.t), &f.Properties)
+			}
+		}
+		if len(f.Busy) > 0 {
+			setProperty("FREEBUSY", formatPeriods(f.Busy), &f.Properties)
+		}
+		if len(f.BusyTentative) > 0 {
+			prop := NewProperty()
+			prop.Name = "FREEBUSY"
+			prop.Params["FBTYPE"] = &Param{Values: []string{"BUSY-TENTATIVE"}}
+			prop.Value = formatPeriods(f.BusyTentative)
+			f.Properties = append(f.Properties, prop)
+		}
+	}
+	return nil
+}
+
+func (j *jsonTimezone) decode(tz *Timezone) {
+	*tz = *NewTimezone()
+	if j.Properties != nil {
+		tz.Properties = j.Properties
+	} else if j.TZID != "" {
+		setProperty("TZID", j.TZID, &tz.Properties)
+	}
+	for _, properties := range j.Standards {
+		s := NewStandard()
+		s.Properties = properties
+		tz.Standards = append(tz.Standards, s)
+	}
+	for _, properties := range j.Daylights {
+		dl := NewDaylight()
+		dl.Properties = properties
+		tz.Daylights = append(tz.Daylights, dl)
+	}
+}
+
+func (j *jsonAlarm) decode(a *Alarm) error {
+	d := &jsonDecoder{}
+	*a = *NewAlarm()
+	a.UID = j.UID
+	a.Action = j.Action
+	a.Trigger = j.Trigger
+	a.TriggerDate = d.time(j.TriggerDate, false)
+	a.Repeat = j.Repeat
+	a.Description = escapeText(j.Description)
+	a.Summary = escapeText(j.Summary)
+	a.Acknowledged = d.time(j.Acknowledged, false)
+	a.SnoozeOf = j.SnoozeOf
+	if j.Attendees != nil {
+		a.Attendees = j.Attendees
+	}
+	if j.Attach != nil {
+		a.Attach = j.Attach
+	}
+	if a.TriggerDate.IsZero() && a.Trigger != "" {
+		a.Offset, d.err = parseDuration(a.Trigger)
+		a.Related = j.Related
+		if a.Related == "" {
+			a.Related = "START"
+		}
+	}
+	if j.Duration != "" && d.err == nil {
+		a.Duration, d.err = parseDuration(j.Duration)
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	if j.Properties != nil {
+		p := &Alarm{Properties: j.Properties}
+		if err := decodeAlarm(p, time.Local); err != nil {
+			return err
+		}
+		related := p.Related
+		if !p.TriggerDate.IsZero() {
+			related = ""
+		}
+		if err := jsonAgree("alarm", a.UID, []jsonField{
+			{"uid", a.UID == p.UID},
+			{"action", a.Action == p.Action},
+			{"trigger", a.Trigger == p.Trigger},
+			{"related", j.Related == related},
+			{"triggerDate", a.TriggerDate.Equal(p.TriggerDate)},
+			{"repeat", a.Repeat == p.Repeat},
+			{"duration", a.Duration == p.Duration},
+			{"description", j.Description == unescapeText(p.Description)},
+			{"summary", j.Summary == unescapeText(p.Summary)},
+			{"attendees", strings.Join(a.Attendees, ",") == strings.Join(p.Attendees, ",")},
+			{"attach", strings.Join(a.Attach, ",") == strings.Join(p.Attach, ",")},
+			{"acknowledged", a.Acknowledged.Equal(p.Acknowledged)},
+			{"snoozeOf", a.SnoozeOf == p.SnoozeOf},
+		}); err != nil {
+			return err
+		}
+		*a = *p
+		return nil
+	}
+
+	a.Properties = make([]*Property, 0)
+	setProperty("ACTION", a.Action, &a.Properties)
+	if !a.TriggerDate.IsZero() {
+		prop := setProperty("TRIGGER", formatDateTime(a.TriggerDate), &a.Properties)
+		prop.Params["VALUE"] = &Param{Values: []string{"DATE-TIME"}}
+	} else {
+		prop := setProperty("TRIGGER", a.Trigger, &a.Properties)
+		if a.Related == "END" {
+			prop.Params["RELATED"] = &Param{Values: []string{"END"}}
+		}
+	}
+	if a.Repeat > 0 {
+		setProperty("REPEAT", strconv.Itoa(a.Repeat), &a.Properties)
+		setProperty("DURATION", formatDuration(a.Duration), &a.Properties)
+	}
+	for _, prop := range []struct{ name, value string }{
+		{"UID", a.UID},
+		{"DESCRIPTION", a.Description},
+		{"SUMMARY", a.Summary},
+	} {
+		if prop.value != "" {
+			setProperty(prop.name, prop.value, &a.Properties)
+		}
+	}
+	for _, attendee := range a.Attendees {
+		a.Properties = append(a.Properties, &Property{Name: "ATTENDEE", Value: attendee, Params: make(map[string]*Param)})
+	}
+	for _, attach := range a.Attach {
+		a.Properties = append(a.Properties, &Property{Name: "ATTACH", Value: attach, Params: make(map[string]*Param)})
+	}
+	if !a.Acknowledged.IsZero() {
+		setProperty("ACKNOWLEDGED", formatDateTime(a.Acknowledged), &a.Properties)
+	}
+	if a.SnoozeOf != "" {
+		prop := setProperty("RELATED-TO", a.SnoozeOf, &a.Properties)
+		prop.Params["RELTYPE"] = &Param{Values: []string{"SNOOZE"}}
+	}
+	return nil
+}
+
+// componentProperties rebuilds the properties common to events and todos
+func componentProperties(summary, description string, rrule *Recur, rdates, exdates []time.Time, recurrenceID time.Time, allDay, floating bool) []*Property {
+	var properties []*Property
+	if summary != "" {
+		setProperty("SUMMARY", summary, &properties)
+	}
+	if description != "" {
+		setProperty("DESCRIPTION", description, &properties)
+	}
+	if rrule != nil {
+		setProperty("RRULE", rrule.String(), &properties)
+	}
+	properties = append(properties, dateProperties("RDATE", rdates, allDay, floating)...)
+	properties = append(properties, dateProperties("EXDATE", exdates, allDay, floating)...)
+	properties = append(properties, dateProperties("RECURRENCE-ID", []time.Time{recurrenceID}, allDay, floating)...)
+	return properties
+}
+
+// dateProperties returns a date property for each time which isn't zero: a
+// date, a floating date-time, an UTC date-time for a time in UTC or in the
+// system location, or a date-time with the TZID of its location
+func dateProperties(name string, times []time.Time, allDay, floating bool) []*Property {
+	var properties []*Property
+	for _, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		prop := NewProperty()
+		prop.Name = name
+		switch loc := t.Location(); {
+		case allDay:
+			prop.Value = t.Format(dateLayout)
+			prop.Params["VALUE"] = &Param{Values: []string{"DATE"}}
+		case floating:
+			prop.Value = t.Format(dateTimeLayoutLocalized)
+		case loc == time.UTC || loc == time.Local || loc.String() == "":
+			prop.Value = formatDateTime(t)
+		default:
+			prop.Value = t.Format(dateTimeLayoutLocalized)
+			prop.Params["TZID"] = &Param{Values: []string{loc.String()}}
+		}
+		properties = append(properties, prop)
+	}
+	return properties
+}
+
+// jsonTime formats a time in ISO 8601, as a date for an all-day component
+func jsonTime(t time.Time, allDay bool) string {
+	switch {
+	case t.IsZero():
+		return ""
+	case allDay:
+		return t.Format(jsonDateLayout)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// jsonForm is the form of the dates of a component: dates of an all-day
+// component, floating date-times without offset, or date-times
+type jsonForm struct {
+	allDay   bool
+	floating bool
+}
+
+func (f jsonForm) time(t time.Time) string {
+	if f.floating && !f.allDay && !t.IsZero() {
+		return t.Format(jsonFloatingLayout)
+	}
+	return jsonTime(t, f.allDay)
+}
+
+func (f jsonForm) times(times []time.Time) []string {
+	var values []string
+	for _, t := range times {
+		values = append(values, f.time(t))
+	}
+	return values
+}
+
+// timeZone returns the name of the location of a time, empty for a
+// floating time
+func (f jsonForm) timeZone(t time.Time) string {
+	if f.floating {
+		return ""
+	}
+	return jsonTimeZone(t)
+}
+
+// isFloating checks if a date property holds a floating date-time, without
+// TZID nor UTC designator
+func isFloating(prop *Property) bool {
+	return prop != nil && !isDate(prop) && prop.Params["TZID"] == nil && !strings.HasSuffix(prop.Value, "Z")
+}
+
+// jsonFloating checks if a date-time of the JSON form is floating
+func jsonFloating(value string) bool {
+	_, err := time.Parse(jsonFloatingLayout, value)
+	return err == nil
+}
+
+// jsonField tells if a typed field of the JSON form agrees with the
+// properties
+type jsonField struct {
+	name  string
+	agree bool
+}
+
+// jsonAgree returns an error for the first typed field of a component
+// disagreeing with its properties, as the properties would be encoded
+func jsonAgree(kind, uid string, fields []jsonField) error {
+	if uid != "" {
+		kind += fmt.Sprintf(" %q", uid)
+	}
+	for _, f := range fields {
+		if !f.agree {
+			return fmt.Errorf("%s: %q disagrees with the properties", kind, f.name)
+		}
+	}
+	return nil
+}
+
+// sameTimes checks if two lists hold the same instants
+func sameTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// recurString returns the value of a rule, empty for none
+func recurString(r *Recur) string {
+	if r == nil {
+		return ""
+	}
+	return r.String()
+}
+
+// jsonTimeZone returns the name of the location of a time, empty for the
+// local location and UTC which need no name
+func jsonTimeZone(t time.Time) string {
+	switch name := t.Location().String(); name {
+	case "Local", "UTC":
+		return ""
+	default:
+		return name
+	}
+}
+
+// jsonDecoder decodes the values of the JSON form, keeping the first error
+type jsonDecoder struct {
+	err error
+}
+
+// location loads the location of a timeZone, nil when not set
+func (d *jsonDecoder) location(name string) *time.Location {
+	if name == "" || d.err != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		d.err = fmt.Errorf("invalid time zone %q", name)
+	}
+	return loc
+}
+
+// time parses a time formatted by jsonTime, dates in the local location
+func (d *jsonDecoder) time(value string, allDay bool) time.Time {
+	return d.timeIn(value, allDay, nil)
+}
+
+// timeIn parses a time formatted by jsonTime in a location, dates in the
+// local location when not set
+func (d *jsonDecoder) timeIn(value string, allDay bool, loc *time.Location) time.Time {
+	if value == "" || d.err != nil {
+		return time.Time{}
+	}
+	if allDay {
+		if loc == nil {
+			loc = time.Local
+		}
+		t, err := time.ParseInLocation(jsonDateLayout, value, loc)
+		if err != nil {
+			d.err = fmt.Errorf("invalid date %q", value)
+		}
+		return t
+	}
+
+	if jsonFloating(value) {
+		t, _ := time.ParseInLocation(jsonFloatingLayout, value, time.Local)
+		return t
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		d.err = fmt.Errorf("invalid date-time %q", value)
+		return t
+	}
+	if loc != nil {
+		t = t.In(loc)
+	}
+	return t
+}
+
+func (d *jsonDecoder) times(values []string, allDay bool, loc *time.Location) []time.Time {
+	var times []time.Time
+	for _, value := range values {
+		times = append(times, d.timeIn(value, allDay, loc))
+	}
+	return times
+}
+
+// recur parses a RRULE value, nil when not set
+func (d *jsonDecoder) recur(value string) *Recur {
+	if value == "" || d.err != nil {
+		return nil
+	}
+	r, err := ParseRecur(strings.TrimPrefix(value, "RRULE:"))
+	if err != nil {
+		d.err = err
+	}
+	return r
+}
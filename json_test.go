@@ -0,0 +1,222 @@
+package ical
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventMarshalJSON(t *testing.T) {
+	c := parseFixture(t, "fixtures/invitation.ics")
+	data, err := json.Marshal(c.Events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`"uid":"meeting@example.com"`,
+		`"sequence":1`,
+		`"timestamp":"2019-01-01T00:00:00Z"`,
+		`"start":"2019-01-10T10:00:00Z"`,
+		`"end":"2019-01-10T11:00:00Z"`,
+		`"rrule":"FREQ=WEEKLY;COUNT=4"`,
+		`"alarms":[{"action":"DISPLAY","trigger":"-PT10M","related":"START","description":"Planning","properties":[`,
+		`{"name":"ATTENDEE","params":{"PARTSTAT":["ACCEPTED"]},"value":"mailto:alice@example.com"}`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected %s in %s", expected, data)
+		}
+	}
+	for _, unexpected := range []string{`"allDay"`, `"timeZone"`, `"recurrenceId"`, `"StartDate"`} {
+		if strings.Contains(string(data), unexpected) {
+			t.Errorf("unexpected %s in %s", unexpected, data)
+		}
+	}
+
+	data, err = JSONOptions{OmitProperties: true}.Marshal(c.Events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"properties"`) {
+		t.Errorf("expected no properties in %s", data)
+	}
+
+	c = parseFixture(t, "fixtures/recurring.ics")
+	data, _ = json.Marshal(c.Events[0])
+	for _, expected := range []string{
+		`"start":"2019-01-07T09:30:00+01:00"`,
+		`"timeZone":"Europe/Paris"`,
+		`"exdates":["2019-01-09T09:30:00+01:00"]`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected %s in %s", expected, data)
+		}
+	}
+	data, _ = json.Marshal(c.Events[3])
+	for _, expected := range []string{`"start":"2019-01-04"`, `"end":"2019-01-08"`, `"allDay":true`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected %s in %s", expected, data)
+		}
+	}
+}
+
+func TestEventUnmarshalJSON(t *testing.T) {
+	c := parseFixture(t, "fixtures/recurring.ics")
+	for _, v := range c.Events {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := &Event{}
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.StartDate.Equal(v.StartDate) || !decoded.EndDate.Equal(v.EndDate) || decoded.AllDay != v.AllDay {
+			t.Errorf("expected %s %s, got %s %s", v.StartDate, v.EndDate, decoded.StartDate, decoded.EndDate)
+		}
+		if decoded.StartDate.Location().String() != v.StartDate.Location().String() {
+			t.Errorf("expected location %s, got %s", v.StartDate.Location(), decoded.StartDate.Location())
+		}
+		if len(decoded.Properties) != len(v.Properties) {
+			t.Errorf("expected %d properties, got %d", len(v.Properties), len(decoded.Properties))
+		}
+	}
+
+	// without the raw properties, they are rebuilt from the typed fields
+	data, _ := JSONOptions{OmitProperties: true}.Marshal(c.Events[0])
+	decoded := &Event{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, prop := range decoded.Properties {
+		lines = append(lines, encodeProperty(prop))
+	}
+	expected := []string{
+		"UID:standup@test",
+		"DTSTAMP:20190101T000000Z",
+		"DTSTART;TZID=Europe/Paris:20190107T093000",
+		"DTEND;TZID=Europe/Paris:20190107T094500",
+		"SUMMARY:Standup",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,WE",
+		"EXDATE;TZID=Europe/Paris:20190109T093000",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected properties\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+	if decoded.RRule == nil || decoded.RRule.Freq != "WEEKLY" {
+		t.Errorf("expected a weekly rule, got %v", decoded.RRule)
+	}
+
+	if err := json.Unmarshal([]byte(`{"start":"tomorrow"}`), decoded); err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+
+	// a typed field edited along with the raw properties is rejected
+	data, _ = json.Marshal(c.Events[0])
+	edited := strings.Replace(string(data), `"summary":"Standup"`, `"summary":"Retro"`, 1)
+	if err := json.Unmarshal([]byte(edited), decoded); err == nil || !strings.Contains(err.Error(), `"summary"`) {
+		t.Errorf("expected an error for a summary disagreeing with the properties, got %v", err)
+	}
+}
+
+func TestEventJSONFloating(t *testing.T) {
+	ics := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Test//EN",
+		"BEGIN:VEVENT",
+		"UID:floating@test",
+		"DTSTAMP:20190101T000000Z",
+		"DTSTART:20190107T093000",
+		"DTEND:20190107T100000",
+		"SUMMARY:Yoga",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	c, err := Parse(strings.NewReader(ics), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := JSONOptions{OmitProperties: true}.Marshal(c.Events[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"start":"2019-01-07T09:30:00"`, `"end":"2019-01-07T10:00:00"`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected %s in %s", expected, data)
+		}
+	}
+	if strings.Contains(string(data), `"timeZone"`) {
+		t.Errorf("unexpected time zone in %s", data)
+	}
+
+	decoded := &Event{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if start := getProperty("DTSTART", decoded.Properties); start == nil || encodeProperty(start) != "DTSTART:20190107T093000" {
+		t.Errorf("expected a floating DTSTART, got %v", start)
+	}
+	if !decoded.StartDate.Equal(c.Events[0].StartDate) {
+		t.Errorf("expected %s, got %s", c.Events[0].StartDate, decoded.StartDate)
+	}
+
+	// the raw properties agree with the floating typed fields
+	data, _ = json.Marshal(c.Events[0])
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCalendarJSONRoundTrip(t *testing.T) {
+	for _, filename := range []string{"fixtures/with-alarm.ics", "fixtures/work.ics", "fixtures/invitation.ics"} {
+		c := parseFixture(t, filename)
+		data, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := &Calendar{}
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if d := Diff(c, decoded); !d.Empty() {
+			t.Errorf("%s: expected no difference, got %v", filename, d)
+		}
+
+		// events and todos are rebuilt alike without the raw properties
+		data, _ = JSONOptions{OmitProperties: true}.Marshal(c)
+		decoded = &Calendar{}
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Fatal(err)
+		}
+		again, _ := JSONOptions{OmitProperties: true}.Marshal(decoded)
+		if string(again) != string(data) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", filename, data, again)
+		}
+	}
+}
+
+func TestRecurString(t *testing.T) {
+	for _, rule := range []string{
+		"FREQ=WEEKLY;COUNT=4",
+		"FREQ=MONTHLY;INTERVAL=2;BYDAY=1MO,-1FR",
+		"FREQ=DAILY;UNTIL=20190131T000000Z;WKST=SU",
+		"FREQ=YEARLY;UNTIL=20190131;BYMONTHDAY=-1;BYMONTH=1,2",
+	} {
+		r, err := ParseRecur(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.String() != rule {
+			t.Errorf("expected %s, got %s", rule, r.String())
+		}
+	}
+
+	r := &Recur{Freq: "DAILY", Until: time.Date(2019, 1, 31, 10, 0, 0, 0, time.UTC), Interval: 1, WeekStart: time.Monday}
+	if r.String() != "FREQ=DAILY;UNTIL=20190131T100000Z" {
+		t.Errorf("unexpected %s", r.String())
+	}
+}
@@ -40,6 +40,10 @@ const (
 	itemEndStandard     // END:STANDARD
 	itemBeginDaylight   // BEGIN:DAYLIGHT
 	itemEndDaylight     // END:DAYLIGHT
+	itemBeginVTodo      // BEGIN:VTODO
+	itemEndVTodo        // END:VTODO
+	itemBeginVFreeBusy  // BEGIN:VFREEBUSY
+	itemEndVFreeBusy    // END:VFREEBUSY
 )
 
 var key = map[string]itemType{
@@ -55,6 +59,10 @@ var key = map[string]itemType{
 	"END:STANDARD":    itemEndStandard,
 	"BEGIN:DAYLIGHT":  itemBeginDaylight,
 	"END:DAYLIGHT":    itemEndDaylight,
+	"BEGIN:VTODO":     itemBeginVTodo,
+	"END:VTODO":       itemEndVTodo,
+	"BEGIN:VFREEBUSY": itemBeginVFreeBusy,
+	"END:VFREEBUSY":   itemEndVFreeBusy,
 }
 
 const eof = -1
@@ -167,6 +175,10 @@ const (
 	endStandard    = "END:STANDARD"
 	beginDaylight  = "BEGIN:DAYLIGHT"
 	endDaylight    = "END:DAYLIGHT"
+	beginVTodo     = "BEGIN:VTODO"
+	endVTodo       = "END:VTODO"
+	beginVFreeBusy = "BEGIN:VFREEBUSY"
+	endVFreeBusy   = "END:VFREEBUSY"
 )
 
 func lexContentLine(l *lexer) stateFn {
@@ -339,6 +351,46 @@ func lexName(l *lexer) stateFn {
 		return lexNewLine
 	}
 
+	// BEGIN:VTODO
+	if strings.HasPrefix(l.input[l.pos:], beginVTodo) {
+		l.pos += len(beginVTodo)
+		l.emit(itemBeginVTodo)
+		if debug {
+			fmt.Println("lexNewLine(): ", beginVTodo)
+		}
+		return lexNewLine
+	}
+
+	// END:VTODO
+	if strings.HasPrefix(l.input[l.pos:], endVTodo) {
+		l.pos += len(endVTodo)
+		l.emit(itemEndVTodo)
+		if debug {
+			fmt.Println("lexNewLine(): ", endVTodo)
+		}
+		return lexNewLine
+	}
+
+	// BEGIN:VFREEBUSY
+	if strings.HasPrefix(l.input[l.pos:], beginVFreeBusy) {
+		l.pos += len(beginVFreeBusy)
+		l.emit(itemBeginVFreeBusy)
+		if debug {
+			fmt.Println("lexNewLine(): ", beginVFreeBusy)
+		}
+		return lexNewLine
+	}
+
+	// END:VFREEBUSY
+	if strings.HasPrefix(l.input[l.pos:], endVFreeBusy) {
+		l.pos += len(endVFreeBusy)
+		l.emit(itemEndVFreeBusy)
+		if debug {
+			fmt.Println("lexNewLine(): ", endVFreeBusy)
+		}
+		return lexNewLine
+	}
+
 Loop:
 	for {
 		switch r := l.next(); {
@@ -0,0 +1,535 @@
+package ical
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	recurType    = reflect.TypeOf(&Recur{})
+)
+
+// icalTag is the parsed ical tag of a struct field:
+// `ical:"NAME[,param=PARAM][,date][,floating][,omitempty]"`
+type icalTag struct {
+	name      string
+	param     string // Param of the property held by the field, rather than its value
+	date      bool   // Times are written as dates
+	floating  bool   // Times are written as floating date-times
+	omitEmpty bool   // Zero values are left out
+}
+
+// parseICalTag parses the ical tag of a field, false when not tagged
+func parseICalTag(field reflect.StructField) (icalTag, bool) {
+	tag := field.Tag.Get("ical")
+	if tag == "" || tag == "-" {
+		return icalTag{}, false
+	}
+	options := strings.Split(tag, ",")
+	t := icalTag{name: strings.ToUpper(strings.TrimSpace(options[0]))}
+	for _, option := range options[1:] {
+		switch option = strings.TrimSpace(option); {
+		case strings.HasPrefix(option, "param="):
+			t.param = strings.ToUpper(strings.TrimPrefix(option, "param="))
+		case option == "date":
+			t.date = true
+		case option == "floating":
+			t.floating = true
+		case option == "omitempty":
+			t.omitEmpty = true
+		}
+	}
+	return t, t.name != ""
+}
+
+// Unmarshal copies the properties of a component into the fields of the
+// struct pointed to by v, according to their ical tags:
+//
+//	UID   string     `ical:"UID"`
+//	Start time.Time  `ical:"DTSTART"`
+//	CRMID string     `ical:"X-CRM-ID"`
+//	From  string     `ical:"X-CRM-ID,param=X-SRC"`
+//	Tags  []string   `ical:"CATEGORIES"`
+//	Alarm []Reminder `ical:"VALARM"`
+//
+// The component is an *Event, *Todo, *Alarm, *FreeBusy or *Calendar.
+// Fields are strings, texts being unescaped, bools, ints, floats,
+// time.Time, time.Duration, *Recur, or slices of them for properties which
+// occur several times or hold a list of values. A param field holds the
+// param values of the first property of its name. Structs tagged VALARM
+// are filled from the alarms of the component. Floating times are in the
+// system location, and fields without property are left unchanged.
+func Unmarshal(component interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Unmarshal needs a pointer to a struct, got %T", v)
+	}
+
+	var properties []*Property
+	var alarms []*Alarm
+	switch c := component.(type) {
+	case *Event:
+		properties, alarms = c.Properties, c.Alarms
+	case *Todo:
+		properties, alarms = c.Properties, c.Alarms
+	case *Alarm:
+		properties = c.Properties
+	case *FreeBusy:
+		properties = c.Properties
+	case *Calendar:
+		properties = c.Properties
+	default:
+		return fmt.Errorf("unsupported component %T", component)
+	}
+	return unmarshalStruct(properties, alarms, rv.Elem())
+}
+
+// unmarshalStruct fills the tagged fields of a struct, and of its embedded
+// structs
+func unmarshalStruct(properties []*Property, alarms []*Alarm, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag, ok := parseICalTag(field)
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("ical") != "-" {
+				if err := unmarshalStruct(properties, alarms, rv.Field(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		fv := rv.Field(i)
+		if tag.name == "VALARM" {
+			if err := unmarshalAlarms(alarms, fv, field.Name); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if tag.param != "" {
+			prop := getProperty(tag.name, properties)
+			if prop == nil || prop.Params[tag.param] == nil {
+				continue
+			}
+			values := prop.Params[tag.param].Values
+			switch {
+			case fv.Kind() == reflect.String && len(values) > 0:
+				fv.SetString(values[0])
+			case fv.Type() == reflect.TypeOf([]string{}):
+				fv.Set(reflect.ValueOf(append([]string{}, values...)))
+			case fv.Kind() != reflect.String:
+				return fmt.Errorf("unsupported type %s of field %s, a param is a string or []string", fv.Type(), field.Name)
+			}
+			continue
+		}
+
+		var props []*Property
+		for _, prop := range properties {
+			if prop.Name == tag.name {
+				props = append(props, prop)
+			}
+		}
+		if len(props) == 0 {
+			continue
+		}
+		if err := unmarshalField(props, fv, field.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalAlarms fills a struct, a pointer to a struct or a slice of them
+// from the alarms of a component
+func unmarshalAlarms(alarms []*Alarm, fv reflect.Value, fieldName string) error {
+	switch {
+	case fv.Kind() == reflect.Struct:
+		if len(alarms) > 0 {
+			return unmarshalStruct(alarms[0].Properties, nil, fv)
+		}
+	case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+		if len(alarms) > 0 {
+			elem := reflect.New(fv.Type().Elem())
+			if err := unmarshalStruct(alarms[0].Properties, nil, elem.Elem()); err != nil {
+				return err
+			}
+			fv.Set(elem)
+		}
+	case fv.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(fv.Type(), 0, len(alarms))
+		for _, a := range alarms {
+			elem := reflect.New(fv.Type().Elem())
+			if err := unmarshalAlarms([]*Alarm{a}, elem.Elem(), fieldName); err != nil {
+				return err
+			}
+			slice = reflect.Append(slice, elem.Elem())
+		}
+		fv.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s of field %s, alarms are structs", fv.Type(), fieldName)
+	}
+	return nil
+}
+
+// unmarshalField sets a field from the properties of its name
+func unmarshalField(props []*Property, fv reflect.Value, fieldName string) error {
+	prop := props[0]
+	invalid := func(value string) error {
+		return fmt.Errorf("invalid %s value %q for field %s", prop.Name, value, fieldName)
+	}
+
+	switch fv.Type() {
+	case timeType:
+		t, err := parseDate(prop, time.Local)
+		if err != nil {
+			return invalid(prop.Value)
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := parseDuration(prop.Value)
+		if err != nil {
+			return invalid(prop.Value)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case recurType:
+		r, err := ParseRecur(prop.Value)
+		if err != nil {
+			return invalid(prop.Value)
+		}
+		fv.Set(reflect.ValueOf(r))
+		return nil
+	}
+
+	if fv.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(fv.Type(), 0, len(props))
+		for _, prop := range props {
+			values := []string{prop.Value}
+			if multiValued[prop.Name] {
+				values = strings.Split(prop.Value, ",")
+				if isText(prop) {
+					// split on the commas not escaped, the parts being unescaped once set
+					values = splitText(prop.Value, ',')
+					for i := range values {
+						values[i] = escapeText(values[i])
+					}
+				}
+			}
+			for _, value := range values {
+				elem := reflect.New(fv.Type().Elem()).Elem()
+				p := &Property{Name: prop.Name, Params: prop.Params, Value: value}
+				if err := unmarshalField([]*Property{p}, elem, fieldName); err != nil {
+					return err
+				}
+				slice = reflect.Append(slice, elem)
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		value := prop.Value
+		if isText(prop) {
+			value = unescapeText(value)
+		}
+		fv.SetString(value)
+	case reflect.Bool:
+		fv.SetBool(strings.EqualFold(prop.Value, "TRUE"))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(prop.Value, 10, fv.Type().Bits())
+		if err != nil {
+			return invalid(prop.Value)
+		}
+		fv.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(prop.Value, fv.Type().Bits())
+		if err != nil {
+			return invalid(prop.Value)
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s of field %s", fv.Type(), fieldName)
+	}
+	return nil
+}
+
+// Marshal returns the event made of the tagged fields of a struct, or a
+// pointer to a struct, as read by Unmarshal. Zero times and nil rules are
+// left out, as are the other zero values of the fields tagged omitempty.
+// Times are written as dates with the date option, as floating date-times
+// with the floating option, in UTC when in UTC or in the system location,
+// and with the TZID of their location otherwise. A param field is set on
+// the first property of its name. The event is given a new UID and a
+// DTSTAMP when not tagged, and its typed fields are set from its
+// properties.
+func Marshal(v interface{}) (*Event, error) {
+	properties, alarms, err := marshalComponent(v)
+	if err != nil {
+		return nil, err
+	}
+	e := NewEvent()
+	e.Properties = withIdentity(properties)
+	e.Alarms = alarms
+
+	if err := decodeEvent(e, "", time.Local); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// MarshalTodo returns the todo made of the tagged fields of a struct, as
+// Marshal does for events
+func MarshalTodo(v interface{}) (*Todo, error) {
+	properties, alarms, err := marshalComponent(v)
+	if err != nil {
+		return nil, err
+	}
+	t := NewTodo()
+	t.Properties = withIdentity(properties)
+	t.Alarms = alarms
+
+	if err := decodeTodo(t, "", time.Local); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// withIdentity adds a new UID and a DTSTAMP to properties without them, or
+// with an empty UID
+func withIdentity(properties []*Property) []*Property {
+	if prop := getProperty("UID", properties); prop == nil || prop.Value == "" {
+		setProperty("UID", newUID(), &properties)
+	}
+	if !hasProperty("DTSTAMP", properties) {
+		setProperty("DTSTAMP", formatDateTime(time.Now()), &properties)
+	}
+	return properties
+}
+
+// marshalComponent returns the properties and the alarms of a struct
+func marshalComponent(v interface{}) ([]*Property, []*Alarm, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("Marshal needs a struct, got %T", v)
+	}
+
+	m := &marshaler{properties: make([]*Property, 0), alarms: make([]*Alarm, 0)}
+	if err := m.marshalStruct(rv, false); err != nil {
+		return nil, nil, err
+	}
+	if err := m.marshalStruct(rv, true); err != nil {
+		return nil, nil, err
+	}
+	return m.properties, m.alarms, nil
+}
+
+// marshaler collects the properties and the alarms of a struct
+type marshaler struct {
+	properties []*Property
+	alarms     []*Alarm
+}
+
+// marshalStruct adds the properties of the tagged fields of a struct, and
+// of its embedded structs, then the params once every property is added
+func (m *marshaler) marshalStruct(rv reflect.Value, params bool) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag, ok := parseICalTag(field)
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("ical") != "-" {
+				if err := m.marshalStruct(rv.Field(i), params); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		fv := rv.Field(i)
+		switch {
+		case tag.param != "":
+			if params {
+				if err := m.marshalParam(tag, fv, field.Name); err != nil {
+					return err
+				}
+			}
+		case params:
+		case tag.name == "VALARM":
+			if err := m.marshalAlarms(fv, field.Name); err != nil {
+				return err
+			}
+		default:
+			if err := m.marshalField(tag, fv, field.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// marshalParam sets a param on the first property of its name
+func (m *marshaler) marshalParam(tag icalTag, fv reflect.Value, fieldName string) error {
+	var values []string
+	switch {
+	case fv.Kind() == reflect.String:
+		if fv.String() != "" {
+			values = []string{fv.String()}
+		}
+	case fv.Type() == reflect.TypeOf([]string{}):
+		values = fv.Interface().([]string)
+	default:
+		return fmt.Errorf("unsupported type %s of field %s, a param is a string or []string", fv.Type(), fieldName)
+	}
+	prop := getProperty(tag.name, m.properties)
+	if prop == nil || len(values) == 0 {
+		return nil
+	}
+	prop.Params[tag.param] = &Param{Values: append([]string{}, values...)}
+	return nil
+}
+
+// marshalAlarms adds the alarm of a struct, of a pointer to a struct or of
+// each item of a slice
+func (m *marshaler) marshalAlarms(fv reflect.Value, fieldName string) error {
+	switch {
+	case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+		if fv.IsNil() {
+			return nil
+		}
+		return m.marshalAlarms(fv.Elem(), fieldName)
+	case fv.Kind() == reflect.Slice:
+		for i := 0; i < fv.Len(); i++ {
+			if err := m.marshalAlarms(fv.Index(i), fieldName); err != nil {
+				return err
+			}
+		}
+		return nil
+	case fv.Kind() != reflect.Struct:
+		return fmt.Errorf("unsupported type %s of field %s, alarms are structs", fv.Type(), fieldName)
+	}
+
+	properties, _, err := marshalComponent(fv.Interface())
+	if err != nil {
+		return err
+	}
+	a := NewAlarm()
+	a.Properties = properties
+	if err := decodeAlarm(a, time.Local); err != nil {
+		return fmt.Errorf("field %s: %v", fieldName, err)
+	}
+	m.alarms = append(m.alarms, a)
+	return nil
+}
+
+// marshalField adds the properties of a field
+func (m *marshaler) marshalField(tag icalTag, fv reflect.Value, fieldName string) error {
+	switch fv.Type() {
+	case timeType:
+		m.properties = append(m.properties, dateProperties(tag.name, []time.Time{fv.Interface().(time.Time)}, tag.date, tag.floating)...)
+		return nil
+	case reflect.TypeOf([]time.Time{}):
+		m.properties = append(m.properties, dateProperties(tag.name, fv.Interface().([]time.Time), tag.date, tag.floating)...)
+		return nil
+	case durationType:
+		if fv.Int() != 0 || !tag.omitEmpty {
+			m.add(tag.name, formatDuration(time.Duration(fv.Int())))
+		}
+		return nil
+	case recurType:
+		if !fv.IsNil() {
+			m.add(tag.name, fv.Interface().(*Recur).String())
+		}
+		return nil
+	}
+
+	if fv.Kind() == reflect.Slice {
+		var values []string
+		for i := 0; i < fv.Len(); i++ {
+			value, err := formatField(tag.name, fv.Index(i), fieldName)
+			if err != nil {
+				return err
+			}
+			values = append(values, value)
+		}
+		switch {
+		case len(values) == 0:
+		case multiValued[tag.name]:
+			m.add(tag.name, strings.Join(values, ","))
+		default:
+			for _, value := range values {
+				m.add(tag.name, value)
+			}
+		}
+		return nil
+	}
+
+	if tag.omitEmpty && isZero(fv) {
+		return nil
+	}
+	value, err := formatField(tag.name, fv, fieldName)
+	if err != nil {
+		return err
+	}
+	m.add(tag.name, value)
+	return nil
+}
+
+func (m *marshaler) add(name, value string) {
+	prop := NewProperty()
+	prop.Name = name
+	prop.Value = value
+	m.properties = append(m.properties, prop)
+}
+
+// formatField formats the value of a field of a basic type, a text escaped
+func formatField(name string, fv reflect.Value, fieldName string) (string, error) {
+	switch fv.Kind() {
+	case reflect.String:
+		if isText(&Property{Name: name}) {
+			return escapeText(fv.String()), nil
+		}
+		return fv.String(), nil
+	case reflect.Bool:
+		if fv.Bool() {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, fv.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s of field %s", fv.Type(), fieldName)
+}
+
+// isText checks if the value of a property is a text, the default for the
+// properties of an unknown type
+// from rfc5545-3.8.8.2
+func isText(prop *Property) bool {
+	typ := valueType(prop)
+	return typ == "text" || typ == "unknown"
+}
+
+// isZero checks if a value is the zero value of its type
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
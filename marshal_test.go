@@ -0,0 +1,161 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type testReminder struct {
+	Action string        `ical:"ACTION"`
+	Before time.Duration `ical:"TRIGGER"`
+	Note   string        `ical:"DESCRIPTION,omitempty"`
+}
+
+type testMeta struct {
+	CRMID  string `ical:"X-CRM-ID,omitempty"`
+	Source string `ical:"X-CRM-ID,param=X-SRC"`
+}
+
+type testDeal struct {
+	testMeta
+	UID       string         `ical:"UID"`
+	Start     time.Time      `ical:"DTSTART"`
+	End       time.Time      `ical:"DTEND"`
+	Title     string         `ical:"SUMMARY"`
+	Sequence  int            `ical:"SEQUENCE,omitempty"`
+	Amount    float64        `ical:"X-CRM-AMOUNT,omitempty"`
+	Won       bool           `ical:"X-CRM-WON"`
+	Tags      []string       `ical:"CATEGORIES"`
+	Attendees []string       `ical:"ATTENDEE"`
+	Rule      *Recur         `ical:"RRULE"`
+	Reminders []testReminder `ical:"VALARM"`
+	Ignored   string
+	Skipped   string `ical:"-"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	c := parseFixture(t, "fixtures/invitation.ics")
+	v := c.Events[0]
+	setProperty("CATEGORIES", `Sales,Q1\, EMEA`, &v.Properties)
+	setProperty("X-CRM-WON", "TRUE", &v.Properties)
+	setProperty("X-CRM-AMOUNT", "1250.5", &v.Properties)
+	prop := setProperty("X-CRM-ID", "D-42", &v.Properties)
+	prop.Params["X-SRC"] = &Param{Values: []string{"hubspot"}}
+
+	deal := testDeal{Ignored: "kept"}
+	if err := Unmarshal(v, &deal); err != nil {
+		t.Fatal(err)
+	}
+	if deal.UID != "meeting@example.com" || deal.Title != "Planning" || deal.Sequence != 1 || !deal.Won || deal.Amount != 1250.5 {
+		t.Errorf("unexpected deal %+v", deal)
+	}
+	if deal.CRMID != "D-42" || deal.Source != "hubspot" || deal.Ignored != "kept" {
+		t.Errorf("unexpected embedded fields %+v", deal)
+	}
+	if !deal.Start.Equal(time.Date(2019, 1, 10, 10, 0, 0, 0, time.UTC)) || !deal.End.Equal(time.Date(2019, 1, 10, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected dates %s %s", deal.Start, deal.End)
+	}
+	if strings.Join(deal.Tags, "|") != "Sales|Q1, EMEA" || len(deal.Attendees) != 3 {
+		t.Errorf("unexpected lists %q %q", deal.Tags, deal.Attendees)
+	}
+	if deal.Rule == nil || deal.Rule.Count != 4 {
+		t.Errorf("unexpected rule %v", deal.Rule)
+	}
+	if len(deal.Reminders) != 1 || deal.Reminders[0].Before != -10*time.Minute || deal.Reminders[0].Action != "DISPLAY" {
+		t.Errorf("unexpected reminders %+v", deal.Reminders)
+	}
+
+	setProperty("SEQUENCE", "first", &v.Properties)
+	if err := Unmarshal(v, &deal); err == nil {
+		t.Error("expected an error for an invalid integer")
+	}
+	if err := Unmarshal(v, deal); err == nil {
+		t.Error("expected an error for a struct not passed by pointer")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	deal := &testDeal{
+		testMeta:  testMeta{CRMID: "D-42", Source: "hubspot"},
+		Start:     time.Date(2019, 3, 4, 14, 0, 0, 0, paris),
+		End:       time.Date(2019, 3, 4, 15, 0, 0, 0, paris),
+		Title:     "Budget; review",
+		Tags:      []string{"Sales", "Q1, EMEA"},
+		Attendees: []string{"mailto:alice@example.com", "mailto:bob@example.com"},
+		Rule:      &Recur{Freq: "WEEKLY", Count: 2, Interval: 1, WeekStart: time.Monday},
+		Reminders: []testReminder{{Action: "DISPLAY", Before: -15 * time.Minute, Note: "Budget"}},
+	}
+	v, err := Marshal(deal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, prop := range v.Properties {
+		if prop.Name != "UID" && prop.Name != "DTSTAMP" {
+			lines = append(lines, encodeProperty(prop))
+		}
+	}
+	expected := []string{
+		"X-CRM-ID;X-SRC=hubspot:D-42",
+		"DTSTART;TZID=Europe/Paris:20190304T140000",
+		"DTEND;TZID=Europe/Paris:20190304T150000",
+		`SUMMARY:Budget\; review`,
+		"X-CRM-WON:FALSE",
+		`CATEGORIES:Sales,Q1\, EMEA`,
+		"ATTENDEE:mailto:alice@example.com",
+		"ATTENDEE:mailto:bob@example.com",
+		"RRULE:FREQ=WEEKLY;COUNT=2",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+	if v.UID == "" || v.Timestamp.IsZero() || !v.StartDate.Equal(deal.Start) || v.RRule == nil {
+		t.Errorf("expected typed fields set, got %+v", v)
+	}
+	if len(v.Alarms) != 1 || v.Alarms[0].Offset != -15*time.Minute {
+		t.Errorf("unexpected alarms %+v", v.Alarms)
+	}
+
+	var back testDeal
+	if err := Unmarshal(v, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Title != deal.Title || strings.Join(back.Tags, "|") != "Sales|Q1, EMEA" || back.Source != "hubspot" {
+		t.Errorf("unexpected deal %+v", back)
+	}
+
+	if _, err := Marshal(struct {
+		Start time.Time         `ical:"DTSTART,date"`
+		Map   map[string]string `ical:"X-MAP"`
+	}{Start: time.Now()}); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+	todo, err := MarshalTodo(struct {
+		Due time.Time `ical:"DUE,date"`
+	}{Due: time.Date(2019, 3, 29, 0, 0, 0, 0, time.Local)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prop := getProperty("DUE", todo.Properties); prop == nil || encodeProperty(prop) != "DUE;VALUE=DATE:20190329" {
+		t.Errorf("unexpected DUE %v", prop)
+	}
+
+	// times in the system location are in UTC, unless floating
+	start := time.Date(2019, 3, 29, 9, 30, 0, 0, time.Local)
+	v, err = Marshal(struct {
+		Start time.Time `ical:"DTSTART"`
+		End   time.Time `ical:"DTEND,floating"`
+	}{Start: start, End: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prop := getProperty("DTSTART", v.Properties); prop == nil || encodeProperty(prop) != "DTSTART:"+formatDateTime(start) {
+		t.Errorf("unexpected DTSTART %v", prop)
+	}
+	if prop := getProperty("DTEND", v.Properties); prop == nil || encodeProperty(prop) != "DTEND:20190329T103000" {
+		t.Errorf("unexpected DTEND %v", prop)
+	}
+}
@@ -0,0 +1,183 @@
+package ical
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MergeOptions sets the calendar properties of the result of Merge
+type MergeOptions struct {
+	ProdID  string // PRODID of the result, defaults to ProdID
+	Version string // VERSION of the result, defaults to 2.0
+}
+
+// Merge combines the events, todos, timezones, free/busy and unknown
+// components of calendars into a new one. Events and todos sharing an UID and RECURRENCE-ID
+// are deduplicated, keeping the highest SEQUENCE, then the latest
+// LAST-MODIFIED, then the latest DTSTAMP, then the first one. Identical
+// VTIMEZONEs are kept once, and a VTIMEZONE with the TZID of a different one
+// is renamed along with the TZID params referring to it, in the components,
+// their alarms and their unknown subcomponents. The calendars are left
+// unchanged.
+func Merge(opts MergeOptions, calendars ...*Calendar) *Calendar {
+	r := NewCalendar()
+	r.Prodid = opts.ProdID
+	if r.Prodid == "" {
+		r.Prodid = ProdID
+	}
+	r.Version = opts.Version
+	if r.Version == "" {
+		r.Version = "2.0"
+	}
+	setProperty("PRODID", r.Prodid, &r.Properties)
+	setProperty("VERSION", r.Version, &r.Properties)
+	setProperty("CALSCALE", r.Calscale, &r.Properties)
+
+	timezones := make(map[string]*Timezone) // by TZID, as merged
+	events := make(map[string]int)          // index of the event by key
+	todos := make(map[string]int)           // index of the todo by key
+
+	for _, c := range calendars {
+		renames := make(map[string]string)
+		for _, tz := range c.Timezones {
+			id := timezoneID(tz)
+			name := id
+			for n := 2; ; n++ {
+				existing, ok := timezones[name]
+				if !ok {
+					tz = tz.clone()
+					if name != id {
+						setProperty("TZID", name, &tz.Properties)
+					}
+					timezones[name] = tz
+					r.Timezones = append(r.Timezones, tz)
+					break
+				}
+				if timezoneContent(existing, name) == timezoneContent(tz, name) {
+					break
+				}
+				name = id + "-" + strconv.Itoa(n)
+			}
+			if name != id {
+				renames[id] = name
+			}
+		}
+
+		for _, v := range c.Events {
+			v = v.clone()
+			renameTZIDs(v.Properties, renames)
+			renameAlarmTZIDs(v.Alarms, renames)
+			renameComponentTZIDs(v.Components, renames)
+			key := componentKey(v.UID, v.RecurrenceID)
+			i, ok := events[key]
+			switch {
+			case v.UID == "" || !ok:
+				events[key] = len(r.Events)
+				r.Events = append(r.Events, v)
+			case newerRevision(v.Sequence, v.Timestamp, v.Properties, r.Events[i].Sequence, r.Events[i].Timestamp, r.Events[i].Properties):
+				r.Events[i] = v
+			}
+		}
+
+		for _, t := range c.Todos {
+			t = t.clone()
+			renameTZIDs(t.Properties, renames)
+			renameAlarmTZIDs(t.Alarms, renames)
+			renameComponentTZIDs(t.Components, renames)
+			key := componentKey(t.UID, t.RecurrenceID)
+			i, ok := todos[key]
+			switch {
+			case t.UID == "" || !ok:
+				todos[key] = len(r.Todos)
+				r.Todos = append(r.Todos, t)
+			case newerRevision(t.Sequence, t.Timestamp, t.Properties, r.Todos[i].Sequence, r.Todos[i].Timestamp, r.Todos[i].Properties):
+				r.Todos[i] = t
+			}
+		}
+
+		for _, f := range c.FreeBusys {
+			fb := *f
+			fb.Properties = cloneProperties(f.Properties)
+			r.FreeBusys = append(r.FreeBusys, &fb)
+		}
+
+		components := make(cloner).components(c.Components)
+		renameComponentTZIDs(components, renames)
+		r.Components = append(r.Components, components...)
+	}
+
+	return r
+}
+
+// newerRevision checks if a component is a newer revision than another one,
+// by SEQUENCE, then LAST-MODIFIED, then DTSTAMP
+// from rfc5546-2.1.5
+func newerRevision(sequence int, stamp time.Time, properties []*Property, otherSequence int, otherStamp time.Time, otherProperties []*Property) bool {
+	if sequence != otherSequence {
+		return sequence > otherSequence
+	}
+	modified, otherModified := lastModified(properties), lastModified(otherProperties)
+	if !modified.Equal(otherModified) && !modified.IsZero() && !otherModified.IsZero() {
+		return modified.After(otherModified)
+	}
+	return stamp.After(otherStamp)
+}
+
+// lastModified returns the LAST-MODIFIED of a component, zero if not set
+func lastModified(properties []*Property) time.Time {
+	if prop := getProperty("LAST-MODIFIED", properties); prop != nil {
+		if t, err := parseDate(prop, time.UTC); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// timezoneContent returns the content lines of a timezone as if its TZID
+// was the given one
+func timezoneContent(tz *Timezone, id string) string {
+	var b strings.Builder
+	for _, prop := range tz.Properties {
+		if prop.Name == "TZID" {
+			b.WriteString("TZID:" + id + crlf)
+			continue
+		}
+		b.WriteString(formatProperty(prop) + crlf)
+	}
+	b.WriteString(rulesContent(tz))
+	return b.String()
+}
+
+// renameTZIDs renames the TZID params of properties
+func renameTZIDs(properties []*Property, renames map[string]string) {
+	if len(renames) == 0 {
+		return
+	}
+	for _, prop := range properties {
+		if param, ok := prop.Params["TZID"]; ok {
+			for i, value := range param.Values {
+				if name, ok := renames[value]; ok {
+					param.Values[i] = name
+				}
+			}
+		}
+	}
+}
+
+// renameAlarmTZIDs renames the TZID params of alarms
+func renameAlarmTZIDs(alarms []*Alarm, renames map[string]string) {
+	for _, a := range alarms {
+		renameTZIDs(a.Properties, renames)
+		renameComponentTZIDs(a.Components, renames)
+	}
+}
+
+// renameComponentTZIDs renames the TZID params of unknown components and
+// of their subcomponents
+func renameComponentTZIDs(components []*Component, renames map[string]string) {
+	for _, c := range components {
+		renameTZIDs(c.Properties, renames)
+		renameComponentTZIDs(c.Components, renames)
+	}
+}
@@ -0,0 +1,364 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A MergeConflict is a change made differently on both sides of a three-way
+// merge, or a component removed on one side and modified on the other
+type MergeConflict struct {
+	Component    string      // VEVENT or VTODO
+	UID          string      // UID of the component
+	RecurrenceID string      // RECURRENCE-ID of an overridden instance, as an UTC date-time
+	Name         string      // Name of the property, VALARM for the alarms, empty for a removed component
+	Base         []*Property // Properties of the base, nil for the alarms or a removed component
+	Ours         []*Property // Properties of our side
+	Theirs       []*Property // Properties of their side
+}
+
+// A Resolution tells which side of a conflict wins
+type Resolution int
+
+// Resolutions of a conflict
+const (
+	ResolveOurs   Resolution = iota // Keep our change
+	ResolveTheirs                   // Keep their change
+)
+
+// A ConflictResolver picks the winning side of a conflict
+type ConflictResolver func(c MergeConflict) Resolution
+
+// Merge3 merges the changes made on two sides, ours and theirs, to a common
+// base calendar. Events and todos are matched by UID and RECURRENCE-ID, and
+// their changes merged property by property, attendees being told apart by
+// address. The highest SEQUENCE and the latest DTSTAMP and LAST-MODIFIED are
+// kept. A property changed differently on both sides is a conflict, as well
+// as a component removed on one side and modified on the other: the resolver
+// picks the winner, ours when nil. The conflicts are returned with the
+// result, which holds the calendar properties, free/busy and unknown
+// components of our side and the timezones of both.
+//
+// A merged component may no longer be valid, e.g. with a DTEND added on one
+// side and a DURATION on the other. It is kept with its merged properties,
+// and the first one is reported in the error along with the whole result.
+func Merge3(base, ours, theirs *Calendar, resolve ConflictResolver) (*Calendar, []MergeConflict, error) {
+	if resolve == nil {
+		resolve = func(MergeConflict) Resolution { return ResolveOurs }
+	}
+
+	r := NewCalendar()
+	r.Prodid, r.Version, r.Calscale, r.Method = ours.Prodid, ours.Version, ours.Calscale, ours.Method
+	m := make(cloner)
+	r.Properties = m.properties(ours.Properties)
+	r.Components = m.components(ours.Components)
+	r.order = m.order(ours.order)
+	tzids := make(map[string]bool)
+	for _, tz := range append(append([]*Timezone{}, ours.Timezones...), theirs.Timezones...) {
+		if id := timezoneID(tz); !tzids[id] {
+			tzids[id] = true
+			r.Timezones = append(r.Timezones, tz.clone())
+		}
+	}
+	for _, f := range ours.FreeBusys {
+		fb := *f
+		fb.Properties = cloneProperties(f.Properties)
+		r.FreeBusys = append(r.FreeBusys, &fb)
+	}
+
+	var conflicts []MergeConflict
+	var err error
+	invalid := func(kind, uid string, e error) {
+		if err == nil {
+			err = fmt.Errorf("merged %s %q: %v", kind, uid, e)
+		}
+	}
+
+	events := func(c *Calendar) []mergeComponent {
+		var components []mergeComponent
+		for _, v := range c.Events {
+			components = append(components, mergeComponent{uid: v.UID, recurrenceID: formatRecurrenceID(v.RecurrenceID), properties: v.Properties, alarms: v.Alarms, start: v.StartDate})
+		}
+		return components
+	}
+	merged, c := merge3Components("VEVENT", events(base), events(ours), events(theirs), resolve)
+	conflicts = append(conflicts, c...)
+	for _, m := range merged {
+		v := NewEvent()
+		v.Properties = m.properties
+		v.Alarms = m.alarms
+		if e := decodeEvent(v, r.Method, floatingLocation(m.properties, m.start)); e != nil {
+			invalid("VEVENT", m.uid, e)
+		}
+		r.Events = append(r.Events, v)
+	}
+
+	todos := func(c *Calendar) []mergeComponent {
+		var components []mergeComponent
+		for _, t := range c.Todos {
+			components = append(components, mergeComponent{uid: t.UID, recurrenceID: formatRecurrenceID(t.RecurrenceID), properties: t.Properties, alarms: t.Alarms, start: t.StartDate})
+		}
+		return components
+	}
+	merged, c = merge3Components("VTODO", todos(base), todos(ours), todos(theirs), resolve)
+	conflicts = append(conflicts, c...)
+	for _, m := range merged {
+		t := NewTodo()
+		t.Properties = m.properties
+		t.Alarms = m.alarms
+		if e := decodeTodo(t, r.Method, floatingLocation(m.properties, m.start)); e != nil {
+			invalid("VTODO", m.uid, e)
+		}
+		r.Todos = append(r.Todos, t)
+	}
+
+	return r, conflicts, err
+}
+
+// a mergeComponent is an event or a todo being merged
+type mergeComponent struct {
+	uid          string
+	recurrenceID string
+	properties   []*Property
+	alarms       []*Alarm
+	start        time.Time // Typed DTSTART, for the location of floating times
+}
+
+func (m *mergeComponent) key() string {
+	return m.uid + "\n" + m.recurrenceID
+}
+
+// content returns the content lines of the component
+func (m *mergeComponent) content() string {
+	var b strings.Builder
+	for _, prop := range m.properties {
+		b.WriteString(formatProperty(prop) + crlf)
+	}
+	return b.String() + alarmsContent(m.alarms)
+}
+
+// floatingLocation returns the location of the floating times of a
+// component, the one of its typed DTSTART when floating, else the system
+// location
+func floatingLocation(properties []*Property, start time.Time) *time.Location {
+	prop := getProperty("DTSTART", properties)
+	if prop != nil && prop.Params["TZID"] == nil && !strings.HasSuffix(prop.Value, "Z") && !start.IsZero() {
+		return start.Location()
+	}
+	return time.Local
+}
+
+// merge3Components merges the components of both sides, in the order of our
+// side then of theirs
+func merge3Components(kind string, base, ours, theirs []mergeComponent, resolve ConflictResolver) ([]mergeComponent, []MergeConflict) {
+	index := func(components []mergeComponent) map[string]*mergeComponent {
+		m := make(map[string]*mergeComponent)
+		for i := range components {
+			m[components[i].key()] = &components[i]
+		}
+		return m
+	}
+	baseByKey, oursByKey, theirsByKey := index(base), index(ours), index(theirs)
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, list := range [][]mergeComponent{ours, theirs} {
+		for i := range list {
+			if key := list[i].key(); !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	var merged []mergeComponent
+	var conflicts []MergeConflict
+	for _, key := range keys {
+		b, o, t := baseByKey[key], oursByKey[key], theirsByKey[key]
+		conflict := MergeConflict{Component: kind}
+		if o != nil {
+			conflict.UID, conflict.RecurrenceID = o.uid, o.recurrenceID
+		} else {
+			conflict.UID, conflict.RecurrenceID = t.uid, t.recurrenceID
+		}
+
+		switch {
+		case o != nil && t != nil:
+			m, c := merge3Component(conflict, b, o, t, resolve)
+			merged = append(merged, m)
+			conflicts = append(conflicts, c...)
+
+		case b == nil:
+			// added on one side
+			if o != nil {
+				merged = append(merged, o.copy())
+			} else {
+				merged = append(merged, t.copy())
+			}
+
+		default:
+			// removed on one side, kept if modified on the other one
+			kept := o
+			if kept == nil {
+				kept = t
+			}
+			if kept.content() == b.content() {
+				continue
+			}
+			conflict.Ours, conflict.Theirs = propertiesOf(o), propertiesOf(t)
+			conflicts = append(conflicts, conflict)
+			if winner := resolve(conflict); (winner == ResolveOurs) == (o != nil) {
+				merged = append(merged, kept.copy())
+			}
+		}
+	}
+	return merged, conflicts
+}
+
+// merge3Component merges the properties and alarms of a component present
+// on both sides, base being nil when added on both sides
+func merge3Component(conflict MergeConflict, b, o, t *mergeComponent, resolve ConflictResolver) (mergeComponent, []MergeConflict) {
+	if b == nil {
+		b = &mergeComponent{}
+	}
+	m := mergeComponent{uid: o.uid, recurrenceID: o.recurrenceID, start: o.start}
+	var conflicts []MergeConflict
+
+	baseFields, oursFields, theirsFields := mergeFields(b.properties), mergeFields(o.properties), mergeFields(t.properties)
+	var names []string
+	seen := make(map[string]bool)
+	for _, props := range [][]*Property{o.properties, theirsOnly(t.properties, oursFields)} {
+		for _, prop := range props {
+			if field := mergeField(prop); !seen[field] {
+				seen[field] = true
+				names = append(names, field)
+			}
+		}
+	}
+
+	for _, field := range names {
+		bp, op, tp := baseFields[field], oursFields[field], theirsFields[field]
+		var kept []*Property
+		name := strings.SplitN(field, "\n", 2)[0]
+		switch {
+		case name == "SEQUENCE":
+			kept = latest(op, tp, func(a, b *Property) bool {
+				x, _ := strconv.Atoi(a.Value)
+				y, _ := strconv.Atoi(b.Value)
+				return x > y
+			})
+		case name == "DTSTAMP" || name == "LAST-MODIFIED":
+			kept = latest(op, tp, func(a, b *Property) bool {
+				x, _ := parseDate(a, time.UTC)
+				y, _ := parseDate(b, time.UTC)
+				return x.After(y)
+			})
+		case propertiesContent(op) == propertiesContent(tp), propertiesContent(tp) == propertiesContent(bp):
+			kept = op
+		case propertiesContent(op) == propertiesContent(bp):
+			kept = tp
+		default:
+			c := conflict
+			c.Name, c.Base, c.Ours, c.Theirs = name, bp, op, tp
+			conflicts = append(conflicts, c)
+			kept = op
+			if resolve(c) == ResolveTheirs {
+				kept = tp
+			}
+		}
+		m.properties = append(m.properties, cloneProperties(kept)...)
+	}
+
+	ba, oa, ta := alarmsContent(b.alarms), alarmsContent(o.alarms), alarmsContent(t.alarms)
+	alarms := o.alarms
+	switch {
+	case oa == ta || ta == ba:
+	case oa == ba:
+		alarms = t.alarms
+	default:
+		c := conflict
+		c.Name = "VALARM"
+		conflicts = append(conflicts, c)
+		if resolve(c) == ResolveTheirs {
+			alarms = t.alarms
+		}
+	}
+	for _, a := range alarms {
+		m.alarms = append(m.alarms, a.clone())
+	}
+
+	return m, conflicts
+}
+
+// mergeField returns the field of a property in a three-way merge: its
+// name, with the address for an attendee
+func mergeField(prop *Property) string {
+	if prop.Name == "ATTENDEE" {
+		return prop.Name + "\n" + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(prop.Value)), "mailto:")
+	}
+	return prop.Name
+}
+
+// mergeFields groups properties by field
+func mergeFields(properties []*Property) map[string][]*Property {
+	fields := make(map[string][]*Property)
+	for _, prop := range properties {
+		field := mergeField(prop)
+		fields[field] = append(fields[field], prop)
+	}
+	return fields
+}
+
+// theirsOnly returns the properties of fields missing on our side
+func theirsOnly(properties []*Property, ours map[string][]*Property) []*Property {
+	var only []*Property
+	for _, prop := range properties {
+		if _, ok := ours[mergeField(prop)]; !ok {
+			only = append(only, prop)
+		}
+	}
+	return only
+}
+
+// latest returns the greatest property of both sides
+func latest(ours, theirs []*Property, greater func(a, b *Property) bool) []*Property {
+	switch {
+	case len(ours) == 0:
+		return theirs
+	case len(theirs) == 0:
+		return ours
+	case greater(theirs[0], ours[0]):
+		return theirs
+	}
+	return ours
+}
+
+// propertiesContent returns the content lines of properties
+func propertiesContent(properties []*Property) string {
+	var b strings.Builder
+	for _, prop := range properties {
+		b.WriteString(formatProperty(prop) + crlf)
+	}
+	return b.String()
+}
+
+// propertiesOf returns the properties of a component, nil for none
+func propertiesOf(m *mergeComponent) []*Property {
+	if m == nil {
+		return nil
+	}
+	return m.properties
+}
+
+// copy returns a deep copy of the component
+func (m *mergeComponent) copy() mergeComponent {
+	c := *m
+	c.properties = cloneProperties(m.properties)
+	c.alarms = make([]*Alarm, len(m.alarms))
+	for i, a := range m.alarms {
+		c.alarms[i] = a.clone()
+	}
+	return c
+}
@@ -0,0 +1,148 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base := NewCalendar()
+	base.Properties = []*Property{{Name: "PRODID", Value: "-//test//EN", Params: map[string]*Param{}}, {Name: "VERSION", Value: "2.0", Params: map[string]*Param{}}}
+	base.Events = append(base.Events, invitation(t))
+	lunch := invitation(t)
+	setProperty("UID", "lunch@example.com", &lunch.Properties)
+	lunch.UID = "lunch@example.com"
+	base.Events = append(base.Events, lunch)
+
+	edit := func(c *Calendar, uid string, f func(v *Event)) {
+		for _, v := range c.Events {
+			if v.UID == uid {
+				f(v)
+			}
+		}
+	}
+	copyOf := func(c *Calendar) *Calendar {
+		r := *c
+		r.Events = nil
+		for _, v := range c.Events {
+			r.Events = append(r.Events, v.clone())
+		}
+		return &r
+	}
+	partstat := func(v *Event, address, value string) {
+		for _, prop := range v.Properties {
+			if prop.Name == "ATTENDEE" && sameAddress(prop.Value, address) {
+				prop.Params["PARTSTAT"] = &Param{Values: []string{value}}
+			}
+		}
+	}
+
+	ours := copyOf(base)
+	edit(ours, "meeting@example.com", func(v *Event) {
+		setProperty("LOCATION", "Room 2", &v.Properties)
+		setProperty("SEQUENCE", "2", &v.Properties)
+		setProperty("DTSTART", "20190110T110000Z", &v.Properties)
+		setProperty("DTEND", "20190110T120000Z", &v.Properties)
+		partstat(v, "mailto:bob@example.com", "ACCEPTED")
+	})
+	edit(ours, "lunch@example.com", func(v *Event) {
+		setProperty("SUMMARY", "Team lunch", &v.Properties)
+	})
+
+	theirs := copyOf(base)
+	edit(theirs, "meeting@example.com", func(v *Event) {
+		setProperty("SUMMARY", "Quarterly planning", &v.Properties)
+		setProperty("DTSTART", "20190110T090000Z", &v.Properties)
+		partstat(v, "mailto:carol@example.com", "DECLINED")
+	})
+	theirs.Events = theirs.Events[:1] // lunch removed
+
+	var resolved []string
+	merged, conflicts, err := Merge3(base, ours, theirs, func(c MergeConflict) Resolution {
+		resolved = append(resolved, c.UID+" "+c.Name)
+		if c.Name == "DTSTART" {
+			return ResolveTheirs
+		}
+		return ResolveOurs
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(resolved, ","); got != "meeting@example.com DTSTART,lunch@example.com " {
+		t.Errorf("unexpected conflicts %s", got)
+	}
+	if len(conflicts) != 2 || propertiesContent(conflicts[0].Ours) != "DTSTART:20190110T110000Z\r\n" || propertiesContent(conflicts[0].Theirs) != "DTSTART:20190110T090000Z\r\n" {
+		t.Errorf("unexpected conflicts %+v", conflicts)
+	}
+
+	if len(merged.Events) != 2 {
+		t.Fatalf("expected the lunch modified on our side to be kept, got %d events", len(merged.Events))
+	}
+	v := merged.Events[0]
+	if v.Summary != "Quarterly planning" || v.Sequence != 2 || v.StartDate.Hour() != 9 || v.EndDate.Hour() != 12 {
+		t.Errorf("unexpected merged event %s %d %s %s", v.Summary, v.Sequence, v.StartDate, v.EndDate)
+	}
+	if location := getProperty("LOCATION", v.Properties).Value; location != "Room 2" {
+		t.Errorf("expected our location, got %s", location)
+	}
+	var statuses []string
+	for _, prop := range v.Properties {
+		if prop.Name == "ATTENDEE" {
+			statuses = append(statuses, prop.Params["PARTSTAT"].Values[0])
+		}
+	}
+	if got := strings.Join(statuses, ","); got != "ACCEPTED,ACCEPTED,DECLINED" {
+		t.Errorf("expected both participation changes, got %s", got)
+	}
+	if len(v.Alarms) != 1 || merged.Events[1].Summary != "Team lunch" {
+		t.Errorf("unexpected alarms or lunch %+v", merged.Events[1])
+	}
+
+	// without resolver, our side wins
+	merged, _, err = Merge3(base, ours, theirs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Events[0].StartDate.Hour() != 11 {
+		t.Errorf("expected our start, got %s", merged.Events[0].StartDate)
+	}
+
+	// unchanged on our side, removed on theirs
+	merged, conflicts, err = Merge3(base, base, theirs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Events) != 1 || len(conflicts) != 0 {
+		t.Errorf("expected the lunch removed without conflict, got %d events and %+v", len(merged.Events), conflicts)
+	}
+}
+
+func TestMerge3InvalidComponent(t *testing.T) {
+	base := NewCalendar()
+	base.Events = append(base.Events, invitation(t))
+	lunch := invitation(t)
+	setProperty("UID", "lunch@example.com", &lunch.Properties)
+	lunch.UID = "lunch@example.com"
+	base.Events = append(base.Events, lunch)
+
+	ours := NewCalendar()
+	theirs := NewCalendar()
+	for _, v := range base.Events {
+		ours.Events = append(ours.Events, v.clone())
+		theirs.Events = append(theirs.Events, v.clone())
+	}
+	setProperty("DURATION", "PT1H", &ours.Events[1].Properties) // along with DTEND
+	setProperty("SUMMARY", "Quarterly planning", &theirs.Events[0].Properties)
+
+	merged, _, err := Merge3(base, ours, theirs, nil)
+	if err == nil || !strings.Contains(err.Error(), "lunch@example.com") {
+		t.Fatalf("expected an error about the lunch, got %v", err)
+	}
+	if merged == nil || len(merged.Events) != 2 {
+		t.Fatalf("expected both events kept, got %+v", merged)
+	}
+	if merged.Events[0].Summary != "Quarterly planning" || !hasProperty("DURATION", merged.Events[1].Properties) {
+		t.Errorf("unexpected merge %+v %+v", merged.Events[0], merged.Events[1])
+	}
+}
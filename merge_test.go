@@ -0,0 +1,114 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	parse := func(lines ...string) *Calendar {
+		t.Helper()
+		c, err := Parse(strings.NewReader(strings.Join(append(append([]string{"BEGIN:VCALENDAR", "PRODID:-//team//EN", "VERSION:2.0"}, lines...), "END:VCALENDAR"), "\r\n")), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	paris := func(offset string) []string {
+		return []string{
+			"BEGIN:VTIMEZONE",
+			"TZID:Europe/Paris",
+			"BEGIN:STANDARD",
+			"DTSTART:19701025T030000",
+			"TZOFFSETFROM:+0200",
+			"TZOFFSETTO:" + offset,
+			"END:STANDARD",
+			"END:VTIMEZONE",
+		}
+	}
+	event := func(uid, sequence, stamp, summary string, extra ...string) []string {
+		lines := []string{
+			"BEGIN:VEVENT",
+			"UID:" + uid,
+			"SEQUENCE:" + sequence,
+			"DTSTAMP:" + stamp,
+			"DTSTART;TZID=Europe/Paris:20190110T100000",
+			"SUMMARY:" + summary,
+		}
+		return append(append(lines, extra...), "END:VEVENT")
+	}
+	join := func(parts ...[]string) []string {
+		var lines []string
+		for _, p := range parts {
+			lines = append(lines, p...)
+		}
+		return lines
+	}
+
+	a := parse(join(
+		paris("+0100"),
+		event("standup", "0", "20190101T000000Z", "Standup"),
+		event("review", "1", "20190101T000000Z", "Review"),
+		event("retro", "0", "20190101T000000Z", "Retro", "LAST-MODIFIED:20190105T000000Z"),
+	)...)
+	b := parse(join(
+		paris("+0100"),
+		event("standup", "0", "20190102T000000Z", "Standup (moved)"),
+		event("review", "0", "20190103T000000Z", "Review (old)"),
+		event("retro", "0", "20190102T000000Z", "Retro (old)", "LAST-MODIFIED:20190104T000000Z"),
+		event("standup", "0", "20190101T000000Z", "Standup (instance)", "RECURRENCE-ID;TZID=Europe/Paris:20190111T100000"),
+	)...)
+	c := parse(join(
+		paris("+0000"),
+		event("planning", "0", "20190101T000000Z", "Planning", "BEGIN:X-PLACE", "X-OPENS;TZID=Europe/Paris:20190110T080000", "END:X-PLACE"),
+		[]string{"BEGIN:VJOURNAL", "UID:notes", "DTSTART;TZID=Europe/Paris:20190110T120000", "END:VJOURNAL"},
+	)...)
+
+	m := Merge(MergeOptions{ProdID: "-//department//EN"}, a, b, c)
+
+	var summaries []string
+	for _, v := range m.Events {
+		summaries = append(summaries, v.Summary)
+	}
+	expected := "Standup (moved),Review,Retro,Standup (instance),Planning"
+	if got := strings.Join(summaries, ","); got != expected {
+		t.Errorf("expected events %s, got %s", expected, got)
+	}
+
+	if len(m.Timezones) != 2 || timezoneID(m.Timezones[0]) != "Europe/Paris" || timezoneID(m.Timezones[1]) != "Europe/Paris-2" {
+		t.Fatalf("expected timezones Europe/Paris and Europe/Paris-2, got %d", len(m.Timezones))
+	}
+	if tzid := getProperty("DTSTART", m.Events[4].Properties).Params["TZID"].Values[0]; tzid != "Europe/Paris-2" {
+		t.Errorf("expected the planning to refer to Europe/Paris-2, got %s", tzid)
+	}
+	if tzid := getProperty("DTSTART", c.Events[0].Properties).Params["TZID"].Values[0]; tzid != "Europe/Paris" {
+		t.Errorf("expected the merged calendar unchanged, got %s", tzid)
+	}
+	if getProperty("PRODID", m.Properties).Value != "-//department//EN" || getProperty("VERSION", m.Properties).Value != "2.0" {
+		t.Errorf("unexpected calendar properties %v", m.Properties)
+	}
+
+	var out strings.Builder
+	if err := Encode(&out, m); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := Parse(strings.NewReader(out.String()), nil)
+	if err != nil {
+		t.Fatalf("merged calendar doesn't parse: %v", err)
+	}
+	if len(parsed.Components) != 1 || parsed.Components[0].Name != "VJOURNAL" {
+		t.Fatalf("expected the VJOURNAL to be kept, got %d components", len(parsed.Components))
+	}
+	for _, prop := range []*Property{
+		getProperty("DTSTART", parsed.Events[4].Properties),
+		getProperty("X-OPENS", parsed.Events[4].Components[0].Properties),
+		getProperty("DTSTART", parsed.Components[0].Properties),
+	} {
+		if tzid := prop.Params["TZID"].Values[0]; tzid != "Europe/Paris-2" {
+			t.Errorf("expected %s to refer to Europe/Paris-2, got %s", prop.Name, tzid)
+		}
+	}
+	if tzid := getProperty("DTSTART", c.Components[0].Properties).Params["TZID"].Values[0]; tzid != "Europe/Paris" {
+		t.Errorf("expected the merged calendar unchanged, got %s", tzid)
+	}
+}
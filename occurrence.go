@@ -0,0 +1,102 @@
+package ical
+
+import (
+	"sort"
+	"time"
+)
+
+// An Occurrence represents an instance of an event within a time range
+type Occurrence struct {
+	Event *Event    // The event, or the event overriding this instance
+	Start time.Time // Start of the instance
+	End   time.Time // End of the instance
+}
+
+// EventsBetween returns the occurrences of the events overlapping [start, end),
+// sorted by start time. Recurring events are expanded, and the instances
+// overridden by an event with a RECURRENCE-ID are replaced by the override.
+func (c *Calendar) EventsBetween(start, end time.Time) []Occurrence {
+	overrides := make(map[string][]*Event)
+	for _, v := range c.Events {
+		if !v.RecurrenceID.IsZero() {
+			overrides[v.UID] = append(overrides[v.UID], v)
+		}
+	}
+
+	var occurrences []Occurrence
+	for _, v := range c.Events {
+		if !v.RecurrenceID.IsZero() {
+			if o := v.occurrence(v.StartDate); o.overlaps(start, end) {
+				occurrences = append(occurrences, o)
+			}
+			continue
+		}
+		occurrences = append(occurrences, v.occurrences(overrides[v.UID], start, end)...)
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].Start.Before(occurrences[j].Start)
+	})
+	return occurrences
+}
+
+// occurrences returns the instances of a master event overlapping [start, end),
+// except the ones overridden
+func (v *Event) occurrences(overrides []*Event, start, end time.Time) []Occurrence {
+	length := v.occurrence(v.StartDate).End.Sub(v.StartDate)
+
+	var occurrences []Occurrence
+	for _, t := range recurrences(v.StartDate, v.RRule, v.RDates, v.ExDates, start.Add(-length), end) {
+		if overridden(overrides, t) {
+			continue
+		}
+		if o := v.occurrence(t); o.overlaps(start, end) {
+			occurrences = append(occurrences, o)
+		}
+	}
+	return occurrences
+}
+
+// occurrence returns the instance of the event starting at t. An event
+// with a DTSTART but neither DTEND nor DURATION ends at its start, or a
+// day later when all-day, from rfc5545-3.6.1.
+func (v *Event) occurrence(t time.Time) Occurrence {
+	o := Occurrence{Event: v, Start: t, End: t}
+	open := hasProperty("DTSTART", v.Properties) && !hasProperty("DTEND", v.Properties) && !hasProperty("DURATION", v.Properties)
+	switch {
+	case open && v.AllDay:
+		o.End = t.AddDate(0, 0, 1)
+	case open:
+	case v.AllDay:
+		// keep the number of days rather than the number of hours across DST
+		y, m, d := v.StartDate.Date()
+		ey, em, ed := v.EndDate.In(v.StartDate.Location()).Date()
+		days := int(time.Date(ey, em, ed, 0, 0, 0, 0, time.UTC).Sub(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+		o.End = t.AddDate(0, 0, days)
+	default:
+		o.End = t.Add(v.EndDate.Sub(v.StartDate))
+	}
+	return o
+}
+
+// overlaps checks if the occurrence overlaps [start, end). An occurrence
+// without duration overlaps when it starts within the range.
+func (o Occurrence) overlaps(start, end time.Time) bool {
+	if !o.Start.Before(end) {
+		return false
+	}
+	if o.End.After(o.Start) {
+		return o.End.After(start)
+	}
+	return !o.Start.Before(start)
+}
+
+// overridden checks if an instance is replaced by an override
+func overridden(overrides []*Event, t time.Time) bool {
+	for _, o := range overrides {
+		if o.RecurrenceID.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
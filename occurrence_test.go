@@ -0,0 +1,83 @@
+package ical
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarEventsBetween(t *testing.T) {
+	file, _ := os.Open("fixtures/recurring.ics")
+	c, err := Parse(file, time.UTC)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paris, _ := time.LoadLocation("Europe/Paris")
+	at := func(d, h, m int) time.Time {
+		return time.Date(2019, time.January, d, h, m, 0, 0, paris)
+	}
+	day := func(d int) time.Time {
+		return time.Date(2019, time.January, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	want := []struct {
+		summary    string
+		start, end time.Time
+	}{
+		{"Offsite", day(4), day(8)},
+		{"Standup", at(7, 9, 30), at(7, 9, 45)},
+		{"Day off", day(10), day(11)},
+		{"Standup (moved)", at(14, 16, 0), at(14, 16, 15)},
+		{"Standup", at(16, 9, 30), at(16, 9, 45)},
+	}
+
+	got := c.EventsBetween(day(5), day(17))
+	if len(got) != len(want) {
+		for _, o := range got {
+			t.Logf("%s %v %v", o.Event.Summary, o.Start, o.End)
+		}
+		t.Fatalf("got %d occurrences want %d", len(got), len(want))
+	}
+	for i, o := range got {
+		if o.Event.Summary != want[i].summary || !o.Start.Equal(want[i].start) || !o.End.Equal(want[i].end) {
+			t.Errorf("got %s %v %v want %s %v %v", o.Event.Summary, o.Start, o.End, want[i].summary, want[i].start, want[i].end)
+		}
+	}
+
+	t.Run("all-day event ending at the window start", func(t *testing.T) {
+		for _, o := range c.EventsBetween(day(8), day(9)) {
+			if o.Event.Summary == "Offsite" {
+				t.Errorf("got %s ending at %v", o.Event.Summary, o.End)
+			}
+		}
+	})
+
+	t.Run("event without an end", func(t *testing.T) {
+		c, err := Parse(strings.NewReader("BEGIN:VCALENDAR\r\nPRODID:-//test//EN\r\nVERSION:2.0\r\n"+
+			"BEGIN:VEVENT\r\nUID:reminder\r\nDTSTAMP:20190101T000000Z\r\nDTSTART:20190301T100000Z\r\nEND:VEVENT\r\n"+
+			"END:VCALENDAR\r\n"), time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Date(2019, time.March, 1, 10, 0, 0, 0, time.UTC)
+		if v := c.Events[0]; !v.EndDate.Equal(start.Add(24 * time.Hour)) {
+			t.Errorf("got end date %v", v.EndDate)
+		}
+		if got := c.EventsBetween(start.Add(time.Minute), start.AddDate(0, 0, 1)); len(got) != 0 {
+			t.Errorf("got %v, expected the event to end at its start", got)
+		}
+		if got := c.EventsBetween(start, start.Add(time.Minute)); len(got) != 1 || !got[0].End.Equal(start) {
+			t.Errorf("got %v, expected the event to end at its start", got)
+		}
+	})
+
+	t.Run("event with a duration", func(t *testing.T) {
+		got := c.EventsBetween(time.Date(2019, time.March, 1, 10, 30, 0, 0, time.UTC), time.Date(2019, time.March, 2, 0, 0, 0, 0, time.UTC))
+		if len(got) != 1 || !got[0].End.Equal(time.Date(2019, time.March, 1, 11, 0, 0, 0, time.UTC)) {
+			t.Errorf("got %v, expected the event in progress", got)
+		}
+	})
+}
@@ -16,11 +16,15 @@ type parser struct {
 	scope     int
 	c         *Calendar
 	v         *Event
+	o         *Todo
+	f         *FreeBusy
 	a         *Alarm
 	t         *Timezone
 	s         *Standard
 	d         *Daylight
 	location  *time.Location
+	parent    int          // scope enclosing the current alarm
+	u         []*Component // unknown components being parsed, innermost last
 }
 
 // Parse transforms the raw iCalendar into a Calendar struct
@@ -105,6 +109,8 @@ const (
 	scopeTimezone
 	scopeStandard
 	scopeDaylight
+	scopeTodo
+	scopeFreeBusy
 )
 
 const (
@@ -162,6 +168,61 @@ func (p *parser) scanDelimiter(delim item) error {
 		}
 
 		p.c.Events = append(p.c.Events, p.v)
+		p.c.order = append(p.c.order, p.v)
+		p.leaveScope(scopeCalendar)
+
+		if item := p.next(); item.typ != itemLineEnd {
+			return fmt.Errorf("found %s, expected CRLF", item)
+		}
+	}
+
+	if delim.typ == itemBeginVTodo {
+		if err := p.validateCalendar(p.c); err != nil {
+			return err
+		}
+
+		p.o = NewTodo()
+		p.enterScope(scopeTodo)
+
+		if item := p.next(); item.typ != itemLineEnd {
+			return fmt.Errorf("found %s, expected CRLF", item)
+		}
+	}
+
+	if delim.typ == itemEndVTodo {
+		if err := p.validateTodo(p.o); err != nil {
+			return err
+		}
+
+		p.c.Todos = append(p.c.Todos, p.o)
+		p.c.order = append(p.c.order, p.o)
+		p.leaveScope(scopeCalendar)
+
+		if item := p.next(); item.typ != itemLineEnd {
+			return fmt.Errorf("found %s, expected CRLF", item)
+		}
+	}
+
+	if delim.typ == itemBeginVFreeBusy {
+		if err := p.validateCalendar(p.c); err != nil {
+			return err
+		}
+
+		p.f = NewFreeBusy()
+		p.enterScope(scopeFreeBusy)
+
+		if item := p.next(); item.typ != itemLineEnd {
+			return fmt.Errorf("found %s, expected CRLF", item)
+		}
+	}
+
+	if delim.typ == itemEndVFreeBusy {
+		if err := p.validateFreeBusy(p.f); err != nil {
+			return err
+		}
+
+		p.c.FreeBusys = append(p.c.FreeBusys, p.f)
+		p.c.order = append(p.c.order, p.f)
 		p.leaveScope(scopeCalendar)
 
 		if item := p.next(); item.typ != itemLineEnd {
@@ -192,6 +253,7 @@ func (p *parser) scanDelimiter(delim item) error {
 		}
 
 		p.c.Timezones = append(p.c.Timezones, p.t)
+		p.c.order = append(p.c.order, p.t)
 		p.leaveScope(scopeCalendar)
 
 		if item := p.next(); item.typ != itemLineEnd {
@@ -215,6 +277,7 @@ func (p *parser) scanDelimiter(delim item) error {
 			return err
 		}
 		p.t.Standards = append(p.t.Standards, p.s)
+		p.t.order = append(p.t.order, p.s)
 		p.leaveScope(scopeTimezone)
 		if item := p.next(); item.typ != itemLineEnd {
 			return fmt.Errorf("found %s, expected CRLF", item)
@@ -237,6 +300,7 @@ func (p *parser) scanDelimiter(delim item) error {
 			return err
 		}
 		p.t.Daylights = append(p.t.Daylights, p.d)
+		p.t.order = append(p.t.order, p.d)
 		p.leaveScope(scopeTimezone)
 		if item := p.next(); item.typ != itemLineEnd {
 			return fmt.Errorf("found %s, expected CRLF", item)
@@ -244,7 +308,12 @@ func (p *parser) scanDelimiter(delim item) error {
 	}
 
 	if delim.typ == itemBeginVAlarm {
+		if p.scope != scopeEvent && p.scope != scopeTodo {
+			return fmt.Errorf("found %s, expected within VEVENT or VTODO", delim)
+		}
+
 		p.a = NewAlarm()
+		p.parent = p.scope
 		p.enterScope(scopeAlarm)
 
 		if item := p.next(); item.typ != itemLineEnd {
@@ -257,8 +326,14 @@ func (p *parser) scanDelimiter(delim item) error {
 			return err
 		}
 
-		p.v.Alarms = append(p.v.Alarms, p.a)
-		p.leaveScope(scopeEvent)
+		if p.parent == scopeTodo {
+			p.o.Alarms = append(p.o.Alarms, p.a)
+			p.o.order = append(p.o.order, p.a)
+		} else {
+			p.v.Alarms = append(p.v.Alarms, p.a)
+			p.v.order = append(p.v.order, p.a)
+		}
+		p.leaveScope(p.parent)
 
 		if item := p.next(); item.typ != itemLineEnd {
 			return fmt.Errorf("found %s, expected CRLF", item)
@@ -280,6 +355,9 @@ func (p *parser) scanContentLine() error {
 	name := p.next()
 
 	if name.typ > itemKeyword {
+		if n := len(p.u); n > 0 {
+			return fmt.Errorf("found %s, expected END:%s", name, p.u[n-1].Name)
+		}
 		if err := p.scanDelimiter(name); err != nil {
 			return err
 		}
@@ -309,26 +387,70 @@ func (p *parser) scanContentLine() error {
 
 	prop.Value = value.val
 
-	if item := p.next(); item.typ != itemLineEnd {
+	end := p.next()
+	if end.typ != itemLineEnd {
 		return fmt.Errorf("found %s, expected CRLF", name)
 	}
+	prop.line = p.lex.input[name.pos:end.pos]
+	prop.parsed = formatProperty(prop)
 
-	switch p.scope {
-	case scopeCalendar:
-		p.c.Properties = append(p.c.Properties, prop)
-	case scopeEvent:
-		p.v.Properties = append(p.v.Properties, prop)
-	case scopeAlarm:
-		p.a.Properties = append(p.a.Properties, prop)
-	case scopeTimezone:
-		p.t.Properties = append(p.t.Properties, prop)
-	case scopeDaylight:
-		p.d.Properties = append(p.d.Properties, prop)
-	case scopeStandard:
-		p.s.Properties = append(p.s.Properties, prop)
-	default:
-		return fmt.Errorf("scope %d, expected =", p.scope)
+	// the properties of an unknown component are kept in it
+	n := len(p.u)
+	switch {
+	case prop.Name == "BEGIN":
+		p.u = append(p.u, &Component{Name: prop.Value})
+		return nil
+	case prop.Name == "END" && n > 0:
+		u := p.u[n-1]
+		if prop.Value != u.Name {
+			return fmt.Errorf("found END:%s, expected END:%s", prop.Value, u.Name)
+		}
+		p.u = p.u[:n-1]
+		return p.add(u)
 	}
+	return p.add(prop)
+}
+
+// add adds a property or an unknown component to the component being
+// parsed, keeping the order of both
+func (p *parser) add(item interface{}) error {
+	var properties *[]*Property
+	var components *[]*Component
+	var order *[]interface{}
+
+	if n := len(p.u); n > 0 {
+		u := p.u[n-1]
+		properties, components, order = &u.Properties, &u.Components, &u.order
+	} else {
+		switch p.scope {
+		case scopeCalendar:
+			properties, components, order = &p.c.Properties, &p.c.Components, &p.c.order
+		case scopeEvent:
+			properties, components, order = &p.v.Properties, &p.v.Components, &p.v.order
+		case scopeTodo:
+			properties, components, order = &p.o.Properties, &p.o.Components, &p.o.order
+		case scopeFreeBusy:
+			properties, components, order = &p.f.Properties, &p.f.Components, &p.f.order
+		case scopeAlarm:
+			properties, components, order = &p.a.Properties, &p.a.Components, &p.a.order
+		case scopeTimezone:
+			properties, components, order = &p.t.Properties, &p.t.Components, &p.t.order
+		case scopeDaylight:
+			properties, components, order = &p.d.Properties, &p.d.Components, &p.d.order
+		case scopeStandard:
+			properties, components, order = &p.s.Properties, &p.s.Components, &p.s.order
+		default:
+			return fmt.Errorf("scope %d, expected =", p.scope)
+		}
+	}
+
+	switch item := item.(type) {
+	case *Property:
+		*properties = append(*properties, item)
+	case *Component:
+		*components = append(*components, item)
+	}
+	*order = append(*order, item)
 	return nil
 }
 
@@ -348,29 +470,37 @@ func (p *parser) scanParams(prop *Property) error {
 			return fmt.Errorf("found %s, expected a param-name", paramName)
 		}
 
-		param := NewParam()
-
 		if item := p.next(); item.typ != itemEqual {
 			return fmt.Errorf("found %s, expected =", item)
 		}
 
-		if err := p.scanValues(param); err != nil {
+		entry := ParamEntry{Name: paramName.val}
+		if err := p.scanValues(&entry); err != nil {
 			return err
 		}
+		prop.params = append(prop.params, entry)
 
-		prop.Params[paramName.val] = param
+		// the values of a duplicate param are added to the first one
+		param, ok := prop.Params[paramName.val]
+		if !ok {
+			param = NewParam()
+			prop.Params[paramName.val] = param
+		}
+		param.Values = append(param.Values, entry.Values...)
 	}
 }
 
-// scanValues parses a list of at least one value for a param
-func (p *parser) scanValues(param *Param) error {
+// scanValues parses a list of at least one value for a param, keeping
+// whether each value was quoted
+func (p *parser) scanValues(entry *ParamEntry) error {
 	paramValue := p.next()
 
 	if paramValue.typ != itemParamValue {
 		return fmt.Errorf("found %s, expected a param-value", paramValue)
 	}
 
-	param.Values = append(param.Values, paramValue.val)
+	entry.Values = append(entry.Values, paramValue.val)
+	entry.Quoted = append(entry.Quoted, p.quoted(paramValue))
 
 	for {
 		item := p.next()
@@ -386,10 +516,17 @@ func (p *parser) scanValues(param *Param) error {
 			return fmt.Errorf("found %s, expected a param-value", paramValue)
 		}
 
-		param.Values = append(param.Values, paramValue.val)
+		entry.Values = append(entry.Values, paramValue.val)
+		entry.Quoted = append(entry.Quoted, p.quoted(paramValue))
 	}
 }
 
+// quoted checks if a param-value was quoted, the lexer leaving the quotes
+// out of the value
+func (p *parser) quoted(paramValue item) bool {
+	return paramValue.pos > 0 && p.lex.input[paramValue.pos-1] == '"'
+}
+
 // hasProperty checks if a given component has a certain property
 func hasProperty(name string, properties []*Property) bool {
 	for _, prop := range properties {
@@ -400,6 +537,40 @@ func hasProperty(name string, properties []*Property) bool {
 	return false
 }
 
+// getProperty returns the first property with a given name, or nil
+func getProperty(name string, properties []*Property) *Property {
+	for _, prop := range properties {
+		if name == prop.Name {
+			return prop
+		}
+	}
+	return nil
+}
+
+// setProperty replaces the value of the first property with a given name,
+// or appends a new property, and returns it
+func setProperty(name, value string, properties *[]*Property) *Property {
+	prop := getProperty(name, *properties)
+	if prop == nil {
+		prop = NewProperty()
+		prop.Name = name
+		*properties = append(*properties, prop)
+	}
+	prop.Value = value
+	return prop
+}
+
+// removeProperty removes every property with a given name
+func removeProperty(name string, properties *[]*Property) {
+	kept := (*properties)[:0]
+	for _, prop := range *properties {
+		if name != prop.Name {
+			kept = append(kept, prop)
+		}
+	}
+	*properties = kept
+}
+
 // parseDate transform an ical date property into a time.Time
 func parseDate(prop *Property, l *time.Location) (time.Time, error) {
 	if strings.HasSuffix(prop.Value, "Z") {
@@ -434,3 +605,131 @@ func parseDate(prop *Property, l *time.Location) (time.Time, error) {
 
 	return time.ParseInLocation(layout, prop.Value, l)
 }
+
+// isDate checks if a date property holds a date rather than a date-time
+func isDate(prop *Property) bool {
+	if val, ok := prop.Params["VALUE"]; ok {
+		return val.Values[0] == "DATE"
+	}
+	return len(prop.Value) == len(dateLayout)
+}
+
+// parseDateList transform an ical property holding a list of dates, such as
+// RDATE or EXDATE, into a list of time.Time. Periods are reduced to their start.
+func parseDateList(prop *Property, l *time.Location) ([]time.Time, error) {
+	var dates []time.Time
+	for _, value := range strings.Split(prop.Value, ",") {
+		if i := strings.Index(value, "/"); i >= 0 {
+			value = value[:i]
+		}
+		date, err := parseDate(&Property{Name: prop.Name, Params: prop.Params, Value: value}, l)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
+
+// parsePeriodList transform a FREEBUSY property into a list of periods
+// from rfc5545-3.3.9
+//
+// period = date-time "/" (date-time / dur-value)
+func parsePeriodList(prop *Property, l *time.Location) ([]Period, error) {
+	var periods []Period
+	for _, value := range strings.Split(prop.Value, ",") {
+		parts := strings.SplitN(value, "/", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid period %q", value)
+		}
+
+		start, err := parseDate(&Property{Name: prop.Name, Value: parts[0]}, l)
+		if err != nil {
+			return nil, err
+		}
+
+		var end time.Time
+		if strings.HasPrefix(parts[1], "P") || strings.HasPrefix(parts[1], "+P") {
+			d, err := parseDuration(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			end = start.Add(d)
+		} else if end, err = parseDate(&Property{Name: prop.Name, Value: parts[1]}, l); err != nil {
+			return nil, err
+		}
+
+		periods = append(periods, Period{Start: start, End: end})
+	}
+	return periods, nil
+}
+
+// parseDuration transform an ical duration value into a time.Duration
+// from rfc5545-3.3.6
+//
+// dur-value = (["+"] / "-") "P" (dur-date / dur-time / dur-week)
+// dur-date  = dur-day [dur-time]
+// dur-time  = "T" (dur-hour / dur-minute / dur-second)
+// dur-week  = 1*DIGIT "W"
+//
+// A day is counted as 24 hours.
+func parseDuration(value string) (time.Duration, error) {
+	s := value
+	sign := time.Duration(1)
+
+	if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	} else if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	s = s[1:]
+
+	var d time.Duration
+	inTime := false
+	n := -1
+
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			if n < 0 {
+				n = 0
+			}
+			n = n*10 + int(r-'0')
+			continue
+		case r == 'T' && !inTime && n < 0:
+			inTime = true
+			continue
+		}
+
+		if n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+
+		switch {
+		case r == 'W' && !inTime:
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			d += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		n = -1
+	}
+
+	if n >= 0 || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	return sign * d, nil
+}
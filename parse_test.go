@@ -8,7 +8,7 @@ import (
 	"time"
 )
 
-var calendarList = []string{"fixtures/example.ics", "fixtures/with-alarm.ics", "fixtures/facebookbirthday.ics"}
+var calendarList = []string{"fixtures/example.ics", "fixtures/with-alarm.ics", "fixtures/facebookbirthday.ics", "fixtures/recurring.ics"}
 
 func Test_unfold(t *testing.T) {
 	t.Run("unfold case 1", func(t *testing.T) {
@@ -153,3 +153,35 @@ func Test_parseDate(t *testing.T) {
 		})
 	}
 }
+
+func Test_parseDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"PT15M", 15 * time.Minute, false},
+		{"-PT15H", -15 * time.Hour, false},
+		{"+P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second, false},
+		{"P2W", 14 * 24 * time.Hour, false},
+		{"PT1H30M", 90 * time.Minute, false},
+		{"P", 0, true},
+		{"PT", 0, true},
+		{"P1DT", 0, true},
+		{"15M", 0, true},
+		{"PT1D", 0, true},
+		{"P1H", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseDuration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
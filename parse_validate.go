@@ -2,6 +2,7 @@ package ical
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -37,7 +38,16 @@ func (p *parser) validateCalendar(c *Calendar) error {
 
 // validateEvent validate event props
 func (p *parser) validateEvent(v *Event) error {
+	return decodeEvent(v, p.c.Method, p.location)
+}
+
+// decodeEvent sets the typed fields of an event from its properties, and
+// validates them. Floating times are in l. Without a method, DTSTAMP and
+// DTSTART are required.
+func decodeEvent(v *Event, method string, l *time.Location) error {
+	*v = Event{Properties: v.Properties, Alarms: v.Alarms, Components: v.Components, order: v.order}
 	uniqueCount := make(map[string]int)
+	var duration time.Duration
 
 	for _, prop := range v.Properties {
 		if prop.Name == "UID" {
@@ -45,13 +55,19 @@ func (p *parser) validateEvent(v *Event) error {
 			uniqueCount["UID"]++
 		}
 
+		if prop.Name == "SEQUENCE" {
+			v.Sequence, _ = strconv.Atoi(prop.Value)
+			uniqueCount["SEQUENCE"]++
+		}
+
 		if prop.Name == "DTSTAMP" {
-			v.Timestamp, _ = parseDate(prop, p.location)
+			v.Timestamp, _ = parseDate(prop, l)
 			uniqueCount["DTSTAMP"]++
 		}
 
 		if prop.Name == "DTSTART" {
-			v.StartDate, _ = parseDate(prop, p.location)
+			v.StartDate, _ = parseDate(prop, l)
+			v.AllDay = isDate(prop)
 			uniqueCount["DTSTART"]++
 		}
 
@@ -59,7 +75,7 @@ func (p *parser) validateEvent(v *Event) error {
 			if hasProperty("DURATION", v.Properties) {
 				return fmt.Errorf("Either \"dtend\" or \"duration\" MAY appear")
 			}
-			v.EndDate, _ = parseDate(prop, p.location)
+			v.EndDate, _ = parseDate(prop, l)
 			uniqueCount["DTEND"]++
 		}
 
@@ -67,9 +83,38 @@ func (p *parser) validateEvent(v *Event) error {
 			if hasProperty("DTEND", v.Properties) {
 				return fmt.Errorf("Either \"dtend\" or \"duration\" MAY appear")
 			}
+			d, err := parseDuration(prop.Value)
+			if err != nil {
+				return err
+			}
+			duration = d
 			uniqueCount["DURATION"]++
 		}
 
+		if prop.Name == "RRULE" {
+			rrule, err := ParseRecur(prop.Value)
+			if err != nil {
+				return err
+			}
+			v.RRule = rrule
+			uniqueCount["RRULE"]++
+		}
+
+		if prop.Name == "RDATE" {
+			dates, _ := parseDateList(prop, l)
+			v.RDates = append(v.RDates, dates...)
+		}
+
+		if prop.Name == "EXDATE" {
+			dates, _ := parseDateList(prop, l)
+			v.ExDates = append(v.ExDates, dates...)
+		}
+
+		if prop.Name == "RECURRENCE-ID" {
+			v.RecurrenceID, _ = parseDate(prop, l)
+			uniqueCount["RECURRENCE-ID"]++
+		}
+
 		if prop.Name == "SUMMARY" {
 			v.Summary = prop.Value
 			uniqueCount["SUMMARY"]++
@@ -81,7 +126,7 @@ func (p *parser) validateEvent(v *Event) error {
 		}
 	}
 
-	if p.c.Method == "" && v.Timestamp.IsZero() {
+	if method == "" && v.Timestamp.IsZero() {
 		return fmt.Errorf("missing required property \"dtstamp\"")
 	}
 
@@ -89,7 +134,7 @@ func (p *parser) validateEvent(v *Event) error {
 		return fmt.Errorf("missing required property \"uid\"")
 	}
 
-	if v.StartDate.IsZero() {
+	if method == "" && v.StartDate.IsZero() {
 		return fmt.Errorf("missing required property \"dtstart\"")
 	}
 
@@ -99,15 +144,137 @@ func (p *parser) validateEvent(v *Event) error {
 		}
 	}
 
-	if !hasProperty("DTEND", v.Properties) {
+	if hasProperty("DURATION", v.Properties) {
+		v.EndDate = v.StartDate.Add(duration)
+	} else if !hasProperty("DTEND", v.Properties) {
 		v.EndDate = v.StartDate.Add(time.Hour * 24) // add one day to start date
 	}
 
 	return nil
 }
 
+// validateTodo validate todo props
+func (p *parser) validateTodo(t *Todo) error {
+	return decodeTodo(t, p.c.Method, p.location)
+}
+
+// decodeTodo sets the typed fields of a todo from its properties, and
+// validates them, as decodeEvent does
+func decodeTodo(t *Todo, method string, l *time.Location) error {
+	*t = Todo{Properties: t.Properties, Alarms: t.Alarms, Components: t.Components, order: t.order}
+	uniqueCount := make(map[string]int)
+	var duration time.Duration
+
+	for _, prop := range t.Properties {
+		if prop.Name == "UID" {
+			t.UID = prop.Value
+			uniqueCount["UID"]++
+		}
+
+		if prop.Name == "SEQUENCE" {
+			t.Sequence, _ = strconv.Atoi(prop.Value)
+			uniqueCount["SEQUENCE"]++
+		}
+
+		if prop.Name == "DTSTAMP" {
+			t.Timestamp, _ = parseDate(prop, l)
+			uniqueCount["DTSTAMP"]++
+		}
+
+		if prop.Name == "DTSTART" {
+			t.StartDate, _ = parseDate(prop, l)
+			uniqueCount["DTSTART"]++
+		}
+
+		if prop.Name == "DUE" {
+			if hasProperty("DURATION", t.Properties) {
+				return fmt.Errorf("Either \"due\" or \"duration\" MAY appear")
+			}
+			t.DueDate, _ = parseDate(prop, l)
+			uniqueCount["DUE"]++
+		}
+
+		if prop.Name == "DURATION" {
+			if hasProperty("DUE", t.Properties) {
+				return fmt.Errorf("Either \"due\" or \"duration\" MAY appear")
+			}
+			d, err := parseDuration(prop.Value)
+			if err != nil {
+				return err
+			}
+			duration = d
+			uniqueCount["DURATION"]++
+		}
+
+		if prop.Name == "SUMMARY" {
+			t.Summary = prop.Value
+			uniqueCount["SUMMARY"]++
+		}
+
+		if prop.Name == "DESCRIPTION" {
+			t.Description = prop.Value
+			uniqueCount["DESCRIPTION"]++
+		}
+
+		if prop.Name == "RRULE" {
+			rrule, err := ParseRecur(prop.Value)
+			if err != nil {
+				return err
+			}
+			t.RRule = rrule
+			uniqueCount["RRULE"]++
+		}
+
+		if prop.Name == "RDATE" {
+			dates, _ := parseDateList(prop, l)
+			t.RDates = append(t.RDates, dates...)
+		}
+
+		if prop.Name == "EXDATE" {
+			dates, _ := parseDateList(prop, l)
+			t.ExDates = append(t.ExDates, dates...)
+		}
+
+		if prop.Name == "RECURRENCE-ID" {
+			t.RecurrenceID, _ = parseDate(prop, l)
+			uniqueCount["RECURRENCE-ID"]++
+		}
+	}
+
+	if method == "" && t.Timestamp.IsZero() {
+		return fmt.Errorf("missing required property \"dtstamp\"")
+	}
+
+	if t.UID == "" {
+		return fmt.Errorf("missing required property \"uid\"")
+	}
+
+	for key, value := range uniqueCount {
+		if value > 1 {
+			return fmt.Errorf("\"%s\" property must not occur more than once", key)
+		}
+	}
+
+	if hasProperty("DURATION", t.Properties) {
+		if t.StartDate.IsZero() {
+			return fmt.Errorf("\"duration\" requires \"dtstart\"")
+		}
+		t.DueDate = t.StartDate.Add(duration)
+	}
+
+	return nil
+}
+
 // validateAlarm validate alarm props
 func (p *parser) validateAlarm(a *Alarm) error {
+	return decodeAlarm(a, p.location)
+}
+
+// decodeAlarm sets the typed fields of an alarm from its properties, and
+// validates them
+// from rfc5545-3.6.6
+func decodeAlarm(a *Alarm, l *time.Location) error {
+	*a = Alarm{Properties: a.Properties, Components: a.Components, order: a.order, Attendees: make([]string, 0), Attach: make([]string, 0)}
 	requiredCount := 0
 	uniqueCount := make(map[string]int)
 	for _, prop := range a.Properties {
@@ -118,13 +285,67 @@ func (p *parser) validateAlarm(a *Alarm) error {
 		}
 
 		if prop.Name == "TRIGGER" {
-			a.Trigger = prop.Value
+			if err := parseTrigger(a, prop, l); err != nil {
+				return err
+			}
 			requiredCount++
 			uniqueCount["TRIGGER"]++
 		}
+
+		if prop.Name == "REPEAT" {
+			repeat, err := strconv.Atoi(prop.Value)
+			if err != nil || repeat < 0 {
+				return fmt.Errorf("invalid \"repeat\" value %q", prop.Value)
+			}
+			a.Repeat = repeat
+			uniqueCount["REPEAT"]++
+		}
+
+		if prop.Name == "DURATION" {
+			d, err := parseDuration(prop.Value)
+			if err != nil {
+				return err
+			}
+			a.Duration = d
+			uniqueCount["DURATION"]++
+		}
+
+		if prop.Name == "DESCRIPTION" {
+			a.Description = prop.Value
+			uniqueCount["DESCRIPTION"]++
+		}
+
+		if prop.Name == "SUMMARY" {
+			a.Summary = prop.Value
+			uniqueCount["SUMMARY"]++
+		}
+
+		if prop.Name == "ATTENDEE" {
+			a.Attendees = append(a.Attendees, prop.Value)
+		}
+
+		if prop.Name == "ATTACH" {
+			a.Attach = append(a.Attach, prop.Value)
+		}
+
+		if prop.Name == "UID" {
+			a.UID = prop.Value
+			uniqueCount["UID"]++
+		}
+
+		if prop.Name == "ACKNOWLEDGED" {
+			a.Acknowledged, _ = parseDate(prop, l)
+			uniqueCount["ACKNOWLEDGED"]++
+		}
+
+		if prop.Name == "RELATED-TO" {
+			if rel, ok := prop.Params["RELTYPE"]; ok && rel.Values[0] == "SNOOZE" {
+				a.SnoozeOf = prop.Value
+			}
+		}
 	}
 
-	if requiredCount != 2 {
+	if requiredCount < 2 {
 		return fmt.Errorf("missing either required property \"action / trigger /\"")
 	}
 
@@ -134,6 +355,129 @@ func (p *parser) validateAlarm(a *Alarm) error {
 		}
 	}
 
+	if uniqueCount["DURATION"] != uniqueCount["REPEAT"] {
+		return fmt.Errorf("\"duration\" and \"repeat\" must both occur, or neither")
+	}
+
+	switch a.Action {
+	case "AUDIO":
+		if len(a.Attach) > 1 {
+			return fmt.Errorf("\"attach\" property must not occur more than once in an AUDIO alarm")
+		}
+	case "DISPLAY":
+		if uniqueCount["DESCRIPTION"] == 0 {
+			return fmt.Errorf("missing required property \"description\" in a DISPLAY alarm")
+		}
+	case "EMAIL":
+		if uniqueCount["DESCRIPTION"] == 0 {
+			return fmt.Errorf("missing required property \"description\" in an EMAIL alarm")
+		}
+		if uniqueCount["SUMMARY"] == 0 {
+			return fmt.Errorf("missing required property \"summary\" in an EMAIL alarm")
+		}
+		if len(a.Attendees) == 0 {
+			return fmt.Errorf("missing required property \"attendee\" in an EMAIL alarm")
+		}
+	}
+
+	return nil
+}
+
+// parseTrigger fills the alarm trigger from either a relative duration
+// or an absolute UTC date-time
+func parseTrigger(a *Alarm, prop *Property, l *time.Location) error {
+	a.Trigger = prop.Value
+
+	if val, ok := prop.Params["VALUE"]; ok && val.Values[0] == "DATE-TIME" {
+		if _, ok := prop.Params["RELATED"]; ok {
+			return fmt.Errorf("\"related\" param is only allowed on a relative trigger")
+		}
+		t, err := parseDate(prop, l)
+		if err != nil {
+			return err
+		}
+		a.TriggerDate = t
+		return nil
+	}
+
+	d, err := parseDuration(prop.Value)
+	if err != nil {
+		return err
+	}
+	a.Offset = d
+	a.Related = "START"
+
+	if rel, ok := prop.Params["RELATED"]; ok {
+		switch rel.Values[0] {
+		case "START", "END":
+			a.Related = rel.Values[0]
+		default:
+			return fmt.Errorf("invalid \"related\" param %q", rel.Values[0])
+		}
+	}
+
+	return nil
+}
+
+// validateFreeBusy validate free/busy props
+func (p *parser) validateFreeBusy(f *FreeBusy) error {
+	uniqueCount := make(map[string]int)
+
+	for _, prop := range f.Properties {
+		if prop.Name == "UID" {
+			f.UID = prop.Value
+			uniqueCount["UID"]++
+		}
+
+		if prop.Name == "DTSTAMP" {
+			f.Timestamp, _ = parseDate(prop, p.location)
+			uniqueCount["DTSTAMP"]++
+		}
+
+		if prop.Name == "DTSTART" {
+			f.StartDate, _ = parseDate(prop, p.location)
+			uniqueCount["DTSTART"]++
+		}
+
+		if prop.Name == "DTEND" {
+			f.EndDate, _ = parseDate(prop, p.location)
+			uniqueCount["DTEND"]++
+		}
+
+		if prop.Name == "FREEBUSY" {
+			periods, err := parsePeriodList(prop, p.location)
+			if err != nil {
+				return err
+			}
+
+			fbtype := "BUSY"
+			if val, ok := prop.Params["FBTYPE"]; ok {
+				fbtype = val.Values[0]
+			}
+
+			switch fbtype {
+			case "BUSY", "BUSY-UNAVAILABLE":
+				f.Busy = append(f.Busy, periods...)
+			case "BUSY-TENTATIVE":
+				f.BusyTentative = append(f.BusyTentative, periods...)
+			}
+		}
+	}
+
+	if p.c.Method == "" && f.Timestamp.IsZero() {
+		return fmt.Errorf("missing required property \"dtstamp\"")
+	}
+
+	if f.UID == "" {
+		return fmt.Errorf("missing required property \"uid\"")
+	}
+
+	for key, value := range uniqueCount {
+		if value > 1 {
+			return fmt.Errorf("\"%s\" property must not occur more than once", key)
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,104 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// alarmCalendar wraps VALARM content lines into a minimal calendar
+func alarmCalendar(lines ...string) string {
+	return strings.Join(append([]string{
+		"BEGIN:VCALENDAR",
+		"PRODID:-//test//EN",
+		"VERSION:2.0",
+		"BEGIN:VEVENT",
+		"UID:1@test",
+		"DTSTAMP:20190101T000000Z",
+		"DTSTART:20190102T100000Z",
+		"BEGIN:VALARM",
+	}, append(lines,
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	)...), "\r\n")
+}
+
+func Test_validateAlarm(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		wantErr bool
+	}{
+		{"audio", []string{"ACTION:AUDIO", "TRIGGER:-PT15M", "ATTACH;VALUE=URI:Basso"}, false},
+		{"audio with two attach", []string{"ACTION:AUDIO", "TRIGGER:-PT15M", "ATTACH:a", "ATTACH:b"}, true},
+		{"display", []string{"ACTION:DISPLAY", "TRIGGER:-PT15M", "DESCRIPTION:Wake up"}, false},
+		{"display without description", []string{"ACTION:DISPLAY", "TRIGGER:-PT15M"}, true},
+		{"email", []string{"ACTION:EMAIL", "TRIGGER:-PT15M", "DESCRIPTION:Body", "SUMMARY:Subject", "ATTENDEE:mailto:a@example.com"}, false},
+		{"email without summary", []string{"ACTION:EMAIL", "TRIGGER:-PT15M", "DESCRIPTION:Body", "ATTENDEE:mailto:a@example.com"}, true},
+		{"email without attendee", []string{"ACTION:EMAIL", "TRIGGER:-PT15M", "DESCRIPTION:Body", "SUMMARY:Subject"}, true},
+		{"repeat without duration", []string{"ACTION:AUDIO", "TRIGGER:-PT15M", "REPEAT:2"}, true},
+		{"duration without repeat", []string{"ACTION:AUDIO", "TRIGGER:-PT15M", "DURATION:PT5M"}, true},
+		{"repeat and duration", []string{"ACTION:AUDIO", "TRIGGER:-PT15M", "REPEAT:2", "DURATION:PT5M"}, false},
+		{"missing trigger", []string{"ACTION:AUDIO"}, true},
+		{"invalid trigger", []string{"ACTION:AUDIO", "TRIGGER:15M"}, true},
+		{"invalid related", []string{"ACTION:AUDIO", "TRIGGER;RELATED=MIDDLE:-PT15M"}, true},
+		{"absolute trigger with related", []string{"ACTION:AUDIO", "TRIGGER;VALUE=DATE-TIME;RELATED=END:20190102T090000Z"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(alarmCalendar(tt.lines...)), nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateAlarmFields(t *testing.T) {
+	t.Run("relative trigger", func(t *testing.T) {
+		c, err := Parse(strings.NewReader(alarmCalendar(
+			"ACTION:AUDIO",
+			"TRIGGER;RELATED=END:-PT15M",
+			"REPEAT:4",
+			"DURATION:PT5M",
+			"ATTACH;FMTTYPE=audio/basic:ftp://example.com/sound",
+		)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := c.Events[0].Alarms[0]
+		if a.Related != "END" || a.Offset != -15*time.Minute {
+			t.Errorf("got related %s offset %v want END -15m", a.Related, a.Offset)
+		}
+		if a.Repeat != 4 || a.Duration != 5*time.Minute {
+			t.Errorf("got repeat %d duration %v want 4 5m", a.Repeat, a.Duration)
+		}
+		if len(a.Attach) != 1 || a.Attach[0] != "ftp://example.com/sound" {
+			t.Errorf("got attach %v", a.Attach)
+		}
+	})
+
+	t.Run("absolute trigger", func(t *testing.T) {
+		c, err := Parse(strings.NewReader(alarmCalendar(
+			"ACTION:EMAIL",
+			"TRIGGER;VALUE=DATE-TIME:20190102T090000Z",
+			"SUMMARY:Subject",
+			"DESCRIPTION:Body",
+			"ATTENDEE:mailto:a@example.com",
+			"ATTENDEE:mailto:b@example.com",
+		)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := c.Events[0].Alarms[0]
+		want := time.Date(2019, time.January, 2, 9, 0, 0, 0, time.UTC)
+		if !a.TriggerDate.Equal(want) {
+			t.Errorf("got trigger date %v want %v", a.TriggerDate, want)
+		}
+		if a.Summary != "Subject" || a.Description != "Body" || len(a.Attendees) != 2 {
+			t.Errorf("got summary %q description %q attendees %v", a.Summary, a.Description, a.Attendees)
+		}
+	})
+}
@@ -0,0 +1,646 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Recur represents a RRULE recurrence rule
+// from rfc5545-3.3.10
+type Recur struct {
+	Freq       string       // SECONDLY, MINUTELY, HOURLY, DAILY, WEEKLY, MONTHLY or YEARLY
+	Interval   int          // Interval between periods, defaults to 1
+	Count      int          // Number of occurrences, 0 if unbounded
+	Until      time.Time    // Last possible occurrence, zero if unbounded
+	BySecond   []int        // BYSECOND
+	ByMinute   []int        // BYMINUTE
+	ByHour     []int        // BYHOUR
+	ByDay      []WeekdayNum // BYDAY
+	ByMonthDay []int        // BYMONTHDAY
+	ByYearDay  []int        // BYYEARDAY
+	ByWeekNo   []int        // BYWEEKNO
+	ByMonth    []int        // BYMONTH
+	BySetPos   []int        // BYSETPOS
+	WeekStart  time.Weekday // WKST, defaults to Monday
+
+	untilFloating bool // UNTIL is a date or a local date-time, to resolve in the DTSTART location
+	untilDate     bool // UNTIL is a date, the whole day is included
+}
+
+// A WeekdayNum represents an entry of BYDAY, e.g. "-1SU" for the last Sunday
+type WeekdayNum struct {
+	N       int // Ordinal of the weekday within the month or the year, 0 for every weekday
+	Weekday time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+var frequencies = map[string]int{
+	"SECONDLY": 0,
+	"MINUTELY": 1,
+	"HOURLY":   2,
+	"DAILY":    3,
+	"WEEKLY":   4,
+	"MONTHLY":  5,
+	"YEARLY":   6,
+}
+
+// ParseRecur parses a RRULE value such as "FREQ=WEEKLY;COUNT=10;BYDAY=MO,WE"
+func ParseRecur(value string) (*Recur, error) {
+	r := &Recur{Interval: 1, WeekStart: time.Monday}
+
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid recur rule part %q", part)
+		}
+		name, val := kv[0], kv[1]
+
+		var err error
+		switch name {
+		case "FREQ":
+			if _, ok := frequencies[val]; !ok {
+				return nil, fmt.Errorf("invalid recur frequency %q", val)
+			}
+			r.Freq = val
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(val)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("invalid recur interval %q", val)
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(val)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("invalid recur count %q", val)
+			}
+		case "UNTIL":
+			err = r.parseUntil(val)
+		case "BYSECOND":
+			r.BySecond, err = parseIntList(val, 0, 60, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseIntList(val, 0, 59, false)
+		case "BYHOUR":
+			r.ByHour, err = parseIntList(val, 0, 23, false)
+		case "BYDAY":
+			r.ByDay, err = parseWeekdayList(val)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(val, 1, 31, true)
+		case "BYYEARDAY":
+			r.ByYearDay, err = parseIntList(val, 1, 366, true)
+		case "BYWEEKNO":
+			r.ByWeekNo, err = parseIntList(val, 1, 53, true)
+		case "BYMONTH":
+			r.ByMonth, err = parseIntList(val, 1, 12, false)
+		case "BYSETPOS":
+			r.BySetPos, err = parseIntList(val, 1, 366, true)
+		case "WKST":
+			wd, ok := weekdays[val]
+			if !ok {
+				err = fmt.Errorf("invalid recur week start %q", val)
+			}
+			r.WeekStart = wd
+		default:
+			// x-name and unknown rule parts are ignored
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("missing recur frequency in %q", value)
+	}
+
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("recur \"until\" and \"count\" must not occur in the same rule")
+	}
+
+	return r, nil
+}
+
+// String formats the rule as a RRULE value, parts in the order of
+// rfc5545-3.3.10 and defaults left out
+func (r *Recur) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if !r.Until.IsZero() {
+		switch {
+		case r.untilDate:
+			parts = append(parts, "UNTIL="+r.Until.Format(dateLayout))
+		case r.untilFloating:
+			parts = append(parts, "UNTIL="+r.Until.Format(dateTimeLayoutLocalized))
+		default:
+			parts = append(parts, "UNTIL="+formatDateTime(r.Until))
+		}
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	ints := func(name string, list []int) {
+		if len(list) == 0 {
+			return
+		}
+		values := make([]string, len(list))
+		for i, n := range list {
+			values[i] = strconv.Itoa(n)
+		}
+		parts = append(parts, name+"="+strings.Join(values, ","))
+	}
+	ints("BYSECOND", r.BySecond)
+	ints("BYMINUTE", r.ByMinute)
+	ints("BYHOUR", r.ByHour)
+	if len(r.ByDay) > 0 {
+		values := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			values[i] = weekdayName(day.Weekday)
+			if day.N != 0 {
+				values[i] = strconv.Itoa(day.N) + values[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(values, ","))
+	}
+	ints("BYMONTHDAY", r.ByMonthDay)
+	ints("BYYEARDAY", r.ByYearDay)
+	ints("BYWEEKNO", r.ByWeekNo)
+	ints("BYMONTH", r.ByMonth)
+	ints("BYSETPOS", r.BySetPos)
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayName(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+// weekdayName returns the two letters name of a weekday, e.g. "MO"
+func weekdayName(wd time.Weekday) string {
+	for name, d := range weekdays {
+		if d == wd {
+			return name
+		}
+	}
+	return ""
+}
+
+// parseUntil parses an UNTIL value, either a date, a local date-time or an UTC date-time
+func (r *Recur) parseUntil(val string) error {
+	var err error
+	switch {
+	case strings.HasSuffix(val, "Z"):
+		r.Until, err = time.Parse(dateTimeLayoutUTC, val)
+	case len(val) == len(dateLayout):
+		r.Until, err = time.Parse(dateLayout, val)
+		r.untilFloating = true
+		r.untilDate = true
+	default:
+		r.Until, err = time.Parse(dateTimeLayoutLocalized, val)
+		r.untilFloating = true
+	}
+	return err
+}
+
+// parseIntList parses a comma separated list of integers within [min, max],
+// or within [-max, -min] as well when negative is set
+func parseIntList(val string, min, max int, negative bool) ([]int, error) {
+	var list []int
+	for _, s := range strings.Split(val, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recur value %q", val)
+		}
+		if (n < min || n > max) && (!negative || n > -min || n < -max) {
+			return nil, fmt.Errorf("recur value %d out of range", n)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
+
+// parseWeekdayList parses a BYDAY list
+func parseWeekdayList(val string) ([]WeekdayNum, error) {
+	var list []WeekdayNum
+	for _, s := range strings.Split(val, ",") {
+		if len(s) < 2 {
+			return nil, fmt.Errorf("invalid recur weekday %q", s)
+		}
+		wd, ok := weekdays[s[len(s)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid recur weekday %q", s)
+		}
+		n := 0
+		if len(s) > 2 {
+			var err error
+			n, err = strconv.Atoi(s[:len(s)-2])
+			if err != nil || n == 0 || n > 53 || n < -53 {
+				return nil, fmt.Errorf("invalid recur weekday %q", s)
+			}
+		}
+		list = append(list, WeekdayNum{N: n, Weekday: wd})
+	}
+	return list, nil
+}
+
+// Between returns the occurrences of the rule within [from, to), for a
+// recurrence starting at start. The start itself is only returned when it
+// matches the rule, but always counts as the first occurrence of a COUNT.
+func (r *Recur) Between(start, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	r.iterate(start, from, to, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			occurrences = append(occurrences, t)
+		}
+		return true
+	})
+	return occurrences
+}
+
+// iterate calls fn on each occurrence of the rule in order, until fn returns
+// false, the rule is exhausted or a period begins after limit. Without a
+// COUNT, the periods ending before from are skipped.
+func (r *Recur) iterate(start, from, limit time.Time, fn func(time.Time) bool) {
+	rule := r.withDefaults(start)
+	loc := start.Location()
+	until := r.until(loc)
+	count := 0
+
+	k := 0
+	if rule.Count == 0 {
+		k = rule.skip(start, from)
+	}
+
+	for ; ; k += rule.Interval {
+		first, set := rule.period(start, k)
+		if first.After(limit) || (!until.IsZero() && first.After(until)) {
+			return
+		}
+
+		for _, t := range set {
+			if t.Before(start) {
+				continue
+			}
+			if !until.IsZero() && t.After(until) {
+				return
+			}
+			// from rfc5545-3.3.10, the start counts as the first
+			// occurrence even when it doesn't match the rule
+			if count == 0 && !t.Equal(start) {
+				count++
+			}
+			if rule.Count > 0 && count >= rule.Count {
+				return
+			}
+			if !fn(t) {
+				return
+			}
+			count++
+		}
+	}
+}
+
+// skip returns the index of a period beginning before from, a multiple of
+// the interval, counted in the wall clock time of the start less a margin
+// for daylight saving time changes
+func (r *Recur) skip(start, from time.Time) int {
+	if !from.After(start) {
+		return 0
+	}
+
+	wall := func(t time.Time) time.Time {
+		y, m, d := t.Date()
+		hh, mm, ss := t.Clock()
+		return time.Date(y, m, d, hh, mm, ss, 0, time.UTC)
+	}
+	s, f := wall(start), wall(from.In(start.Location()))
+
+	var n int
+	switch r.Freq {
+	case "YEARLY":
+		n = f.Year() - s.Year()
+	case "MONTHLY":
+		n = (f.Year()-s.Year())*12 + int(f.Month()) - int(s.Month())
+	case "WEEKLY":
+		n = int(f.Sub(s).Hours()) / (24 * 7)
+	case "DAILY":
+		n = int(f.Sub(s).Hours()) / 24
+	case "HOURLY":
+		n = int(f.Sub(s)/time.Hour) - 2
+	case "MINUTELY":
+		n = int(f.Sub(s)/time.Minute) - 2*60
+	case "SECONDLY":
+		n = int(f.Sub(s)/time.Second) - 2*60*60
+	}
+
+	n = n/r.Interval*r.Interval - r.Interval
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// until resolves UNTIL in the location of the recurrence
+func (r *Recur) until(loc *time.Location) time.Time {
+	if r.Until.IsZero() || !r.untilFloating {
+		return r.Until
+	}
+	y, m, d := r.Until.Date()
+	if r.untilDate {
+		return time.Date(y, m, d+1, 0, 0, 0, -1, loc)
+	}
+	hh, mm, ss := r.Until.Clock()
+	return time.Date(y, m, d, hh, mm, ss, 0, loc)
+}
+
+// withDefaults fills the rule parts implied by the start of the recurrence
+func (r *Recur) withDefaults(start time.Time) *Recur {
+	rule := *r
+	if rule.Interval < 1 {
+		rule.Interval = 1
+	}
+	freq := frequencies[rule.Freq]
+
+	if len(rule.ByWeekNo) == 0 && len(rule.ByYearDay) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 {
+		switch rule.Freq {
+		case "YEARLY":
+			if len(rule.ByMonth) == 0 {
+				rule.ByMonth = []int{int(start.Month())}
+			}
+			rule.ByMonthDay = []int{start.Day()}
+		case "MONTHLY":
+			rule.ByMonthDay = []int{start.Day()}
+		case "WEEKLY":
+			rule.ByDay = []WeekdayNum{{Weekday: start.Weekday()}}
+		}
+	}
+
+	if len(rule.ByHour) == 0 && freq > frequencies["HOURLY"] {
+		rule.ByHour = []int{start.Hour()}
+	}
+	if len(rule.ByMinute) == 0 && freq > frequencies["MINUTELY"] {
+		rule.ByMinute = []int{start.Minute()}
+	}
+	if len(rule.BySecond) == 0 && freq > frequencies["SECONDLY"] {
+		rule.BySecond = []int{start.Second()}
+	}
+
+	return &rule
+}
+
+// period returns the beginning of the k-th period of the rule and the sorted
+// occurrences within it, BYSETPOS applied
+func (r *Recur) period(start time.Time, k int) (time.Time, []time.Time) {
+	loc := start.Location()
+	y, m, d := start.Date()
+	hh, mm, ss := start.Clock()
+
+	var days []time.Time // UTC midnights
+	hours, minutes, seconds := r.ByHour, r.ByMinute, r.BySecond
+	var first time.Time
+
+	switch r.Freq {
+	case "YEARLY":
+		first = time.Date(y+k, time.January, 1, 0, 0, 0, 0, loc)
+		days = dayRange(time.Date(y+k, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(y+k+1, time.January, 1, 0, 0, 0, 0, time.UTC))
+	case "MONTHLY":
+		first = time.Date(y, m+time.Month(k), 1, 0, 0, 0, 0, loc)
+		days = dayRange(time.Date(y, m+time.Month(k), 1, 0, 0, 0, 0, time.UTC), time.Date(y, m+time.Month(k)+1, 1, 0, 0, 0, 0, time.UTC))
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		first = time.Date(y, m, d-offset+7*k, 0, 0, 0, 0, loc)
+		days = dayRange(time.Date(y, m, d-offset+7*k, 0, 0, 0, 0, time.UTC), time.Date(y, m, d-offset+7*k+7, 0, 0, 0, 0, time.UTC))
+	case "DAILY":
+		first = time.Date(y, m, d+k, 0, 0, 0, 0, loc)
+		days = []time.Time{time.Date(y, m, d+k, 0, 0, 0, 0, time.UTC)}
+	case "HOURLY":
+		first = time.Date(y, m, d, hh+k, 0, 0, 0, loc)
+		t := time.Date(y, m, d, hh+k, 0, 0, 0, time.UTC)
+		days = []time.Time{t.Truncate(24 * time.Hour)}
+		hours = filterInts([]int{t.Hour()}, r.ByHour)
+	case "MINUTELY":
+		first = time.Date(y, m, d, hh, mm+k, 0, 0, loc)
+		t := time.Date(y, m, d, hh, mm+k, 0, 0, time.UTC)
+		days = []time.Time{t.Truncate(24 * time.Hour)}
+		hours = filterInts([]int{t.Hour()}, r.ByHour)
+		minutes = filterInts([]int{t.Minute()}, r.ByMinute)
+	case "SECONDLY":
+		first = time.Date(y, m, d, hh, mm, ss+k, 0, loc)
+		t := time.Date(y, m, d, hh, mm, ss+k, 0, time.UTC)
+		days = []time.Time{t.Truncate(24 * time.Hour)}
+		hours = filterInts([]int{t.Hour()}, r.ByHour)
+		minutes = filterInts([]int{t.Minute()}, r.ByMinute)
+		seconds = filterInts([]int{t.Second()}, r.BySecond)
+	}
+
+	var set []time.Time
+	for _, day := range days {
+		if !r.matchDay(day) {
+			continue
+		}
+		dy, dm, dd := day.Date()
+		for _, h := range hours {
+			for _, mi := range minutes {
+				for _, s := range seconds {
+					set = append(set, time.Date(dy, dm, dd, h, mi, s, 0, loc))
+				}
+			}
+		}
+	}
+
+	sort.Slice(set, func(i, j int) bool { return set[i].Before(set[j]) })
+
+	if len(r.BySetPos) > 0 {
+		var selected []time.Time
+		for i, t := range set {
+			for _, pos := range r.BySetPos {
+				if pos == i+1 || pos == i-len(set) {
+					selected = append(selected, t)
+					break
+				}
+			}
+		}
+		set = selected
+	}
+
+	return first, set
+}
+
+// matchDay checks a day against the BYMONTH, BYWEEKNO, BYYEARDAY, BYMONTHDAY and BYDAY rule parts
+func (r *Recur) matchDay(day time.Time) bool {
+	y, m, d := day.Date()
+	yday := day.YearDay()
+	yearDays := daysIn(y, 0)
+	monthDays := daysIn(y, m)
+
+	if len(r.ByMonth) > 0 && !containsInt(r.ByMonth, int(m)) {
+		return false
+	}
+
+	if len(r.ByWeekNo) > 0 {
+		week, weeks := weekNumber(day, r.WeekStart)
+		if !containsInt(r.ByWeekNo, week) && !containsInt(r.ByWeekNo, week-weeks-1) {
+			return false
+		}
+	}
+
+	if len(r.ByYearDay) > 0 && !containsInt(r.ByYearDay, yday) && !containsInt(r.ByYearDay, yday-yearDays-1) {
+		return false
+	}
+
+	if len(r.ByMonthDay) > 0 && !containsInt(r.ByMonthDay, d) && !containsInt(r.ByMonthDay, d-monthDays-1) {
+		return false
+	}
+
+	if len(r.ByDay) > 0 {
+		// ordinals are relative to the month in a MONTHLY rule or a YEARLY rule
+		// restricted with BYMONTH, relative to the year in other YEARLY rules
+		n, last := 0, 0
+		switch {
+		case r.Freq == "MONTHLY" || (r.Freq == "YEARLY" && len(r.ByMonth) > 0):
+			n, last = (d-1)/7+1, -((monthDays-d)/7 + 1)
+		case r.Freq == "YEARLY" && len(r.ByWeekNo) == 0:
+			n, last = (yday-1)/7+1, -((yearDays-yday)/7 + 1)
+		}
+
+		match := false
+		for _, wd := range r.ByDay {
+			if wd.Weekday != day.Weekday() {
+				continue
+			}
+			if wd.N == 0 || n == 0 || wd.N == n || wd.N == last {
+				match = true
+				break
+			}
+		}
+		if !match {
+			return false
+		}
+	}
+
+	return true
+}
+
+// weekNumber returns the week number of a day and the number of weeks of its
+// week-numbering year, week 1 being the first week with at least four days
+func weekNumber(day time.Time, weekStart time.Weekday) (int, int) {
+	y := day.Year()
+	start := firstWeek(y, weekStart)
+
+	if day.Before(start) {
+		y--
+		start = firstWeek(y, weekStart)
+	} else if next := firstWeek(y+1, weekStart); !day.Before(next) {
+		y++
+		start = next
+	}
+
+	weeks := int(firstWeek(y+1, weekStart).Sub(start).Hours()) / (24 * 7)
+	return int(day.Sub(start).Hours())/(24*7) + 1, weeks
+}
+
+// firstWeek returns the first day of the week 1 of a year
+func firstWeek(y int, weekStart time.Weekday) time.Time {
+	jan1 := time.Date(y, time.January, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(jan1.Weekday()) - int(weekStart) + 7) % 7
+	if offset <= 3 {
+		return jan1.AddDate(0, 0, -offset)
+	}
+	return jan1.AddDate(0, 0, 7-offset)
+}
+
+// daysIn returns the number of days in a month, or in the year if m is 0
+func daysIn(y int, m time.Month) int {
+	if m == 0 {
+		return time.Date(y, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	}
+	return time.Date(y, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// dayRange returns every UTC midnight within [from, to)
+func dayRange(from, to time.Time) []time.Time {
+	var days []time.Time
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// filterInts keeps the values found in filter, or all of them if filter is empty
+func filterInts(values, filter []int) []int {
+	if len(filter) == 0 {
+		return values
+	}
+	var kept []int
+	for _, v := range values {
+		if containsInt(filter, v) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// recurrences returns the start of every instance of a component within
+// [from, to), from its DTSTART, RRULE, RDATE and EXDATE properties
+func recurrences(start time.Time, rrule *Recur, rdates, exdates []time.Time, from, to time.Time) []time.Time {
+	var starts []time.Time
+
+	if !start.Before(from) && start.Before(to) {
+		starts = append(starts, start)
+	}
+
+	if rrule != nil {
+		starts = append(starts, rrule.Between(start, from, to)...)
+	}
+
+	for _, t := range rdates {
+		if !t.Before(from) && t.Before(to) {
+			starts = append(starts, t)
+		}
+	}
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	var instances []time.Time
+	for i, t := range starts {
+		if i > 0 && t.Equal(starts[i-1]) {
+			continue
+		}
+		if containsTime(exdates, t) {
+			continue
+		}
+		instances = append(instances, t)
+	}
+	return instances
+}
+
+func containsTime(list []time.Time, t time.Time) bool {
+	for _, v := range list {
+		if v.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
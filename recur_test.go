@@ -0,0 +1,223 @@
+package ical
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurBetween(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	start := time.Date(1997, time.September, 2, 9, 0, 0, 0, ny)
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		to    time.Time
+		want  []string
+	}{
+		{
+			name:  "daily for 3 occurrences",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: start,
+			want:  []string{"19970902T090000", "19970903T090000", "19970904T090000"},
+		},
+		{
+			name:  "every other day until",
+			rule:  "FREQ=DAILY;INTERVAL=2;UNTIL=19970908T130000Z",
+			start: start,
+			want:  []string{"19970902T090000", "19970904T090000", "19970906T090000", "19970908T090000"},
+		},
+		{
+			name:  "weekly on tuesday and thursday",
+			rule:  "FREQ=WEEKLY;COUNT=4;WKST=SU;BYDAY=TU,TH",
+			start: start,
+			want:  []string{"19970902T090000", "19970904T090000", "19970909T090000", "19970911T090000"},
+		},
+		{
+			name:  "monthly on the first friday",
+			rule:  "FREQ=MONTHLY;COUNT=3;BYDAY=1FR",
+			start: time.Date(1997, time.September, 5, 9, 0, 0, 0, ny),
+			want:  []string{"19970905T090000", "19971003T090000", "19971107T090000"},
+		},
+		{
+			name:  "monthly on the second to last monday",
+			rule:  "FREQ=MONTHLY;COUNT=2;BYDAY=-2MO",
+			start: time.Date(1997, time.September, 22, 9, 0, 0, 0, ny),
+			want:  []string{"19970922T090000", "19971020T090000"},
+		},
+		{
+			name:  "monthly on the last day skipping short months",
+			rule:  "FREQ=MONTHLY;COUNT=3;BYMONTHDAY=-1",
+			start: time.Date(1997, time.September, 30, 9, 0, 0, 0, ny),
+			want:  []string{"19970930T090000", "19971031T090000", "19971130T090000"},
+		},
+		{
+			name:  "monthly on the 31st",
+			rule:  "FREQ=MONTHLY;COUNT=3",
+			start: time.Date(1997, time.October, 31, 9, 0, 0, 0, ny),
+			want:  []string{"19971031T090000", "19971231T090000", "19980131T090000"},
+		},
+		{
+			name:  "yearly in june and july",
+			rule:  "FREQ=YEARLY;COUNT=4;BYMONTH=6,7",
+			start: time.Date(1997, time.June, 10, 9, 0, 0, 0, ny),
+			want:  []string{"19970610T090000", "19970710T090000", "19980610T090000", "19980710T090000"},
+		},
+		{
+			name:  "yearly on the 20th monday",
+			rule:  "FREQ=YEARLY;COUNT=2;BYDAY=20MO",
+			start: time.Date(1997, time.May, 19, 9, 0, 0, 0, ny),
+			want:  []string{"19970519T090000", "19980518T090000"},
+		},
+		{
+			name:  "yearly on monday of week 20",
+			rule:  "FREQ=YEARLY;COUNT=2;BYWEEKNO=20;BYDAY=MO",
+			start: time.Date(1997, time.May, 12, 9, 0, 0, 0, ny),
+			want:  []string{"19970512T090000", "19980511T090000"},
+		},
+		{
+			name:  "last work day of the month",
+			rule:  "FREQ=MONTHLY;COUNT=3;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			start: time.Date(1997, time.September, 30, 9, 0, 0, 0, ny),
+			want:  []string{"19970930T090000", "19971031T090000", "19971128T090000"},
+		},
+		{
+			name:  "every 3 hours",
+			rule:  "FREQ=HOURLY;INTERVAL=3;UNTIL=19970902T170000Z",
+			start: start,
+			want:  []string{"19970902T090000", "19970902T120000"},
+		},
+		{
+			name:  "every 20 minutes between 9 and 10",
+			rule:  "FREQ=DAILY;BYHOUR=9;BYMINUTE=0,20,40",
+			start: start,
+			to:    time.Date(1997, time.September, 3, 12, 0, 0, 0, ny),
+			want:  []string{"19970902T090000", "19970902T092000", "19970902T094000", "19970903T090000", "19970903T092000", "19970903T094000"},
+		},
+		{
+			name:  "daily across a DST change",
+			rule:  "FREQ=DAILY;COUNT=2",
+			start: time.Date(1997, time.October, 25, 9, 0, 0, 0, ny),
+			want:  []string{"19971025T090000", "19971026T090000"},
+		},
+		{
+			name:  "yearly until a date",
+			rule:  "FREQ=YEARLY;UNTIL=19990610",
+			start: time.Date(1997, time.June, 10, 9, 0, 0, 0, ny),
+			want:  []string{"19970610T090000", "19980610T090000", "19990610T090000"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRecur(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			to := tt.to
+			if to.IsZero() {
+				to = tt.start.AddDate(3, 0, 0)
+			}
+			got := r.Between(tt.start, tt.start, to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v want %v", got, tt.want)
+			}
+			for i, occurrence := range got {
+				if occurrence.Location() != ny || occurrence.Format(dateTimeLayoutLocalized) != tt.want[i] {
+					t.Errorf("got %v want %s", occurrence, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecurrencesCountStart(t *testing.T) {
+	// the start is a wednesday, not matching the rule, and counts as the first occurrence
+	start := time.Date(1997, time.September, 3, 9, 0, 0, 0, time.UTC)
+	r, err := ParseRecur("FREQ=WEEKLY;COUNT=3;BYDAY=TU")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := recurrences(start, r, nil, nil, start, start.AddDate(1, 0, 0))
+	want := []time.Time{start, time.Date(1997, time.September, 9, 9, 0, 0, 0, time.UTC), time.Date(1997, time.September, 16, 9, 0, 0, 0, time.UTC)}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("got %v want %v", got[i], want[i])
+		}
+	}
+}
+
+func TestRecurBetweenSkipsPeriods(t *testing.T) {
+	ny, _ := time.LoadLocation("America/New_York")
+	from := time.Date(2020, time.March, 8, 1, 0, 0, 0, ny) // an hour before a DST change
+	to := from.AddDate(0, 0, 40)
+
+	rules := []string{
+		"FREQ=SECONDLY;INTERVAL=97",
+		"FREQ=MINUTELY;INTERVAL=13",
+		"FREQ=HOURLY;INTERVAL=5",
+		"FREQ=DAILY;INTERVAL=3",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,SU",
+		"FREQ=MONTHLY;BYDAY=-1SU",
+	}
+	for _, rule := range rules {
+		r, err := ParseRecur(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// occurrences found from a recent start, without skipping, are
+		// the same once periods are skipped
+		start := time.Date(2020, time.February, 4, 9, 0, 0, 0, ny)
+		var want []time.Time
+		r.iterate(start, start, to, func(occurrence time.Time) bool {
+			if !occurrence.Before(to) {
+				return false
+			}
+			if !occurrence.Before(from) {
+				want = append(want, occurrence)
+			}
+			return true
+		})
+
+		got := r.Between(start, from, to)
+		if len(got) != len(want) || len(got) == 0 {
+			t.Fatalf("%s: got %d occurrences want %d", rule, len(got), len(want))
+		}
+		for i := range want {
+			if !got[i].Equal(want[i]) {
+				t.Errorf("%s: got %v want %v", rule, got[i], want[i])
+			}
+		}
+	}
+
+	// a minutely rule started decades ago is not expanded from its start
+	r, _ := ParseRecur("FREQ=MINUTELY")
+	start := time.Date(1997, time.September, 2, 9, 0, 0, 0, ny)
+	if got := r.Between(start, from, from.Add(time.Minute)); len(got) != 1 || !got[0].Equal(from) {
+		t.Errorf("got %v want %v", got, from)
+	}
+}
+
+func TestParseRecurErrors(t *testing.T) {
+	rules := []string{
+		"",
+		"COUNT=2",
+		"FREQ=FORTNIGHTLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=19970902T170000Z",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=WEEKLY;WKST=XX",
+	}
+	for _, rule := range rules {
+		if _, err := ParseRecur(rule); err == nil {
+			t.Errorf("ParseRecur(%q) expected an error", rule)
+		}
+	}
+}
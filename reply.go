@@ -0,0 +1,202 @@
+package ical
+
+import (
+	"fmt"
+	"time"
+)
+
+// replyStampParam records on an ATTENDEE the DTSTAMP of the last reply
+// processed for this attendee, as CalendarServer does
+const replyStampParam = "X-CALENDARSERVER-DTSTAMP"
+
+// A ReplyStatus tells how a reply was processed
+type ReplyStatus int
+
+const (
+	// ReplyApplied is a reply updating the participation status of the attendee
+	ReplyApplied ReplyStatus = iota
+	// ReplyStale is a reply older than the organizer's copy or than the last reply of the attendee
+	ReplyStale
+	// ReplyUnknownEvent is a reply to an event, an instance or a sequence unknown to the organizer
+	ReplyUnknownEvent
+	// ReplyUnknownAttendee is a reply from someone who is not an attendee of the event
+	ReplyUnknownAttendee
+)
+
+// A ReplyResult reports the processing of the reply to an event
+type ReplyResult struct {
+	UID          string
+	RecurrenceID time.Time
+	Attendee     string
+	PartStat     string
+	Status       ReplyStatus
+	Event        *Event // Event of the reply in the organizer's calendar, nil for an unknown event or a new override not applied
+}
+
+// ProcessReply applies an incoming REPLY to the organizer's calendar,
+// updating the participation status of the replying attendee.
+//
+// The events are matched by UID and RECURRENCE-ID, an override is added
+// for a reply applied to a single instance of a recurring event. Replies to an older
+// SEQUENCE, or with a DTSTAMP older than the last reply of the attendee,
+// are reported as stale and not applied.
+// from rfc5546-3.2.3
+func ProcessReply(organizer, reply *Calendar) ([]ReplyResult, error) {
+	if reply.Method != MethodReply {
+		return nil, fmt.Errorf("found method %q, expected %s", reply.Method, MethodReply)
+	}
+
+	var results []ReplyResult
+	for _, r := range reply.Events {
+		attendee := getProperty("ATTENDEE", r.Properties)
+		if attendee == nil {
+			return nil, fmt.Errorf("missing required property \"attendee\" in the reply to %q", r.UID)
+		}
+
+		result := ReplyResult{
+			UID:          r.UID,
+			RecurrenceID: r.RecurrenceID,
+			Attendee:     attendee.Value,
+			PartStat:     "NEEDS-ACTION",
+		}
+		if partstat, ok := attendee.Params["PARTSTAT"]; ok {
+			result.PartStat = partstat.Values[0]
+		}
+
+		v, added := findInstance(organizer, r.UID, r.RecurrenceID)
+		result.Status = applyReply(v, r, attendee, result.PartStat)
+		switch {
+		case result.Status == ReplyApplied:
+			if added {
+				organizer.Events = append(organizer.Events, v)
+			}
+			result.Event = v
+		case !added && result.Status != ReplyUnknownEvent:
+			result.Event = v
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// applyReply updates the attendee of an event from a reply
+func applyReply(v, r *Event, attendee *Property, partstat string) ReplyStatus {
+	if v == nil || r.Sequence > v.Sequence {
+		return ReplyUnknownEvent
+	}
+	if r.Sequence < v.Sequence {
+		return ReplyStale
+	}
+
+	var prop *Property
+	for _, p := range v.Properties {
+		if p.Name == "ATTENDEE" && sameAddress(p.Value, attendee.Value) {
+			prop = p
+			break
+		}
+	}
+	if prop == nil {
+		return ReplyUnknownAttendee
+	}
+
+	if stamp, ok := prop.Params[replyStampParam]; ok && !r.Timestamp.IsZero() {
+		last, err := time.Parse(dateTimeLayoutUTC, stamp.Values[0])
+		if err == nil && !r.Timestamp.After(last) {
+			return ReplyStale
+		}
+	}
+
+	prop.Params["PARTSTAT"] = &Param{Values: []string{partstat}}
+	delete(prop.Params, "RSVP")
+	if !r.Timestamp.IsZero() {
+		prop.Params[replyStampParam] = &Param{Values: []string{formatDateTime(r.Timestamp)}}
+	}
+
+	if delegated, ok := attendee.Params["DELEGATED-TO"]; ok {
+		prop.Params["DELEGATED-TO"] = &Param{Values: append([]string{}, delegated.Values...)}
+	}
+
+	// delegates are added to the attendees
+	for _, p := range r.Properties {
+		if p.Name != "ATTENDEE" || p == attendee {
+			continue
+		}
+		from, ok := p.Params["DELEGATED-FROM"]
+		if !ok || !containsAddress(from.Values, attendee.Value) || hasAttendee(v, p.Value) {
+			continue
+		}
+		v.Properties = append(v.Properties, p.clone())
+	}
+
+	return ReplyApplied
+}
+
+// findInstance returns the event of a calendar with a given UID and
+// RECURRENCE-ID. For an instance of a recurring event not overridden yet,
+// an override is created, and left to the caller to add to the calendar.
+func findInstance(c *Calendar, uid string, recurrenceID time.Time) (*Event, bool) {
+	var master *Event
+	for _, v := range c.Events {
+		if v.UID != uid {
+			continue
+		}
+		if v.RecurrenceID.Equal(recurrenceID) {
+			return v, false
+		}
+		if v.RecurrenceID.IsZero() {
+			master = v
+		}
+	}
+
+	if master == nil || recurrenceID.IsZero() {
+		return nil, false
+	}
+
+	instances := recurrences(master.StartDate, master.RRule, master.RDates, master.ExDates, recurrenceID, recurrenceID.Add(time.Nanosecond))
+	if len(instances) == 0 {
+		return nil, false
+	}
+	return master.override(instances[0]), true
+}
+
+// override creates the override of the instance of a recurring event starting at t
+func (v *Event) override(t time.Time) *Event {
+	o := v.clone()
+	o.RRule = nil
+	o.RDates = nil
+	o.ExDates = nil
+	o.RecurrenceID = t
+	removeProperty("RRULE", &o.Properties)
+	removeProperty("RDATE", &o.Properties)
+	removeProperty("EXDATE", &o.Properties)
+
+	end := v.occurrence(t).End
+	o.StartDate, o.EndDate = t, end
+	if !hasProperty("DTEND", o.Properties) && !hasProperty("DURATION", o.Properties) {
+		o.EndDate = t.Add(v.EndDate.Sub(v.StartDate)) // as parsed
+	}
+
+	if start := getProperty("DTSTART", o.Properties); start != nil {
+		rid := start.clone()
+		rid.Name = "RECURRENCE-ID"
+		rid.Value = formatDateLike(start, t)
+		start.Value = rid.Value
+		o.Properties = append(o.Properties, rid)
+	}
+	if prop := getProperty("DTEND", o.Properties); prop != nil {
+		prop.Value = formatDateLike(prop, end)
+	}
+
+	return o
+}
+
+// hasAttendee checks if an event has a given attendee
+func hasAttendee(v *Event, address string) bool {
+	for _, prop := range v.Properties {
+		if prop.Name == "ATTENDEE" && sameAddress(prop.Value, address) {
+			return true
+		}
+	}
+	return false
+}
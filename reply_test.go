@@ -0,0 +1,133 @@
+package ical
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProcessReply(t *testing.T) {
+	file, _ := os.Open("fixtures/invitation.ics")
+	organizer, err := Parse(file, nil)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := organizer.Events[0]
+
+	// reply builds the reply of an attendee stamped at a given time
+	reply := func(v *Event, attendee, partstat string, stamp time.Time) *Calendar {
+		c, err := NewReply(v, attendee, partstat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Events[0].Timestamp = stamp
+		setProperty("DTSTAMP", formatDateTime(stamp), &c.Events[0].Properties)
+		return c
+	}
+
+	stamp := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	status := func(results []ReplyResult, err error) ReplyStatus {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("got %d results", len(results))
+		}
+		return results[0].Status
+	}
+	partstat := func(v *Event, attendee string) string {
+		for _, prop := range v.Properties {
+			if prop.Name == "ATTENDEE" && prop.Value == attendee {
+				return prop.Params["PARTSTAT"].Values[0]
+			}
+		}
+		return ""
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		if got := status(ProcessReply(organizer, reply(v, "mailto:bob@example.com", "ACCEPTED", stamp))); got != ReplyApplied {
+			t.Errorf("got status %d", got)
+		}
+		if got := partstat(v, "mailto:bob@example.com"); got != "ACCEPTED" {
+			t.Errorf("got partstat %s", got)
+		}
+	})
+
+	t.Run("older reply", func(t *testing.T) {
+		if got := status(ProcessReply(organizer, reply(v, "mailto:bob@example.com", "DECLINED", stamp.Add(-time.Hour)))); got != ReplyStale {
+			t.Errorf("got status %d", got)
+		}
+		if got := partstat(v, "mailto:bob@example.com"); got != "ACCEPTED" {
+			t.Errorf("got partstat %s", got)
+		}
+	})
+
+	t.Run("older sequence", func(t *testing.T) {
+		old := v.clone()
+		old.Sequence = 0
+		setProperty("SEQUENCE", "0", &old.Properties)
+		if got := status(ProcessReply(organizer, reply(old, "mailto:carol@example.com", "ACCEPTED", stamp))); got != ReplyStale {
+			t.Errorf("got status %d", got)
+		}
+	})
+
+	t.Run("unknown event", func(t *testing.T) {
+		other := v.clone()
+		other.UID = "other@example.com"
+		setProperty("UID", other.UID, &other.Properties)
+		if got := status(ProcessReply(organizer, reply(other, "mailto:bob@example.com", "ACCEPTED", stamp))); got != ReplyUnknownEvent {
+			t.Errorf("got status %d", got)
+		}
+	})
+
+	t.Run("unknown attendee", func(t *testing.T) {
+		c := reply(v, "mailto:bob@example.com", "ACCEPTED", stamp.Add(time.Hour))
+		getProperty("ATTENDEE", c.Events[0].Properties).Value = "mailto:mallory@example.com"
+		if got := status(ProcessReply(organizer, c)); got != ReplyUnknownAttendee {
+			t.Errorf("got status %d", got)
+		}
+	})
+
+	t.Run("stale instance", func(t *testing.T) {
+		old := v.override(v.StartDate.AddDate(0, 0, 7))
+		old.Sequence = 0
+		setProperty("SEQUENCE", "0", &old.Properties)
+		results, err := ProcessReply(organizer, reply(old, "mailto:carol@example.com", "DECLINED", stamp))
+		if status(results, err) != ReplyStale {
+			t.Fatalf("got status %d", results[0].Status)
+		}
+		if len(organizer.Events) != 1 || results[0].Event != nil {
+			t.Errorf("expected no override for a stale reply, got %d events", len(organizer.Events))
+		}
+	})
+
+	t.Run("instance", func(t *testing.T) {
+		instance := v.override(v.StartDate.AddDate(0, 0, 7))
+		results, err := ProcessReply(organizer, reply(instance, "mailto:carol@example.com", "DECLINED", stamp))
+		if status(results, err) != ReplyApplied {
+			t.Fatalf("got status %d", results[0].Status)
+		}
+		if len(organizer.Events) != 2 || results[0].Event != organizer.Events[1] {
+			t.Fatalf("expected an override to be created")
+		}
+		if got := partstat(organizer.Events[1], "mailto:carol@example.com"); got != "DECLINED" {
+			t.Errorf("got partstat %s", got)
+		}
+		if got := partstat(v, "mailto:carol@example.com"); got != "NEEDS-ACTION" {
+			t.Errorf("got partstat %s on the master", got)
+		}
+
+		occurrences := organizer.EventsBetween(v.StartDate, v.StartDate.AddDate(0, 1, 0))
+		if len(occurrences) != 4 || occurrences[1].Event != organizer.Events[1] {
+			t.Errorf("got %d occurrences, expected the second one overridden", len(occurrences))
+		}
+	})
+
+	t.Run("not a reply", func(t *testing.T) {
+		if _, err := ProcessReply(organizer, organizer); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
@@ -0,0 +1,320 @@
+package ical
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// An AlarmFired is delivered by a Scheduler when an alarm comes due.
+// Either Event or Todo is set, depending on the component of the alarm.
+type AlarmFired struct {
+	Event *Event
+	Todo  *Todo
+	Alarm *Alarm
+	When  time.Time
+}
+
+// A Clock tells the time to a Scheduler
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock backed by the time package
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// A Scheduler delivers the alarms of a set of calendars as they come due,
+// either on its channel C or to the OnFire callback when set.
+//
+// Alarms acknowledged at or after their fire time are skipped, and snooze
+// alarms from rfc9074 fire like any other alarm. The instances of a
+// recurring event overridden by an event with a RECURRENCE-ID fire the
+// alarms of the override, and cancelled events fire none.
+type Scheduler struct {
+	C         <-chan AlarmFired // Fired alarms, unused when OnFire is set
+	OnFire    func(AlarmFired)  // Callback for fired alarms
+	Clock     Clock             // Clock, defaults to the system clock
+	Lookahead time.Duration     // Longest time to sleep before looking for alarms again
+
+	mu        sync.Mutex
+	calendars []*Calendar
+	c         chan AlarmFired
+	reload    chan struct{}
+	stop      chan struct{} // Closed to stop, nil when not running
+	done      chan struct{}
+}
+
+// NewScheduler creates a Scheduler for the alarms of the calendars.
+// Clock, OnFire and Lookahead must be set before calling Start.
+func NewScheduler(calendars ...*Calendar) *Scheduler {
+	c := make(chan AlarmFired)
+	return &Scheduler{
+		C:         c,
+		Clock:     systemClock{},
+		Lookahead: 24 * time.Hour,
+		calendars: calendars,
+		c:         c,
+		reload:    make(chan struct{}, 1),
+	}
+}
+
+// Start begins delivering alarms coming due from now on. Starting a
+// scheduler already running does nothing, and a stopped scheduler can be
+// started again.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Stop stops delivering alarms and waits for the delivery in progress.
+// Stopping a scheduler not started, or already stopped, does nothing.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Update replaces the calendars of the scheduler. Alarms which came due
+// before the update are not delivered again.
+func (s *Scheduler) Update(calendars ...*Calendar) {
+	s.mu.Lock()
+	s.calendars = calendars
+	s.mu.Unlock()
+	s.wake()
+}
+
+// Acknowledge marks a fired alarm as acknowledged now. Acknowledging a
+// snooze alarm removes it from its component.
+func (s *Scheduler) Acknowledge(f AlarmFired) {
+	s.mu.Lock()
+	now := s.Clock.Now()
+	acknowledge(f.Alarm, now)
+	if f.Alarm.SnoozeOf != "" {
+		removeAlarm(f, f.Alarm)
+	}
+	s.mu.Unlock()
+	s.wake()
+}
+
+// Snooze acknowledges a fired alarm and adds a snooze alarm to its
+// component, firing once after d. Snoozing a snooze alarm replaces it.
+func (s *Scheduler) Snooze(f AlarmFired, d time.Duration) *Alarm {
+	s.mu.Lock()
+	now := s.Clock.Now()
+
+	original := f.Alarm.UID
+	if f.Alarm.SnoozeOf != "" {
+		original = f.Alarm.SnoozeOf
+		removeAlarm(f, f.Alarm)
+	} else {
+		if original == "" {
+			original = newUID()
+			f.Alarm.UID = original
+			setProperty("UID", original, &f.Alarm.Properties)
+		}
+		acknowledge(f.Alarm, now)
+	}
+
+	a := snooze(f.Alarm, original, now.Add(d))
+	if f.Todo != nil {
+		f.Todo.Alarms = append(f.Todo.Alarms, a)
+	} else {
+		f.Event.Alarms = append(f.Event.Alarms, a)
+	}
+	s.mu.Unlock()
+	s.wake()
+	return a
+}
+
+// wake makes the scheduler look for alarms again
+func (s *Scheduler) wake() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the alarms which came due since the last run, then sleeps
+// until the next one
+func (s *Scheduler) run(stop, done chan struct{}) {
+	defer close(done)
+	last := s.Clock.Now()
+
+	for {
+		now := s.Clock.Now()
+		for _, f := range s.due(last, now) {
+			if !s.deliver(f, stop) {
+				return
+			}
+		}
+		last = now
+
+		wait := s.Lookahead
+		if next, ok := s.next(now); ok {
+			wait = next.Sub(now)
+		}
+
+		select {
+		case <-s.Clock.After(wait):
+		case <-s.reload:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// deliver sends a fired alarm, and returns false if the scheduler was stopped meanwhile
+func (s *Scheduler) deliver(f AlarmFired, stop chan struct{}) bool {
+	if s.OnFire != nil {
+		s.OnFire(f)
+		return true
+	}
+	select {
+	case s.c <- f:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// due returns the alarms firing within (from, to], in order
+func (s *Scheduler) due(from, to time.Time) []AlarmFired {
+	fired := s.fired(from.Add(time.Nanosecond), to.Add(time.Nanosecond))
+	sort.SliceStable(fired, func(i, j int) bool { return fired[i].When.Before(fired[j].When) })
+	return fired
+}
+
+// next returns the earliest fire time after now, within the lookahead
+func (s *Scheduler) next(now time.Time) (time.Time, bool) {
+	var next time.Time
+	for _, f := range s.fired(now.Add(time.Nanosecond), now.Add(s.Lookahead)) {
+		if next.IsZero() || f.When.Before(next) {
+			next = f.When
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// fired returns the unacknowledged alarms firing within [from, to)
+func (s *Scheduler) fired(from, to time.Time) []AlarmFired {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var fired []AlarmFired
+	for _, c := range s.calendars {
+		overrides := make(map[string][]*Event)
+		for _, v := range c.Events {
+			if !v.RecurrenceID.IsZero() {
+				overrides[v.UID] = append(overrides[v.UID], v)
+			}
+		}
+
+		for _, v := range c.Events {
+			if prop := getProperty("STATUS", v.Properties); prop != nil && prop.Value == "CANCELLED" {
+				continue
+			}
+			var overriding []*Event
+			if v.RecurrenceID.IsZero() {
+				overriding = overrides[v.UID]
+			}
+			for _, a := range v.Alarms {
+				for _, t := range eventFireTimes(a, v, overriding, from, to) {
+					if t.After(a.Acknowledged) {
+						fired = append(fired, AlarmFired{Event: v, Alarm: a, When: t})
+					}
+				}
+			}
+		}
+		for _, todo := range c.Todos {
+			for _, a := range todo.Alarms {
+				for _, t := range a.TodoFireTimes(todo, from, to) {
+					if t.After(a.Acknowledged) {
+						fired = append(fired, AlarmFired{Todo: todo, Alarm: a, When: t})
+					}
+				}
+			}
+		}
+	}
+	return fired
+}
+
+// eventFireTimes returns the fire times within [from, to) of an alarm for
+// the instances of its event which aren't overridden
+func eventFireTimes(a *Alarm, v *Event, overrides []*Event, from, to time.Time) []time.Time {
+	instances := func(from, to time.Time) []time.Time {
+		var starts []time.Time
+		for _, t := range recurrences(v.StartDate, v.RRule, v.RDates, v.ExDates, from, to) {
+			if !overridden(overrides, t) {
+				starts = append(starts, t)
+			}
+		}
+		return starts
+	}
+	return a.fireTimes(instances, v.occurrence(v.StartDate).End.Sub(v.StartDate), from, to)
+}
+
+// acknowledge sets the ACKNOWLEDGED property of an alarm
+func acknowledge(a *Alarm, now time.Time) {
+	a.Acknowledged = now.UTC().Truncate(time.Second)
+	setProperty("ACKNOWLEDGED", a.Acknowledged.Format(dateTimeLayoutUTC), &a.Properties)
+}
+
+// snooze creates a snooze alarm for the original alarm, firing at when
+func snooze(a *Alarm, original string, when time.Time) *Alarm {
+	s := NewAlarm()
+	s.UID = newUID()
+	s.Action = a.Action
+	s.SnoozeOf = original
+	s.TriggerDate = when.UTC().Truncate(time.Second)
+	s.Trigger = s.TriggerDate.Format(dateTimeLayoutUTC)
+	s.Description = a.Description
+	s.Summary = a.Summary
+	s.Attendees = append(s.Attendees, a.Attendees...)
+	s.Attach = append(s.Attach, a.Attach...)
+
+	setProperty("UID", s.UID, &s.Properties)
+	setProperty("ACTION", s.Action, &s.Properties)
+	trigger := setProperty("TRIGGER", s.Trigger, &s.Properties)
+	trigger.Params["VALUE"] = &Param{Values: []string{"DATE-TIME"}}
+	related := setProperty("RELATED-TO", original, &s.Properties)
+	related.Params["RELTYPE"] = &Param{Values: []string{"SNOOZE"}}
+
+	for _, prop := range a.Properties {
+		switch prop.Name {
+		case "DESCRIPTION", "SUMMARY", "ATTENDEE", "ATTACH":
+			s.Properties = append(s.Properties, prop.clone())
+		}
+	}
+	return s
+}
+
+// removeAlarm removes an alarm from the component of a fired alarm
+func removeAlarm(f AlarmFired, a *Alarm) {
+	alarms := &f.Event.Alarms
+	if f.Todo != nil {
+		alarms = &f.Todo.Alarms
+	}
+	kept := (*alarms)[:0]
+	for _, alarm := range *alarms {
+		if alarm != a {
+			kept = append(kept, alarm)
+		}
+	}
+	*alarms = kept
+}
@@ -0,0 +1,291 @@
+package ical
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock which only moves when told to
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := make(chan time.Time, 1)
+	f.waiters = append(f.waiters, fakeWaiter{f.now.Add(d), c})
+	return c
+}
+
+// Advance moves the clock forward and wakes up the expired waiters
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	kept := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.at.After(f.now) {
+			kept = append(kept, w)
+			continue
+		}
+		w.c <- f.now
+	}
+	f.waiters = kept
+}
+
+// BlockUntil waits for n waiters to be registered
+func (f *fakeClock) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		count := len(f.waiters)
+		f.mu.Unlock()
+		if count >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitAlarm receives the next fired alarm
+func waitAlarm(t *testing.T, s *Scheduler) AlarmFired {
+	t.Helper()
+	select {
+	case f := <-s.C:
+		return f
+	case <-time.After(time.Second):
+		t.Fatal("no alarm fired")
+	}
+	return AlarmFired{}
+}
+
+// noAlarm checks no alarm is fired
+func noAlarm(t *testing.T, s *Scheduler) {
+	t.Helper()
+	select {
+	case f := <-s.C:
+		t.Fatalf("unexpected alarm fired at %v", f.When)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func schedulerCalendar(start time.Time, alarms ...*Alarm) *Calendar {
+	c := NewCalendar()
+	v := NewEvent()
+	v.UID = "event@test"
+	v.StartDate = start
+	v.EndDate = start.Add(time.Hour)
+	v.Alarms = alarms
+	c.Events = append(c.Events, v)
+	return c
+}
+
+func TestScheduler(t *testing.T) {
+	now := time.Date(2019, time.January, 2, 9, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: now}
+
+	c := schedulerCalendar(now.Add(time.Hour),
+		&Alarm{Related: "START", Offset: -15 * time.Minute},
+		&Alarm{Related: "START", Offset: -2 * time.Hour}, // already past
+	)
+
+	s := NewScheduler(c)
+	s.Clock = clock
+	s.Start()
+	defer s.Stop()
+
+	clock.BlockUntil(1)
+	clock.Advance(45 * time.Minute)
+
+	f := waitAlarm(t, s)
+	if !f.When.Equal(now.Add(45*time.Minute)) || f.Event != c.Events[0] || f.Alarm != c.Events[0].Alarms[0] {
+		t.Errorf("got alarm at %v", f.When)
+	}
+
+	t.Run("snooze", func(t *testing.T) {
+		a := s.Snooze(f, 5*time.Minute)
+		if a.SnoozeOf == "" || a.SnoozeOf != f.Alarm.UID {
+			t.Errorf("snooze alarm related to %q, want %q", a.SnoozeOf, f.Alarm.UID)
+		}
+		if !f.Alarm.Acknowledged.Equal(clock.Now()) {
+			t.Errorf("got acknowledged %v", f.Alarm.Acknowledged)
+		}
+
+		clock.Advance(5 * time.Minute)
+		snoozed := waitAlarm(t, s)
+		if snoozed.Alarm != a || !snoozed.When.Equal(now.Add(50*time.Minute)) {
+			t.Errorf("got alarm at %v", snoozed.When)
+		}
+
+		s.Acknowledge(snoozed)
+		if len(c.Events[0].Alarms) != 2 {
+			t.Errorf("got %d alarms, expected the snooze alarm to be removed", len(c.Events[0].Alarms))
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		updated := schedulerCalendar(now.Add(2*time.Hour),
+			&Alarm{Related: "START", Offset: -10 * time.Minute},
+			&Alarm{Related: "START", Offset: -75 * time.Minute}, // came due before the update
+		)
+		s.Update(updated)
+		noAlarm(t, s)
+
+		clock.Advance(time.Hour)
+		f := waitAlarm(t, s)
+		if f.Alarm != updated.Events[0].Alarms[0] {
+			t.Errorf("got alarm at %v from the previous calendar", f.When)
+		}
+	})
+}
+
+func TestSchedulerAcknowledged(t *testing.T) {
+	now := time.Date(2019, time.January, 2, 9, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: now}
+
+	var mu sync.Mutex
+	var fired []AlarmFired
+
+	c := schedulerCalendar(now.Add(time.Hour),
+		&Alarm{Related: "START", Offset: -15 * time.Minute, Acknowledged: now.Add(50 * time.Minute)},
+		&Alarm{Related: "START", Offset: -30 * time.Minute, Repeat: 1, Duration: 10 * time.Minute, Acknowledged: now.Add(30 * time.Minute)},
+	)
+
+	s := NewScheduler(c)
+	s.Clock = clock
+	s.OnFire = func(f AlarmFired) {
+		mu.Lock()
+		fired = append(fired, f)
+		mu.Unlock()
+	}
+	s.Start()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Hour)
+	time.Sleep(50 * time.Millisecond)
+	s.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(fired) != 1 || !fired[0].When.Equal(now.Add(40*time.Minute)) {
+		t.Errorf("got %d alarms, expected only the repetition after the acknowledgement", len(fired))
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	s := NewScheduler()
+	s.Stop() // not started
+
+	s.Clock = &fakeClock{now: time.Date(2019, time.January, 2, 9, 0, 0, 0, time.UTC)}
+	s.Start()
+	s.Stop()
+	s.Stop() // already stopped
+}
+
+func TestSchedulerRestart(t *testing.T) {
+	now := time.Date(2019, time.January, 2, 9, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: now}
+	c := schedulerCalendar(now.Add(time.Hour), &Alarm{Related: "START", Offset: -15 * time.Minute})
+
+	s := NewScheduler(c)
+	s.Clock = clock
+	s.Start()
+	s.Start() // already running
+	clock.BlockUntil(1)
+	s.Stop()
+
+	s.Start()
+	clock.BlockUntil(2) // the waiter of the first run is left behind
+	clock.Advance(45 * time.Minute)
+	if f := waitAlarm(t, s); !f.When.Equal(now.Add(45 * time.Minute)) {
+		t.Errorf("got alarm at %v", f.When)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked after a restart")
+	}
+}
+
+func TestSchedulerOverrides(t *testing.T) {
+	start := time.Date(2019, time.January, 2, 10, 0, 0, 0, time.UTC)
+	moved := start.AddDate(0, 0, 1)
+	rule, _ := ParseRecur("FREQ=DAILY;COUNT=3")
+
+	for _, test := range []struct {
+		name     string
+		override *Event
+		expected []time.Time
+	}{
+		{
+			name: "moved",
+			override: &Event{
+				UID:          "event@test",
+				RecurrenceID: moved,
+				StartDate:    moved.Add(5 * time.Hour),
+				EndDate:      moved.Add(6 * time.Hour),
+				Alarms:       []*Alarm{{Related: "START", Offset: -10 * time.Minute}},
+			},
+			expected: []time.Time{
+				start.Add(-10 * time.Minute),
+				moved.Add(5*time.Hour - 10*time.Minute),
+				start.AddDate(0, 0, 2).Add(-10 * time.Minute),
+			},
+		},
+		{
+			name: "cancelled",
+			override: &Event{
+				UID:          "event@test",
+				RecurrenceID: moved,
+				StartDate:    moved,
+				EndDate:      moved.Add(time.Hour),
+				Properties:   []*Property{{Name: "STATUS", Value: "CANCELLED", Params: make(map[string]*Param)}},
+				Alarms:       []*Alarm{{Related: "START", Offset: -10 * time.Minute}},
+			},
+			expected: []time.Time{
+				start.Add(-10 * time.Minute),
+				start.AddDate(0, 0, 2).Add(-10 * time.Minute),
+			},
+		},
+	} {
+		c := schedulerCalendar(start, &Alarm{Related: "START", Offset: -10 * time.Minute})
+		c.Events[0].RRule = rule
+		c.Events = append(c.Events, test.override)
+
+		s := NewScheduler(c)
+		var times []time.Time
+		for _, f := range s.due(start.Add(-time.Hour), start.AddDate(0, 0, 3)) {
+			times = append(times, f.When)
+		}
+		if len(times) != len(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, times)
+			continue
+		}
+		for i := range times {
+			if !times[i].Equal(test.expected[i]) {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, times)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package ical
+
+import (
+	"time"
+)
+
+// defaultSlotStep is the default granularity of slot starts
+const defaultSlotStep = 30 * time.Minute
+
+// A SlotQuery describes the meeting slots to look for with FindSlots
+type SlotQuery struct {
+	Start        time.Time                // Start of the search window
+	End          time.Time                // End of the search window
+	Duration     time.Duration            // Length of a slot
+	Buffer       time.Duration            // Free time required before and after a slot
+	Location     *time.Location           // Location of the working hours, defaults to UTC
+	WorkingHours map[time.Weekday][]Hours // Working hours per weekday, no slot on days without any
+	Step         time.Duration            // Granularity of slot starts from the start of the working hours, defaults to 30 minutes
+	Limit        int                      // Maximum number of slots, 0 for all of them
+}
+
+// Hours represent a daily time range, as offsets from midnight
+type Hours struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// FindSlots returns the slots within the working hours where every calendar
+// is free, earliest first. Each calendar stands for an attendee, and
+// tentatively busy time counts as busy.
+func FindSlots(q SlotQuery, calendars ...*Calendar) []Period {
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	step := q.Step
+	if step <= 0 {
+		step = defaultSlotStep
+	}
+
+	var busy []Period
+	for _, c := range calendars {
+		f := ComputeFreeBusy(q.Start.Add(-q.Buffer), q.End.Add(q.Buffer), "", c)
+		for _, p := range append(f.Busy, f.BusyTentative...) {
+			busy = append(busy, Period{Start: p.Start.Add(-q.Buffer), End: p.End.Add(q.Buffer)})
+		}
+	}
+	busy = mergePeriods(busy)
+
+	var slots []Period
+	y, m, d := q.Start.In(loc).Date()
+	for day := time.Date(y, m, d, 0, 0, 0, 0, loc); day.Before(q.End); day = day.AddDate(0, 0, 1) {
+		for _, h := range q.WorkingHours[day.Weekday()] {
+			dy, dm, dd := day.Date()
+			work := Period{
+				Start: time.Date(dy, dm, dd, 0, 0, 0, int(h.Start), loc),
+				End:   time.Date(dy, dm, dd, 0, 0, 0, int(h.End), loc),
+			}
+			free := work
+			if free.Start.Before(q.Start) {
+				free.Start = q.Start
+			}
+			if free.End.After(q.End) {
+				free.End = q.End
+			}
+			if !free.End.After(free.Start) {
+				continue
+			}
+
+			for _, p := range subtractPeriods([]Period{free}, busy) {
+				// first step of the working hours within the free time
+				start := work.Start
+				if p.Start.After(start) {
+					steps := (p.Start.Sub(start) + step - 1) / step
+					start = start.Add(steps * step)
+				}
+
+				for ; !start.Add(q.Duration).After(p.End); start = start.Add(step) {
+					slots = append(slots, Period{Start: start, End: start.Add(q.Duration)})
+					if q.Limit > 0 && len(slots) >= q.Limit {
+						return slots
+					}
+				}
+			}
+		}
+	}
+
+	return slots
+}
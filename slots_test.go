@@ -0,0 +1,59 @@
+package ical
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindSlots(t *testing.T) {
+	paris, _ := time.LoadLocation("Europe/Paris")
+	at := func(d, h, m int) time.Time {
+		return time.Date(2019, time.January, d, h, m, 0, 0, paris)
+	}
+	calendar := func(periods ...Period) *Calendar {
+		c := NewCalendar()
+		for _, p := range periods {
+			v := NewEvent()
+			v.StartDate, v.EndDate = p.Start, p.End
+			c.Events = append(c.Events, v)
+		}
+		return c
+	}
+
+	alice := calendar(Period{at(4, 9, 0), at(4, 10, 0)}, Period{at(4, 14, 0), at(4, 17, 0)})
+	bob := calendar(Period{at(4, 10, 30), at(4, 12, 0)})
+
+	hours := []Hours{{9 * time.Hour, 12 * time.Hour}, {13 * time.Hour, 17 * time.Hour}}
+	query := SlotQuery{
+		Start:    at(4, 0, 0), // Friday
+		End:      at(8, 0, 0),
+		Duration: 30 * time.Minute,
+		Buffer:   15 * time.Minute,
+		Location: paris,
+		WorkingHours: map[time.Weekday][]Hours{
+			time.Monday: hours,
+			time.Friday: hours,
+		},
+		Step: 15 * time.Minute,
+	}
+
+	want := []Period{
+		{at(4, 13, 0), at(4, 13, 30)},
+		{at(4, 13, 15), at(4, 13, 45)},
+		{at(7, 9, 0), at(7, 9, 30)},
+	}
+
+	query.Limit = len(want)
+	got := FindSlots(query, alice, bob)
+	if !equalPeriods(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	t.Run("no room between meetings", func(t *testing.T) {
+		q := query
+		q.Start, q.End, q.Limit = at(4, 9, 0), at(4, 12, 0), 0
+		if got := FindSlots(q, alice, bob); len(got) != 0 {
+			t.Errorf("got %v, expected no slot", got)
+		}
+	})
+}
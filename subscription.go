@@ -0,0 +1,153 @@
+package ical
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultRefreshInterval is the polling interval of a feed which doesn't
+// suggest any
+var DefaultRefreshInterval = 24 * time.Hour
+
+// A Subscription fetches a calendar published at an http(s) or webcal URL,
+// with conditional requests so that an unchanged feed is neither downloaded
+// nor parsed again.
+type Subscription struct {
+	URL          string         // URL of the feed, webcal:// standing for https://
+	Client       *http.Client   // Client sending the requests, defaults to http.DefaultClient
+	Location     *time.Location // Location of the parsed calendar, defaults to the system location
+	Calendar     *Calendar      // Calendar of the last fetch
+	ETag         string         // ETag of the last fetch
+	LastModified string         // Last-Modified of the last fetch
+	Interval     time.Duration  // Polling interval suggested by the feed, or DefaultRefreshInterval
+	Fetched      time.Time      // Time of the last fetch
+}
+
+// FeedChanges reports the events of a feed changed by a fetch, recurrence
+// instances being told apart by their RECURRENCE-ID
+type FeedChanges struct {
+	Modified bool     // Whether the feed was downloaded, false when not modified
+	Added    []*Event // Events new in the feed
+	Updated  []*Event // Events of the feed that changed
+	Removed  []*Event // Events no longer in the feed
+}
+
+// NewSubscription creates a Subscription to a feed
+func NewSubscription(url string) *Subscription {
+	return &Subscription{URL: url, Interval: DefaultRefreshInterval}
+}
+
+// Next returns when the feed should be fetched again
+func (s *Subscription) Next() time.Time {
+	return s.Fetched.Add(s.Interval)
+}
+
+// Fetch downloads the feed, unless it is not modified since the last fetch,
+// and returns the changes of its events
+func (s *Subscription) Fetch() (*FeedChanges, error) {
+	u, err := feedURL(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/calendar")
+	if s.Calendar != nil {
+		if s.ETag != "" {
+			req.Header.Set("If-None-Match", s.ETag)
+		}
+		if s.LastModified != "" {
+			req.Header.Set("If-Modified-Since", s.LastModified)
+		}
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && s.Calendar != nil:
+		s.Fetched = time.Now()
+		return &FeedChanges{}, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("fetching %s: %s", s.URL, resp.Status)
+	}
+
+	c, err := Parse(resp.Body, s.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := feedChanges(s.Calendar, c)
+	s.Calendar = c
+	s.ETag = resp.Header.Get("ETag")
+	s.LastModified = resp.Header.Get("Last-Modified")
+	s.Interval = refreshInterval(c)
+	s.Fetched = time.Now()
+	return changes, nil
+}
+
+// feedURL returns the http URL of a feed
+func feedURL(feed string) (string, error) {
+	u, err := url.Parse(feed)
+	if err != nil {
+		return "", err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "webcal", "webcals":
+		u.Scheme = "https"
+	default:
+		return "", fmt.Errorf("unsupported feed scheme %q", u.Scheme)
+	}
+	return u.String(), nil
+}
+
+// refreshInterval returns the polling interval suggested by a calendar with
+// REFRESH-INTERVAL, or else X-PUBLISHED-TTL
+// from rfc7986-5.7
+func refreshInterval(c *Calendar) time.Duration {
+	for _, name := range []string{"REFRESH-INTERVAL", "X-PUBLISHED-TTL"} {
+		if prop := getProperty(name, c.Properties); prop != nil {
+			if d, err := parseDuration(prop.Value); err == nil && d > 0 {
+				return d
+			}
+		}
+	}
+	return DefaultRefreshInterval
+}
+
+// feedChanges compares the events of two versions of a feed, old being nil
+// for the first fetch. A change of DTSTAMP alone is ignored, as feeds often
+// stamp events with the time of the download.
+func feedChanges(old, c *Calendar) *FeedChanges {
+	changes := &FeedChanges{Modified: true}
+	if old == nil {
+		old = NewCalendar()
+	}
+
+	for _, change := range Diff(old, c).Events {
+		switch change.Kind {
+		case ChangeAdded:
+			changes.Added = append(changes.Added, change.New)
+		case ChangeRemoved:
+			changes.Removed = append(changes.Removed, change.Old)
+		case ChangeModified:
+			if change.AlarmsChanged || len(change.Properties) > 1 || change.Properties[0].Name != "DTSTAMP" {
+				changes.Updated = append(changes.Updated, change.New)
+			}
+		}
+	}
+	return changes
+}
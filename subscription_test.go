@@ -0,0 +1,91 @@
+package ical
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscription(t *testing.T) {
+	data, err := ioutil.ReadFile("fixtures/invitation.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := strings.Replace(string(data), "VERSION:2.0\r\n", "VERSION:2.0\r\nREFRESH-INTERVAL;VALUE=DURATION:PT6H\r\n", 1)
+	etag := `"1"`
+	downloads := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(feed))
+	}))
+	defer server.Close()
+
+	s := NewSubscription(server.URL)
+	changes, err := s.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Modified || len(changes.Added) != 1 || len(changes.Updated) != 0 || len(changes.Removed) != 0 {
+		t.Errorf("unexpected first fetch %+v", changes)
+	}
+	if s.Interval != 6*time.Hour || s.ETag != etag || s.Next() != s.Fetched.Add(6*time.Hour) {
+		t.Errorf("unexpected interval %s or etag %s", s.Interval, s.ETag)
+	}
+
+	changes, err = s.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Modified || downloads != 1 {
+		t.Errorf("expected the feed not modified, got %+v after %d downloads", changes, downloads)
+	}
+
+	feed = strings.Replace(feed, "SUMMARY:Planning", "SUMMARY:Review", 1)
+	feed = strings.Replace(feed, "REFRESH-INTERVAL;VALUE=DURATION:PT6H", "X-PUBLISHED-TTL:PT1H", 1)
+	feed = strings.Replace(feed, "END:VCALENDAR", "BEGIN:VEVENT\r\nUID:other@example.com\r\nDTSTAMP:20190101T000000Z\r\nDTSTART:20190111T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR", 1)
+	etag = `"2"`
+	changes, err = s.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Modified || len(changes.Added) != 1 || len(changes.Updated) != 1 || changes.Updated[0].Summary != "Review" {
+		t.Errorf("unexpected changes %+v", changes)
+	}
+	if s.Interval != time.Hour {
+		t.Errorf("expected the X-PUBLISHED-TTL interval, got %s", s.Interval)
+	}
+
+	feed = strings.Replace(string(data), "END:VCALENDAR", "BEGIN:VEVENT\r\nUID:other@example.com\r\nDTSTAMP:20190102T000000Z\r\nDTSTART:20190111T100000Z\r\nEND:VEVENT\r\nEND:VCALENDAR", 1)
+	etag = `"3"`
+	changes, err = s.Fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Added) != 0 || len(changes.Updated) != 1 || len(changes.Removed) != 0 || s.Interval != DefaultRefreshInterval {
+		t.Errorf("expected only the meeting updated, got %+v", changes)
+	}
+}
+
+func Test_feedURL(t *testing.T) {
+	tests := map[string]string{
+		"webcal://example.com/feed.ics": "https://example.com/feed.ics",
+		"http://example.com/feed.ics":   "http://example.com/feed.ics",
+		"ftp://example.com/feed.ics":    "",
+	}
+	for feed, expected := range tests {
+		got, err := feedURL(feed)
+		if got != expected || (expected == "") != (err != nil) {
+			t.Errorf("feedURL(%q): expected %q, got %q, %v", feed, expected, got, err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package ical
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A transition is a change of the UTC offset of a location
+type transition struct {
+	at   time.Time // first instant of the new offset, in UTC
+	from int       // offset before, in seconds east of UTC
+	to   int       // offset after
+	name string    // abbreviation after
+}
+
+// transitions returns the transitions of a location during the years
+// from..to, found by stepping through them
+func transitions(loc *time.Location, from, to int) []transition {
+	const step = 12 * time.Hour
+
+	var list []transition
+	t := time.Date(from, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(to+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, offset := t.In(loc).Zone()
+	for ; t.Before(end); t = t.Add(step) {
+		next := t.Add(step)
+		if _, o := next.In(loc).Zone(); o == offset {
+			continue
+		}
+
+		// the first second of the new offset
+		lo, hi := t, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if _, o := mid.In(loc).Zone(); o == offset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		name, o := hi.In(loc).Zone()
+		list = append(list, transition{at: hi, from: offset, to: o, name: name})
+		offset = o
+	}
+	return list
+}
+
+// newTimezone creates the VTIMEZONE of a location, with the observances of
+// the years from..to. The observances starting every year on the same
+// weekday of a month are a yearly rule, the other ones a list of dates
+// after the offset of the location at the start of these years.
+// from rfc5545-3.6.5
+func newTimezone(loc *time.Location, from, to int) *Timezone {
+	tz := NewTimezone()
+	setProperty("TZID", loc.String(), &tz.Properties)
+
+	// an observance before the first date, in case it follows a rule
+	from--
+	list := transitions(loc, from, to)
+
+	standard := 0
+	if len(list) > 0 {
+		standard = list[0].from
+	}
+	for _, t := range list {
+		if t.to < standard {
+			standard = t.to
+		}
+	}
+
+	// transitions of the same offsets and name are an observance
+	var keys []string
+	groups := make(map[string][]transition)
+	for _, t := range list {
+		key := fmt.Sprintf("%d %d %s", t.from, t.to, t.name)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	rules := true
+	for _, key := range keys {
+		group := groups[key]
+		properties := observance(group[0].from, group[0].to, group[0].name, localTime(group[0]))
+		if rrule := yearlyRule(group, to-from+1); rrule != "" {
+			setProperty("RRULE", rrule, &properties)
+		} else {
+			rules = false
+			var dates []string
+			for _, t := range group[1:] {
+				dates = append(dates, localTime(t))
+			}
+			if len(dates) > 0 {
+				setProperty("RDATE", strings.Join(dates, ","), &properties)
+			}
+		}
+
+		if group[0].to > standard {
+			tz.Daylights = append(tz.Daylights, &Daylight{Properties: properties})
+		} else {
+			tz.Standards = append(tz.Standards, &Standard{Properties: properties})
+		}
+	}
+
+	// the offset before the first transition not following a rule
+	if len(list) == 0 || !rules {
+		name, offset := time.Date(from, 1, 1, 0, 0, 0, 0, loc).Zone()
+		base := &Standard{Properties: observance(offset, offset, name, "19700101T000000")}
+		tz.Standards = append([]*Standard{base}, tz.Standards...)
+	}
+	return tz
+}
+
+// observance returns the properties of a STANDARD or DAYLIGHT observance
+func observance(from, to int, name, start string) []*Property {
+	properties := make([]*Property, 0)
+	setProperty("DTSTART", start, &properties)
+	setProperty("TZOFFSETFROM", formatUTCOffset(from), &properties)
+	setProperty("TZOFFSETTO", formatUTCOffset(to), &properties)
+	if name != "" {
+		setProperty("TZNAME", escapeText(name), &properties)
+	}
+	return properties
+}
+
+// localTime formats the time of a transition in the offset before it
+func localTime(t transition) string {
+	return t.at.Add(time.Duration(t.from) * time.Second).Format(dateTimeLayoutLocalized)
+}
+
+// yearlyRule returns the RRULE of transitions happening once each of the
+// years, on the same weekday of a month at the same time, empty when they
+// don't
+func yearlyRule(group []transition, years int) string {
+	if len(group) != years {
+		return ""
+	}
+
+	var month time.Month
+	var weekday time.Weekday
+	var clock string
+	nth, last := true, true
+	n := 0
+	for i, t := range group {
+		local := t.at.Add(time.Duration(t.from) * time.Second)
+		if i == 0 {
+			month, weekday, clock = local.Month(), local.Weekday(), local.Format("150405")
+			n = (local.Day()-1)/7 + 1
+		}
+		if local.Month() != month || local.Weekday() != weekday || local.Format("150405") != clock {
+			return ""
+		}
+		nth = nth && (local.Day()-1)/7+1 == n
+		last = last && local.AddDate(0, 0, 7).Month() != month
+	}
+
+	// the last weekday of the month, rather than the 4th, for a single year
+	switch {
+	case last:
+		n = -1
+	case !nth:
+		return ""
+	}
+	day := strings.ToUpper(weekday.String()[:2])
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", month, n, day)
+}
+
+// formatUTCOffset formats an offset in seconds as an utc-offset value
+// from rfc5545-3.3.14
+func formatUTCOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	s := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+	if offset%60 != 0 {
+		s += fmt.Sprintf("%02d", offset%60)
+	}
+	return s
+}
+
+// addTimezones adds a VTIMEZONE for each TZID referenced in the events and
+// todos of a calendar without one, from the IANA time zone database. Its
+// observances cover the years of the dates in this TZID.
+func addTimezones(c *Calendar) error {
+	defined := make(map[string]bool)
+	for _, tz := range c.Timezones {
+		defined[timezoneID(tz)] = true
+	}
+
+	// the first and last years of the dates of each TZID
+	years := make(map[string][2]int)
+	var tzids []string
+	add := func(properties []*Property) {
+		for _, prop := range properties {
+			tzid := paramValue(prop, "TZID")
+			if tzid == "" || defined[tzid] {
+				continue
+			}
+			for _, value := range strings.Split(prop.Value, ",") {
+				if len(value) < 4 {
+					continue
+				}
+				year, err := strconv.Atoi(value[:4])
+				if err != nil {
+					continue
+				}
+				r, ok := years[tzid]
+				if !ok {
+					tzids = append(tzids, tzid)
+					r = [2]int{year, year}
+				}
+				if year < r[0] {
+					r[0] = year
+				}
+				if year > r[1] {
+					r[1] = year
+				}
+				years[tzid] = r
+			}
+		}
+	}
+	for _, v := range c.Events {
+		add(v.Properties)
+	}
+	for _, t := range c.Todos {
+		add(t.Properties)
+	}
+
+	sort.Strings(tzids)
+	for _, tzid := range tzids {
+		loc, err := time.LoadLocation(tzid)
+		if err != nil {
+			return fmt.Errorf("no VTIMEZONE for TZID %q: %v", tzid, err)
+		}
+		c.Timezones = append(c.Timezones, newTimezone(loc, years[tzid][0], years[tzid][1]))
+	}
+	return nil
+}
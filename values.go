@@ -0,0 +1,271 @@
+package ical
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultValueTypes are the value types of the properties without VALUE
+// param, other properties being of an unknown type
+// from rfc5545-3.8 and rfc7986-5
+var defaultValueTypes = map[string]string{
+	"ACTION": "text", "CALSCALE": "text", "CATEGORIES": "text", "CLASS": "text",
+	"COLOR": "text", "COMMENT": "text", "CONTACT": "text", "DESCRIPTION": "text",
+	"LOCATION": "text", "METHOD": "text", "NAME": "text", "PRODID": "text",
+	"RELATED-TO": "text", "REQUEST-STATUS": "text", "RESOURCES": "text",
+	"STATUS": "text", "SUMMARY": "text", "TRANSP": "text", "TZID": "text",
+	"TZNAME": "text", "UID": "text", "VERSION": "text",
+
+	"ACKNOWLEDGED": "date-time", "COMPLETED": "date-time", "CREATED": "date-time",
+	"DTEND": "date-time", "DTSTAMP": "date-time", "DTSTART": "date-time",
+	"DUE": "date-time", "EXDATE": "date-time", "LAST-MODIFIED": "date-time",
+	"RDATE": "date-time", "RECURRENCE-ID": "date-time",
+
+	"DURATION": "duration", "REFRESH-INTERVAL": "duration", "TRIGGER": "duration",
+
+	"PERCENT-COMPLETE": "integer", "PRIORITY": "integer", "REPEAT": "integer",
+	"SEQUENCE": "integer",
+
+	"ATTACH": "uri", "CONFERENCE": "uri", "IMAGE": "uri", "SOURCE": "uri",
+	"TZURL": "uri", "URL": "uri",
+
+	"ATTENDEE": "cal-address", "ORGANIZER": "cal-address",
+	"FREEBUSY": "period",
+	"GEO":      "float",
+	"RRULE":    "recur", "EXRULE": "recur",
+	"TZOFFSETFROM": "utc-offset", "TZOFFSETTO": "utc-offset",
+}
+
+// multiValued are the properties whose value is a list separated by commas
+var multiValued = map[string]bool{
+	"CATEGORIES": true, "RESOURCES": true, "EXDATE": true, "RDATE": true, "FREEBUSY": true,
+}
+
+// structured are the properties whose value is made of parts separated by
+// semicolons
+var structured = map[string]bool{
+	"GEO": true, "REQUEST-STATUS": true,
+}
+
+// recurParts are the parts of a recurrence rule, in the order they are written
+var recurParts = []string{"FREQ", "UNTIL", "COUNT", "INTERVAL", "BYSECOND", "BYMINUTE", "BYHOUR", "BYDAY", "BYMONTHDAY", "BYYEARDAY", "BYWEEKNO", "BYMONTH", "BYSETPOS", "WKST"}
+
+// typedProperty creates a property with a VALUE param when its value type
+// isn't the default one
+func typedProperty(name, typ string) *Property {
+	p := NewProperty()
+	p.Name = strings.ToUpper(name)
+	if typ != "unknown" && typ != valueType(p) {
+		p.Params["VALUE"] = &Param{Values: []string{strings.ToUpper(typ)}}
+	}
+	return p
+}
+
+// valueType returns the value type of a property in lower case, from its
+// VALUE param or else its name
+func valueType(prop *Property) string {
+	if param, ok := prop.Params["VALUE"]; ok && len(param.Values) > 0 {
+		return strings.ToLower(param.Values[0])
+	}
+	if t, ok := defaultValueTypes[prop.Name]; ok {
+		return t
+	}
+	return "unknown"
+}
+
+// formatValues returns the values of a property in the form of jCal and
+// xCal: text unescaped, dates and times with separators. A structured
+// value is returned as its parts.
+// from rfc7265-3.6 and rfc6321-3.6
+func formatValues(prop *Property, typ string) []string {
+	var values []string
+	switch {
+	case structured[prop.Name]:
+		values = splitText(prop.Value, ';')
+	case multiValued[prop.Name] && typ == "text":
+		values = splitText(prop.Value, ',')
+	case multiValued[prop.Name]:
+		values = strings.Split(prop.Value, ",")
+	default:
+		values = []string{prop.Value}
+	}
+
+	for i, value := range values {
+		switch typ {
+		case "text":
+			if !structured[prop.Name] && !multiValued[prop.Name] {
+				value = unescapeText(value)
+			}
+		case "date", "date-time", "time", "utc-offset", "period":
+			value = formatXValue(typ, value)
+		}
+		values[i] = value
+	}
+	return values
+}
+
+// formatXValue formats a date, date-time, time, utc-offset or period value
+// with the separators of jCal and xCal
+func formatXValue(typ, value string) string {
+	switch typ {
+	case "date":
+		if len(value) == 8 {
+			return value[:4] + "-" + value[4:6] + "-" + value[6:]
+		}
+	case "time":
+		if len(value) >= 6 {
+			return value[:2] + ":" + value[2:4] + ":" + value[4:]
+		}
+	case "date-time":
+		if i := strings.Index(value, "T"); i == 8 {
+			return formatXValue("date", value[:i]) + "T" + formatXValue("time", value[i+1:])
+		}
+	case "utc-offset":
+		if len(value) >= 5 {
+			offset := value[:3] + ":" + value[3:5]
+			if len(value) == 7 {
+				offset += ":" + value[5:]
+			}
+			return offset
+		}
+	case "period":
+		parts := strings.SplitN(value, "/", 2)
+		if len(parts) == 2 {
+			end := parts[1]
+			if !strings.HasPrefix(end, "P") && !strings.HasPrefix(end, "+P") {
+				end = formatXValue("date-time", end)
+			}
+			return formatXValue("date-time", parts[0]) + "/" + end
+		}
+	}
+	return value
+}
+
+// parseXValue reverts formatXValue
+func parseXValue(typ, value string) string {
+	switch typ {
+	case "date", "date-time", "time":
+		return strings.NewReplacer("-", "", ":", "").Replace(value)
+	case "utc-offset":
+		return strings.Replace(value, ":", "", -1)
+	case "period":
+		parts := strings.SplitN(value, "/", 2)
+		for i := range parts {
+			if !strings.HasPrefix(parts[i], "P") {
+				parts[i] = parseXValue("date-time", parts[i])
+			}
+		}
+		return strings.Join(parts, "/")
+	}
+	return value
+}
+
+// parseValues returns the value of a property from values in the form of
+// jCal and xCal, reverting formatValues
+func parseValues(name, typ string, values []string) string {
+	values = append([]string(nil), values...)
+	for i, value := range values {
+		switch typ {
+		case "text":
+			values[i] = escapeText(value)
+		case "date", "date-time", "time", "utc-offset", "period":
+			values[i] = parseXValue(typ, value)
+		}
+	}
+	if structured[name] {
+		return strings.Join(values, ";")
+	}
+	return strings.Join(values, ",")
+}
+
+// splitRecur returns the parts of a recurrence rule, names in lower case and
+// lists split, UNTIL with separators
+func splitRecur(value string) (names []string, parts map[string][]string) {
+	parts = make(map[string][]string)
+	for _, part := range strings.Split(value, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		name := strings.ToLower(kv[0])
+		values := strings.Split(kv[1], ",")
+		if name == "until" {
+			typ := "date-time"
+			if len(kv[1]) == 8 {
+				typ = "date"
+			}
+			values = []string{formatXValue(typ, kv[1])}
+		}
+		names = append(names, name)
+		parts[name] = values
+	}
+	return names, parts
+}
+
+// joinRecur returns a recurrence rule from its parts, reverting splitRecur
+func joinRecur(parts map[string][]string) string {
+	var rule []string
+	written := make(map[string]bool)
+	for _, name := range recurParts {
+		if values, ok := parts[strings.ToLower(name)]; ok {
+			if name == "UNTIL" {
+				values = []string{parseXValue("date-time", strings.Join(values, ""))}
+			}
+			rule = append(rule, name+"="+strings.Join(values, ","))
+			written[strings.ToLower(name)] = true
+		}
+	}
+	var others []string
+	for name := range parts {
+		if !written[name] {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		rule = append(rule, strings.ToUpper(name)+"="+strings.Join(parts[name], ","))
+	}
+	return strings.Join(rule, ";")
+}
+
+// escapeText escapes a text value
+// from rfc5545-3.3.11
+func escapeText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// unescapeText unescapes a text value
+func unescapeText(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) {
+			i++
+			switch text[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(text[i])
+			}
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// splitText splits a text value on a separator not escaped, and unescapes
+// the parts
+func splitText(text string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, unescapeText(text[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, unescapeText(text[start:]))
+}
@@ -0,0 +1,331 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// nsXCal is the XML namespace of xCal, from rfc6321-3.1
+const nsXCal = "urn:ietf:params:xml:ns:icalendar-2.0"
+
+// paramTypes are the value types of the params which aren't text
+// from rfc6321-3.5
+var paramTypes = map[string]string{
+	"ALTREP": "uri", "DIR": "uri",
+	"DELEGATED-FROM": "cal-address", "DELEGATED-TO": "cal-address",
+	"MEMBER": "cal-address", "SENT-BY": "cal-address",
+}
+
+// xcalElement is an element of an xCal document. Elements are kept generic,
+// their names being the names of components, properties, params and value
+// types.
+type xcalElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr    `xml:",any,attr"`
+	Text     string        `xml:",chardata"`
+	Children []xcalElement `xml:",any"`
+}
+
+// MarshalXCal returns the xCal form of a calendar, an XML document in the
+// urn:ietf:params:xml:ns:icalendar-2.0 namespace
+// from rfc6321
+func MarshalXCal(c *Calendar) ([]byte, error) {
+	root := xcalElement{
+		XMLName:  xml.Name{Local: "icalendar"},
+		Attrs:    []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: nsXCal}},
+		Children: []xcalElement{xcalCalendar(c)},
+	}
+	data, err := xml.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// xcalCalendar returns the xCal element of a calendar
+func xcalCalendar(c *Calendar) xcalElement {
+	return xcalComponent(c)
+}
+
+// xcalComponent returns the xCal element of a component
+func xcalComponent(component interface{}) xcalElement {
+	name, _, properties, components := contents(component)
+	var children []xcalElement
+	for _, c := range components {
+		children = append(children, xcalComponent(c))
+	}
+	return xcalContainer(strings.ToLower(name), properties, children)
+}
+
+// xcalContainer returns the element of a component, with its properties and
+// components elements when not empty
+// from rfc6321-3.3
+func xcalContainer(name string, properties []*Property, components []xcalElement) xcalElement {
+	e := xcalElement{XMLName: xml.Name{Local: name}}
+	if len(properties) > 0 {
+		list := xcalElement{XMLName: xml.Name{Local: "properties"}}
+		for _, prop := range properties {
+			list.Children = append(list.Children, xcalProperty(prop))
+		}
+		e.Children = append(e.Children, list)
+	}
+	if len(components) > 0 {
+		e.Children = append(e.Children, xcalElement{XMLName: xml.Name{Local: "components"}, Children: components})
+	}
+	return e
+}
+
+// xcalProperty returns the xCal element of a property, its params followed
+// by its values within value type elements
+// from rfc6321-3.4
+func xcalProperty(prop *Property) xcalElement {
+	typ := valueType(prop)
+	e := xcalElement{XMLName: xml.Name{Local: strings.ToLower(prop.Name)}}
+
+	names := make([]string, 0, len(prop.Params))
+	for name := range prop.Params {
+		if name != "VALUE" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		params := xcalElement{XMLName: xml.Name{Local: "parameters"}}
+		for _, name := range names {
+			paramType := paramTypes[name]
+			if paramType == "" {
+				paramType = "text"
+			}
+			param := xcalElement{XMLName: xml.Name{Local: strings.ToLower(name)}}
+			for _, value := range prop.Params[name].Values {
+				param.Children = append(param.Children, xcalText(paramType, value))
+			}
+			params.Children = append(params.Children, param)
+		}
+		e.Children = append(e.Children, params)
+	}
+
+	values := formatValues(prop, typ)
+	switch {
+	case typ == "recur":
+		e.Children = append(e.Children, xcalRecur(prop.Value))
+	case prop.Name == "GEO":
+		e.Children = append(e.Children, xcalParts(values, "latitude", "longitude")...)
+	case prop.Name == "REQUEST-STATUS":
+		e.Children = append(e.Children, xcalParts(values, "code", "description", "data")...)
+	case typ == "period":
+		for _, value := range values {
+			e.Children = append(e.Children, xcalPeriod(value))
+		}
+	default:
+		for _, value := range values {
+			if typ == "boolean" {
+				value = strings.ToLower(value)
+			}
+			e.Children = append(e.Children, xcalText(typ, value))
+		}
+	}
+	return e
+}
+
+// xcalText returns an element holding a text
+func xcalText(name, text string) xcalElement {
+	return xcalElement{XMLName: xml.Name{Local: name}, Text: text}
+}
+
+// xcalParts returns the elements of the parts of a structured value
+// from rfc6321-3.4.1.2
+func xcalParts(values []string, names ...string) []xcalElement {
+	var parts []xcalElement
+	for i, value := range values {
+		if i < len(names) {
+			parts = append(parts, xcalText(names[i], value))
+		}
+	}
+	return parts
+}
+
+// xcalPeriod returns the element of a period, ending with a date-time or a
+// duration
+// from rfc6321-3.6.9
+func xcalPeriod(value string) xcalElement {
+	e := xcalElement{XMLName: xml.Name{Local: "period"}}
+	parts := strings.SplitN(value, "/", 2)
+	e.Children = append(e.Children, xcalText("start", parts[0]))
+	if len(parts) == 2 {
+		end := "end"
+		if strings.HasPrefix(parts[1], "P") || strings.HasPrefix(parts[1], "+P") {
+			end = "duration"
+		}
+		e.Children = append(e.Children, xcalText(end, parts[1]))
+	}
+	return e
+}
+
+// xcalRecur returns the element of a recurrence rule, a child element for
+// each value of its parts
+// from rfc6321-3.6.10
+func xcalRecur(value string) xcalElement {
+	e := xcalElement{XMLName: xml.Name{Local: "recur"}}
+	names, parts := splitRecur(value)
+	for _, name := range names {
+		for _, v := range parts[name] {
+			e.Children = append(e.Children, xcalText(name, v))
+		}
+	}
+	return e
+}
+
+// UnmarshalXCal parses the xCal form of a calendar, as Parse does for the
+// iCalendar format. The first vcalendar element of the xCal namespace is
+// parsed wherever it is in the document, such as in the body of a SOAP
+// envelope.
+// if the time.Location parameter is not set, it will default to the system location
+func UnmarshalXCal(data []byte, l *time.Location) (*Calendar, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no xCal vcalendar element found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Space != nsXCal || start.Name.Local != "vcalendar" {
+			continue
+		}
+		var root xcalElement
+		if err := d.DecodeElement(&root, &start); err != nil {
+			return nil, err
+		}
+
+		var b strings.Builder
+		if err := writeXCalComponent(&b, root); err != nil {
+			return nil, err
+		}
+		return Parse(strings.NewReader(b.String()), l)
+	}
+}
+
+// writeXCalComponent writes an xCal component in the iCalendar format
+func writeXCalComponent(b *strings.Builder, component xcalElement) error {
+	name := strings.ToUpper(component.XMLName.Local)
+	b.WriteString("BEGIN:" + name + crlf)
+	for _, child := range component.Children {
+		switch child.XMLName.Local {
+		case "properties":
+			for _, prop := range child.Children {
+				line, err := xcalContentLine(prop)
+				if err != nil {
+					return err
+				}
+				b.WriteString(line + crlf)
+			}
+		case "components":
+			for _, c := range child.Children {
+				if err := writeXCalComponent(b, c); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("invalid xCal element %s in %s", child.XMLName.Local, name)
+		}
+	}
+	b.WriteString("END:" + name + crlf)
+	return nil
+}
+
+// xcalContentLine returns the content line of an xCal property
+func xcalContentLine(prop xcalElement) (string, error) {
+	typ := "unknown"
+	var values []string
+	var params []xcalElement
+	for _, child := range prop.Children {
+		switch child.XMLName.Local {
+		case "parameters":
+			params = child.Children
+		case "latitude", "longitude":
+			typ = "float"
+			values = append(values, child.Text)
+		case "code", "description", "data":
+			typ = "text"
+			values = append(values, child.Text)
+		case "recur":
+			typ = "recur"
+			values = append(values, xcalRecurValue(child))
+		case "period":
+			typ = "period"
+			values = append(values, xcalPeriodValue(child))
+		default:
+			typ = child.XMLName.Local
+			values = append(values, child.Text)
+		}
+	}
+	if len(values) == 0 {
+		return "", fmt.Errorf("invalid xCal property %s, no value", prop.XMLName.Local)
+	}
+
+	p := typedProperty(prop.XMLName.Local, typ)
+	for _, param := range params {
+		values := make([]string, 0, len(param.Children))
+		for _, value := range param.Children {
+			values = append(values, value.Text)
+		}
+		p.Params[strings.ToUpper(param.XMLName.Local)] = &Param{Values: values}
+	}
+
+	switch typ {
+	case "recur":
+		p.Value = values[0]
+	case "boolean":
+		p.Value = strings.ToUpper(strings.Join(values, ","))
+	default:
+		p.Value = parseValues(p.Name, typ, values)
+	}
+	return encodeProperty(p), nil
+}
+
+// xcalRecurValue returns the value of a recurrence rule element, the parts
+// in the order of the elements
+func xcalRecurValue(recur xcalElement) string {
+	var names []string
+	parts := make(map[string][]string)
+	for _, part := range recur.Children {
+		name := strings.ToUpper(part.XMLName.Local)
+		value := part.Text
+		if name == "UNTIL" {
+			value = parseXValue("date-time", value)
+		}
+		if _, ok := parts[name]; !ok {
+			names = append(names, name)
+		}
+		parts[name] = append(parts[name], value)
+	}
+
+	rule := make([]string, 0, len(names))
+	for _, name := range names {
+		rule = append(rule, name+"="+strings.Join(parts[name], ","))
+	}
+	return strings.Join(rule, ";")
+}
+
+// xcalPeriodValue returns the value of a period element, in the xCal form
+func xcalPeriodValue(period xcalElement) string {
+	var start, end string
+	for _, child := range period.Children {
+		switch child.XMLName.Local {
+		case "start":
+			start = child.Text
+		case "end", "duration":
+			end = child.Text
+		}
+	}
+	return start + "/" + end
+}
@@ -0,0 +1,164 @@
+package ical
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMarshalXCal(t *testing.T) {
+	file, _ := os.Open("fixtures/invitation.ics")
+	c, err := Parse(file, nil)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := c.Events[0]
+	v.Properties = append(v.Properties,
+		&Property{Name: "CATEGORIES", Value: `Work,R\,D`, Params: map[string]*Param{}},
+		&Property{Name: "GEO", Value: "48.85;2.35", Params: map[string]*Param{}},
+		&Property{Name: "REQUEST-STATUS", Value: `2.0;Success`, Params: map[string]*Param{}},
+		&Property{Name: "X-SECRET", Value: "a<b", Params: map[string]*Param{}},
+	)
+	c.Components = append(c.Components, &Component{
+		Name:       "VJOURNAL",
+		Properties: []*Property{{Name: "UID", Value: "journal@example.com", Params: map[string]*Param{}}},
+	})
+
+	data, err := MarshalXCal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xcal := strings.Join(strings.Fields(string(data)), "")
+	for _, want := range []string{
+		`<icalendarxmlns="urn:ietf:params:xml:ns:icalendar-2.0"><vcalendar><properties>`,
+		`<sequence><integer>1</integer></sequence>`,
+		`<organizer><parameters><cn><text>Alice</text></cn></parameters><cal-address>mailto:alice@example.com</cal-address></organizer>`,
+		`<dtstart><date-time>2019-01-10T10:00:00Z</date-time></dtstart>`,
+		`<rrule><recur><freq>WEEKLY</freq><count>4</count></recur></rrule>`,
+		`<categories><text>Work</text><text>R,D</text></categories>`,
+		`<geo><latitude>48.85</latitude><longitude>2.35</longitude></geo>`,
+		`<request-status><code>2.0</code><description>Success</description></request-status>`,
+		`<x-secret><unknown>a&lt;b</unknown></x-secret>`,
+		`<components><valarm><properties><action><text>DISPLAY</text></action>`,
+		`<vjournal><properties><uid><text>journal@example.com</text></uid></properties></vjournal>`,
+	} {
+		if !strings.Contains(xcal, want) {
+			t.Errorf("missing %s in %s", want, xcal)
+		}
+	}
+
+	got, err := UnmarshalXCal(data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := Diff(c, got); !s.Empty() {
+		t.Errorf("calendar differs after an xCal round trip: %+v", s)
+	}
+	if len(got.Components) != 1 || got.Components[0].Name != "VJOURNAL" || getProperty("X-SECRET", got.Events[0].Properties).Value != "a<b" {
+		t.Errorf("unknown properties or components lost: %v", got.Components)
+	}
+}
+
+func TestXCalRoundTrip(t *testing.T) {
+	for _, filename := range append(calendarList, "fixtures/icalendar.ics", "fixtures/work.ics") {
+		t.Run(filename, func(t *testing.T) {
+			file, _ := os.Open(filename)
+			c, err := Parse(file, nil)
+			file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := MarshalXCal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalXCal(data, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := MarshalXCal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(data) {
+				t.Errorf("expected %s, got %s", data, again)
+			}
+		})
+	}
+}
+
+func TestUnmarshalXCal(t *testing.T) {
+	data := `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <GetMeetingResponse xmlns="http://example.com/meetings">
+      <icalendar xmlns="urn:ietf:params:xml:ns:icalendar-2.0">
+        <vcalendar>
+          <properties>
+            <prodid><text>-//test//EN</text></prodid>
+            <version><text>2.0</text></version>
+          </properties>
+          <components>
+            <vevent>
+              <properties>
+                <uid><text>xcal@example.com</text></uid>
+                <dtstamp><date-time>2019-01-01T00:00:00Z</date-time></dtstamp>
+                <dtstart>
+                  <parameters><tzid><text>America/New_York</text></tzid></parameters>
+                  <date-time>2019-01-10T10:00:00</date-time>
+                </dtstart>
+                <duration><duration>PT1H</duration></duration>
+                <rrule><recur><freq>WEEKLY</freq><byday>MO</byday><byday>TH</byday><until>2019-02-01T00:00:00Z</until></recur></rrule>
+                <exdate><date>2019-01-14</date><date>2019-01-17</date></exdate>
+                <attendee>
+                  <parameters>
+                    <cn><text>Doe, John</text></cn>
+                    <delegated-from><cal-address>mailto:a@example.com</cal-address></delegated-from>
+                  </parameters>
+                  <cal-address>mailto:john@example.com</cal-address>
+                </attendee>
+                <tzoffsetfrom><utc-offset>-05:00</utc-offset></tzoffsetfrom>
+                <x-travel><boolean>true</boolean></x-travel>
+              </properties>
+            </vevent>
+          </components>
+        </vcalendar>
+      </icalendar>
+    </GetMeetingResponse>
+  </soap:Body>
+</soap:Envelope>`
+	c, err := UnmarshalXCal([]byte(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := c.Events[0]
+	if v.UID != "xcal@example.com" || v.StartDate.Location().String() != "America/New_York" || v.EndDate.Sub(v.StartDate).Hours() != 1 {
+		t.Errorf("unexpected event %+v", v)
+	}
+	for _, want := range []string{
+		`RRULE:FREQ=WEEKLY;BYDAY=MO,TH;UNTIL=20190201T000000Z`,
+		`EXDATE;VALUE=DATE:20190114,20190117`,
+		`ATTENDEE;CN="Doe, John";DELEGATED-FROM="mailto:a@example.com":mailto:john@example.com`,
+		`TZOFFSETFROM:-0500`,
+		`X-TRAVEL;VALUE=BOOLEAN:TRUE`,
+	} {
+		if !containsString(propertyLines(v.Properties), want) {
+			t.Errorf("missing %s in %v", want, propertyLines(v.Properties))
+		}
+	}
+
+	if _, err := UnmarshalXCal([]byte(`<vcalendar/>`), nil); err == nil {
+		t.Error("expected an error for a vcalendar outside of the xCal namespace")
+	}
+}
+
+// propertyLines returns the content lines of properties in the canonical form
+func propertyLines(properties []*Property) []string {
+	var lines []string
+	for _, prop := range properties {
+		lines = append(lines, formatProperty(prop))
+	}
+	return lines
+}